@@ -3,8 +3,6 @@ package gotezos
 import (
 	"crypto/sha512"
 	"encoding/json"
-	"strconv"
-	"strings"
 
 	"github.com/Messer4/base58check"
 	"github.com/pkg/errors"
@@ -21,9 +19,9 @@ type AccountService struct {
 
 // FrozenBalance is representation of frozen balance on the Tezos network
 type FrozenBalance struct {
-	Deposits string `json:"deposits"`
-	Fees     string `json:"fees"`
-	Rewards  string `json:"rewards"`
+	Deposits Mutez `json:"deposits"`
+	Fees     Mutez `json:"fees"`
+	Rewards  Mutez `json:"rewards"`
 }
 
 //Wallet needed for signing operations
@@ -48,7 +46,7 @@ func (gt *GoTezos) newAccountService() *AccountService {
 }
 
 // GetBalanceAtSnapshot gets the balance of a public key hash at a specific snapshot for a cycle.
-func (s *AccountService) GetBalanceAtSnapshot(tezosAddr string, cycle int) (float64, error) {
+func (s *AccountService) GetBalanceAtSnapshot(tezosAddr string, cycle int) (Mutez, error) {
 	snapShot, err := s.gt.SnapShot.Get(cycle)
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get balance for %s at snapshot at %d cycle", tezosAddr, cycle)
@@ -60,21 +58,16 @@ func (s *AccountService) GetBalanceAtSnapshot(tezosAddr string, cycle int) (floa
 		return 0, errors.Wrapf(err, "could not get balance at snapshot '%s'", query)
 	}
 
-	strBalance, err := unmarshalString(resp)
+	balance, err := unmarshalMutez(resp)
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get balance at snapshot '%s'", query)
 	}
 
-	floatBalance, err := strconv.ParseFloat(strBalance, 64)
-	if err != nil {
-		return 0, errors.Wrapf(err, "could not get balance at snapshot '%s'", query)
-	}
-
-	return floatBalance / MUTEZ, nil
+	return balance, nil
 }
 
 // GetBalance gets the balance of a public key hash at a specific snapshot for a cycle.
-func (s *AccountService) GetBalance(tezosAddr string) (float64, error) {
+func (s *AccountService) GetBalance(tezosAddr string) (Mutez, error) {
 
 	query := "/chains/main/blocks/head/context/contracts/" + tezosAddr + "/balance"
 	resp, err := s.gt.Get(query, nil)
@@ -82,22 +75,16 @@ func (s *AccountService) GetBalance(tezosAddr string) (float64, error) {
 		return 0, errors.Wrapf(err, "could not get balance '%s'", query)
 	}
 
-	strBalance, err := unmarshalString(resp)
+	balance, err := unmarshalMutez(resp)
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get balance '%s'", query)
 	}
 
-	floatBalance, err := strconv.ParseFloat(strBalance, 64)
-	if err != nil {
-		return 0, errors.Wrapf(err, "could not get balance '%s'", query)
-	}
-
-	return floatBalance / MUTEZ, nil
+	return balance, nil
 }
 
 // GetBalanceAtBlock get the balance of an address at a specific hash
-func (s *AccountService) GetBalanceAtBlock(tezosAddr string, id interface{}) (int, error) {
-	var balance string
+func (s *AccountService) GetBalanceAtBlock(tezosAddr string, id interface{}) (Mutez, error) {
 	block, err := s.gt.Block.Get(id)
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get balance at block %v", id)
@@ -109,24 +96,13 @@ func (s *AccountService) GetBalanceAtBlock(tezosAddr string, id interface{}) (in
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get balance at block '%s'", query)
 	}
-	balance, err = unmarshalString(resp)
+
+	balance, err := unmarshalMutez(resp)
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get balance at block '%s'", query)
 	}
 
-	var returnBalance int
-	if strings.Contains(balance, "No service found at gt URL") {
-		returnBalance = 0
-	}
-
-	if len(balance) < 1 {
-		returnBalance = 0
-	} else {
-		floatBalance, _ := strconv.Atoi(balance) //TODO error checking
-		returnBalance = int(floatBalance)
-	}
-
-	return returnBalance, nil
+	return balance, nil
 }
 
 // CreateWallet returns Wallet with the mnemonic and password provided
@@ -293,6 +269,16 @@ func (s *AccountService) generatePublicHash(publicKey []byte) (string, error) {
 	return b58cencode(hash.Sum(nil), tz1), nil
 }
 
+// unmarshalMutez unmarshals the bytes received as a parameter, into the type Mutez.
+func unmarshalMutez(v []byte) (Mutez, error) {
+	var m Mutez
+	err := json.Unmarshal(v, &m)
+	if err != nil {
+		return m, errors.Wrap(err, "could not unmarshal bytes to Mutez")
+	}
+	return m, nil
+}
+
 // unmarshalString unmarshals the bytes received as a parameter, into the type string.
 func unmarshalString(v []byte) (string, error) {
 	var str string
@@ -0,0 +1,341 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// How many blocks are fetched concurrently when scanning for big map keys
+	bigMapScanWorkers = 50
+)
+
+// Actions of a big map diff
+const (
+	BigMapDiffUpdate = "update"
+	BigMapDiffRemove = "remove"
+	BigMapDiffCopy   = "copy"
+	BigMapDiffAlloc  = "alloc"
+)
+
+// BigMap is a big map in the storage of a contract. Annots are the annotations of its type in the storage type.
+type BigMap struct {
+	ID        int
+	Annots    []string
+	KeyType   Micheline
+	ValueType Micheline
+}
+
+// BigMapKey is a key of a big map seen in the big map diffs of operation receipts, with its value at Level.
+// Value is nil once the key was removed.
+type BigMapKey struct {
+	Key     Micheline
+	KeyHash string
+	Value   *Micheline
+	Level   int
+}
+
+// bigMapScanJob is a block scanned by GetBigMapKeys
+type bigMapScanJob struct {
+	level int
+}
+
+// bigMapScanJobResult is the operations of a block scanned by GetBigMapKeys
+type bigMapScanJobResult struct {
+	level      int
+	operations [][]Operation
+	err        error
+}
+
+// GetBigMaps gets the big maps in the storage of a contract at the block id
+func (s *ContractService) GetBigMaps(contract string, id interface{}) ([]BigMap, error) {
+	script, err := s.GetScript(contract, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get big maps")
+	}
+
+	storageType, err := script.StorageType()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get big maps")
+	}
+
+	bigMaps, err := findBigMaps(script.Storage, storageType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get big maps of contract %s", contract)
+	}
+
+	return bigMaps, nil
+}
+
+// GetBigMapValue gets the value of a key of a big map at the block id. The key is packed and hashed locally with
+// the key type of the big map, and the value is returned in its readable form. It reports false when the big map
+// has no such key.
+func (s *ContractService) GetBigMapValue(bigMap BigMap, key Micheline, id interface{}) (Micheline, bool, error) {
+	var value Micheline
+
+	hash, err := ScriptExpressionHash(key, bigMap.KeyType)
+	if err != nil {
+		return value, false, errors.Wrap(err, "could not get big map value")
+	}
+
+	query, err := blockQuery(id)
+	if err != nil {
+		return value, false, errors.Wrap(err, "could not get big map value")
+	}
+	query = query + "/context/big_maps/" + strconv.Itoa(bigMap.ID) + "/" + hash
+
+	resp, err := s.gt.Get(query, nil)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "404 ") {
+			return value, false, nil
+		}
+		return value, false, errors.Wrapf(err, "could not get big map value '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &value)
+	if err != nil {
+		return value, false, errors.Wrapf(err, "could not get big map value '%s'", query)
+	}
+
+	if bigMap.ValueType.Kind == MichelinePrim {
+		value, err = convertData(value, bigMap.ValueType, readableData)
+		if err != nil {
+			return value, false, errors.Wrapf(err, "could not get big map value '%s'", query)
+		}
+	}
+
+	return value, true, nil
+}
+
+// GetBigMapKeys collects the keys of a big map from the big map diffs of the operation receipts of the blocks
+// from level first to level last, with their value at the last block that changed them. Only keys changed in
+// those blocks are known, so first should be the level the big map was originated at. Keys copied from another
+// big map are known if the copied keys were changed in those blocks as well.
+func (s *ContractService) GetBigMapKeys(bigMap BigMap, first, last int) ([]BigMapKey, error) {
+	if first > last {
+		return nil, errors.Errorf("could not get big map keys, first level %d is after last level %d", first, last)
+	}
+
+	levels := last - first + 1
+	jobs := make(chan bigMapScanJob, levels)
+	results := make(chan bigMapScanJobResult, levels)
+
+	for w := 1; w <= bigMapScanWorkers; w++ {
+		go s.bigMapScanWorker(jobs, results)
+	}
+
+	for level := first; level <= last; level++ {
+		jobs <- bigMapScanJob{level: level}
+	}
+	close(jobs)
+
+	var err error
+	byLevel := make(map[int][][]Operation)
+	for i := 0; i < levels; i++ {
+		result := <-results
+		if result.err != nil {
+			err = result.err
+			continue
+		}
+		byLevel[result.level] = result.operations
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get keys of big map %d", bigMap.ID)
+	}
+
+	// Diffs are replayed in the order of the blocks, so keys end up with their latest value
+	keys := make(map[int]map[string]BigMapKey)
+	for level := first; level <= last; level++ {
+		for _, diff := range bigMapDiffs(byLevel[level]) {
+			err := applyBigMapDiff(keys, diff, level)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get keys of big map %d at level %d", bigMap.ID, level)
+			}
+		}
+	}
+
+	var list []BigMapKey
+	for _, key := range keys[bigMap.ID] {
+		if bigMap.KeyType.Kind == MichelinePrim {
+			key.Key, err = convertData(key.Key, bigMap.KeyType, readableData)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get keys of big map %d", bigMap.ID)
+			}
+		}
+		if key.Value != nil && bigMap.ValueType.Kind == MichelinePrim {
+			value, err := convertData(*key.Value, bigMap.ValueType, readableData)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get keys of big map %d", bigMap.ID)
+			}
+			key.Value = &value
+		}
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, k int) bool { return list[i].KeyHash < list[k].KeyHash })
+
+	return list, nil
+}
+
+func (s *ContractService) bigMapScanWorker(jobs <-chan bigMapScanJob, results chan<- bigMapScanJobResult) {
+	for j := range jobs {
+		operations, err := s.gt.Block.GetOperations(j.level)
+		results <- bigMapScanJobResult{level: j.level, operations: operations, err: err}
+	}
+}
+
+// findBigMaps walks a storage value along its type and returns the big maps it holds
+func findBigMaps(value, typ Micheline) ([]BigMap, error) {
+	if typ.Kind != MichelinePrim {
+		return nil, errors.Errorf("invalid type %s", typ.Michelson())
+	}
+
+	switch typ.Prim {
+	case "big_map":
+		if value.Kind != MichelineInt || len(typ.Args) != 2 || !value.Int.IsInt64() {
+			return nil, errors.Errorf("big map %s is not an id", value.Michelson())
+		}
+		return []BigMap{{ID: int(value.Int.Int64()), Annots: typ.Annots, KeyType: typ.Args[0], ValueType: typ.Args[1]}}, nil
+
+	case "pair", "or", "option":
+		if value.Kind != MichelinePrim {
+			return nil, errors.Errorf("value %s does not match type %s", value.Michelson(), typ.Michelson())
+		}
+		var types []Micheline
+		switch {
+		case typ.Prim == "pair" && value.Prim == "Pair" && len(typ.Args) == 2:
+			types = typ.Args
+		case typ.Prim == "or" && value.Prim == "Left" && len(typ.Args) == 2:
+			types = typ.Args[:1]
+		case typ.Prim == "or" && value.Prim == "Right" && len(typ.Args) == 2:
+			types = typ.Args[1:]
+		case typ.Prim == "option" && value.Prim == "Some" && len(typ.Args) == 1:
+			types = typ.Args
+		case typ.Prim == "option" && value.Prim == "None":
+		default:
+			return nil, errors.Errorf("value %s does not match type %s", value.Michelson(), typ.Michelson())
+		}
+		if len(value.Args) != len(types) {
+			return nil, errors.Errorf("value %s does not match type %s", value.Michelson(), typ.Michelson())
+		}
+
+		var bigMaps []BigMap
+		for k := range types {
+			found, err := findBigMaps(value.Args[k], types[k])
+			if err != nil {
+				return nil, err
+			}
+			bigMaps = append(bigMaps, found...)
+		}
+		return bigMaps, nil
+	}
+
+	return nil, nil
+}
+
+// bigMapDiffs returns the big map diffs of the results of operations, including their internal operations, in
+// the order they were applied
+func bigMapDiffs(operations [][]Operation) []StructBigMapDiff {
+	var diffs []StructBigMapDiff
+	appendInternal := func(internal InternalOperationResults) {
+		for _, operation := range internal {
+			switch op := operation.(type) {
+			case *InternalTransaction:
+				diffs = append(diffs, op.Result.BigMapDiff...)
+			case *InternalOrigination:
+				diffs = append(diffs, op.Result.BigMapDiff...)
+			}
+		}
+	}
+
+	for _, pass := range operations {
+		for _, operation := range pass {
+			for _, contents := range operation.Contents {
+				switch c := contents.(type) {
+				case *TransactionContents:
+					if c.Metadata != nil {
+						diffs = append(diffs, c.Metadata.OperationResult.BigMapDiff...)
+						appendInternal(c.Metadata.InternalOperationResults)
+					}
+				case *OriginationContents:
+					if c.Metadata != nil {
+						diffs = append(diffs, c.Metadata.OperationResult.BigMapDiff...)
+						appendInternal(c.Metadata.InternalOperationResults)
+					}
+				}
+			}
+		}
+	}
+
+	return diffs
+}
+
+// applyBigMapDiff applies a big map diff to the known keys of every big map
+func applyBigMapDiff(keys map[int]map[string]BigMapKey, diff StructBigMapDiff, level int) error {
+	bigMapID := func(id string) (int, error) {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return 0, errors.Errorf("invalid big map id '%s'", id)
+		}
+		return n, nil
+	}
+
+	switch diff.Action {
+	case BigMapDiffUpdate:
+		id, err := bigMapID(diff.BigMap)
+		if err != nil {
+			return err
+		}
+		var key Micheline
+		if err := json.Unmarshal(diff.Key, &key); err != nil {
+			return errors.Wrap(err, "invalid big map key")
+		}
+		entry := BigMapKey{Key: key, KeyHash: diff.KeyHash, Level: level}
+		if len(diff.Value) > 0 && string(diff.Value) != "null" {
+			var value Micheline
+			if err := json.Unmarshal(diff.Value, &value); err != nil {
+				return errors.Wrap(err, "invalid big map value")
+			}
+			entry.Value = &value
+		}
+		if keys[id] == nil {
+			keys[id] = make(map[string]BigMapKey)
+		}
+		keys[id][diff.KeyHash] = entry
+
+	case BigMapDiffRemove:
+		id, err := bigMapID(diff.BigMap)
+		if err != nil {
+			return err
+		}
+		delete(keys, id)
+
+	case BigMapDiffCopy:
+		source, err := bigMapID(diff.SourceBigMap)
+		if err != nil {
+			return err
+		}
+		destination, err := bigMapID(diff.DestinationBigMap)
+		if err != nil {
+			return err
+		}
+		copied := make(map[string]BigMapKey, len(keys[source]))
+		for hash, key := range keys[source] {
+			key.Level = level
+			copied[hash] = key
+		}
+		keys[destination] = copied
+
+	case BigMapDiffAlloc:
+		id, err := bigMapID(diff.BigMap)
+		if err != nil {
+			return err
+		}
+		keys[id] = make(map[string]BigMapKey)
+	}
+
+	return nil
+}
@@ -13,14 +13,15 @@ type BlockService struct {
 	gt *GoTezos
 }
 
-// Block is a block returned by the Tezos RPC API.
+// Block is a block returned by the Tezos RPC API. Its operations are listed by validation pass, with their
+// contents decoded into the type of their kind.
 type Block struct {
-	Protocol   string               `json:"protocol"`
-	ChainID    string               `json:"chain_id"`
-	Hash       string               `json:"hash"`
-	Header     StructHeader         `json:"header"`
-	Metadata   StructMetadata       `json:"metadata"`
-	Operations [][]StructOperations `json:"operations"`
+	Protocol   string         `json:"protocol"`
+	ChainID    string         `json:"chain_id"`
+	Hash       string         `json:"hash"`
+	Header     StructHeader   `json:"header"`
+	Metadata   StructMetadata `json:"metadata"`
+	Operations [][]Operation  `json:"operations"`
 }
 
 // StructHeader is a header in a block returned by the Tezos RPC API.
@@ -82,7 +83,7 @@ type StructLevel struct {
 type StructBalanceUpdates struct {
 	Kind     string `json:"kind"`
 	Contract string `json:"contract,omitempty"`
-	Change   string `json:"change"`
+	Change   Mutez  `json:"change"`
 	Category string `json:"category,omitempty"`
 	Delegate string `json:"delegate,omitempty"`
 	Level    int    `json:"level,omitempty"`
@@ -98,31 +99,93 @@ type StructOperations struct {
 	Signature string           `json:"signature"`
 }
 
-// StructContents is the Contents found in a operation of a block returned by the Tezos RPC API.
+// StructContents is the Contents of an operation built to be forged and injected with the Tezos RPC API. Operations
+// returned by the node are decoded into the contents types of their kind instead, see Operation.
 type StructContents struct {
 	Kind             string            `json:"kind,omitempty"`
 	Source           string            `json:"source,omitempty"`
-	Fee              string            `json:"fee,omitempty"`
+	Fee              Mutez             `json:"fee,omitempty"`
 	Counter          string            `json:"counter,omitempty"`
 	GasLimit         string            `json:"gas_limit,omitempty"`
 	StorageLimit     string            `json:"storage_limit,omitempty"`
-	Amount           string            `json:"amount,omitempty"`
+	Amount           Mutez             `json:"amount,omitempty"`
 	Destination      string            `json:"destination,omitempty"`
 	Delegate         string            `json:"delegate,omitempty"`
 	Phk              string            `json:"phk,omitempty"`
 	Secret           string            `json:"secret,omitempty"`
 	Level            int               `json:"level,omitempty"`
 	ManagerPublicKey string            `json:"managerPubkey,omitempty"`
-	Balance          string            `json:"balance,omitempty"`
+	PublicKey        string            `json:"public_key,omitempty"`
+	Period           *int              `json:"period,omitempty"`
+	Proposals        []string          `json:"proposals,omitempty"`
+	Proposal         string            `json:"proposal,omitempty"`
+	Ballot           string            `json:"ballot,omitempty"`
+	Balance          Mutez             `json:"balance,omitempty"`
+	Parameters       *StructParameters `json:"parameters,omitempty"`
+	Script           *StructScript     `json:"script,omitempty"`
 	Metadata         *ContentsMetadata `json:"metadata,omitempty"`
 }
 
+// MarshalJSON encodes the contents with the fee, amount and balance of their kind only, which are sent even when
+// they are zero
+func (c StructContents) MarshalJSON() ([]byte, error) {
+	type contents StructContents
+	v := struct {
+		contents
+		Fee     *Mutez `json:"fee,omitempty"`
+		Amount  *Mutez `json:"amount,omitempty"`
+		Balance *Mutez `json:"balance,omitempty"`
+	}{contents: contents(c)}
+
+	switch c.Kind {
+	case KindReveal, KindDelegation:
+		v.Fee = &c.Fee
+	case KindTransaction:
+		v.Fee, v.Amount = &c.Fee, &c.Amount
+	case KindOrigination:
+		v.Fee, v.Balance = &c.Fee, &c.Balance
+	}
+
+	return json.Marshal(v)
+}
+
+// StructScript is the Script found in the Contents of an origination operation returned by the Tezos RPC API.
+type StructScript struct {
+	Code    json.RawMessage `json:"code"`
+	Storage json.RawMessage `json:"storage"`
+}
+
+// StructParameters is the Parameters found in the Contents of a transaction operation returned by the Tezos RPC API.
+type StructParameters struct {
+	Entrypoint string          `json:"entrypoint"`
+	Value      json.RawMessage `json:"value"`
+}
+
 // ContentsMetadata is the Metadata found in the Contents in a operation of a block returned by the Tezos RPC API.
 type ContentsMetadata struct {
 	BalanceUpdates []StructBalanceUpdates `json:"balance_updates"`
 	Slots          []int                  `json:"slots"`
 }
 
+// StructBigMapDiff is a BigMapDiff found in the OperationResult of a manager operation returned by the Tezos RPC API.
+type StructBigMapDiff struct {
+	Action            string          `json:"action,omitempty"`
+	BigMap            string          `json:"big_map,omitempty"`
+	KeyHash           string          `json:"key_hash,omitempty"`
+	Key               json.RawMessage `json:"key,omitempty"`
+	Value             json.RawMessage `json:"value,omitempty"`
+	SourceBigMap      string          `json:"source_big_map,omitempty"`
+	DestinationBigMap string          `json:"destination_big_map,omitempty"`
+	KeyType           json.RawMessage `json:"key_type,omitempty"`
+	ValueType         json.RawMessage `json:"value_type,omitempty"`
+}
+
+// StructResultError is an error found in the OperationResult of a manager operation returned by the Tezos RPC API.
+type StructResultError struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
 // NewBlockService creates a new BlockService
 func (gt *GoTezos) newBlockService() *BlockService {
 	return &BlockService{gt: gt}
@@ -181,3 +244,14 @@ func (b *Block) unmarshalJSON(v []byte) (Block, error) {
 	}
 	return block, nil
 }
+
+// blockQuery returns the RPC path of the block id, a level or a hash
+func blockQuery(id interface{}) (string, error) {
+	switch v := id.(type) {
+	case int:
+		return "/chains/main/blocks/" + strconv.Itoa(v), nil
+	case string:
+		return "/chains/main/blocks/" + v, nil
+	}
+	return "", errors.Errorf("block id type must be string or int, got %T", id)
+}
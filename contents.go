@@ -0,0 +1,625 @@
+package gotezos
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of the contents of an operation
+const (
+	KindEndorsement               = "endorsement"
+	KindSeedNonceRevelation       = "seed_nonce_revelation"
+	KindDoubleEndorsementEvidence = "double_endorsement_evidence"
+	KindDoubleBakingEvidence      = "double_baking_evidence"
+	KindActivateAccount           = "activate_account"
+	KindProposals                 = "proposals"
+	KindBallot                    = "ballot"
+	KindReveal                    = "reveal"
+	KindTransaction               = "transaction"
+	KindOrigination               = "origination"
+	KindDelegation                = "delegation"
+)
+
+// Operation is an operation returned by the Tezos RPC API, with its contents decoded into the type of their kind
+type Operation struct {
+	Protocol  string                `json:"protocol,omitempty"`
+	ChainID   string                `json:"chain_id,omitempty"`
+	Hash      string                `json:"hash,omitempty"`
+	Branch    string                `json:"branch"`
+	Contents  OperationContentsList `json:"contents"`
+	Signature string                `json:"signature,omitempty"`
+}
+
+// OperationContents is the contents of an operation. It is one of *EndorsementContents,
+// *SeedNonceRevelationContents, *DoubleEndorsementEvidenceContents, *DoubleBakingEvidenceContents,
+// *ActivateAccountContents, *ProposalsContents, *BallotContents, *RevealContents, *TransactionContents,
+// *OriginationContents, *DelegationContents, or *UnknownOperation for kinds this library does not know.
+type OperationContents interface {
+	OperationKind() string
+}
+
+// OperationContentsList is the contents of an operation, decoded with DecodeContents
+type OperationContentsList []OperationContents
+
+// InternalOperation is an operation emitted by a smart contract. It is one of *InternalReveal,
+// *InternalTransaction, *InternalOrigination, *InternalDelegation, or *UnknownOperation.
+type InternalOperation interface {
+	OperationKind() string
+}
+
+// InternalOperationResults are the internal operations of a manager operation, decoded with DecodeInternalOperation
+type InternalOperationResults []InternalOperation
+
+// UnknownOperation is the contents of an operation of a kind this library does not know, kept as raw JSON
+type UnknownOperation struct {
+	Kind string
+	Raw  json.RawMessage
+}
+
+// BalanceUpdatesMetadata is the metadata of contents that only update balances
+type BalanceUpdatesMetadata struct {
+	BalanceUpdates []StructBalanceUpdates `json:"balance_updates"`
+}
+
+// EndorsementContents is the contents of an endorsement operation
+type EndorsementContents struct {
+	Kind     string               `json:"kind"`
+	Level    int                  `json:"level"`
+	Metadata *EndorsementMetadata `json:"metadata,omitempty"`
+}
+
+// EndorsementMetadata is the metadata of an endorsement
+type EndorsementMetadata struct {
+	BalanceUpdates []StructBalanceUpdates `json:"balance_updates"`
+	Delegate       string                 `json:"delegate"`
+	Slots          []int                  `json:"slots"`
+}
+
+// SeedNonceRevelationContents is the contents of a seed nonce revelation operation
+type SeedNonceRevelationContents struct {
+	Kind     string                  `json:"kind"`
+	Level    int                     `json:"level"`
+	Nonce    string                  `json:"nonce"`
+	Metadata *BalanceUpdatesMetadata `json:"metadata,omitempty"`
+}
+
+// DoubleEndorsementEvidenceContents is the contents of an operation denouncing a delegate that endorsed twice
+type DoubleEndorsementEvidenceContents struct {
+	Kind     string                  `json:"kind"`
+	Op1      InlinedEndorsement      `json:"op1"`
+	Op2      InlinedEndorsement      `json:"op2"`
+	Metadata *BalanceUpdatesMetadata `json:"metadata,omitempty"`
+}
+
+// InlinedEndorsement is an endorsement given as evidence in a DoubleEndorsementEvidenceContents
+type InlinedEndorsement struct {
+	Branch     string              `json:"branch"`
+	Operations EndorsementContents `json:"operations"`
+	Signature  string              `json:"signature,omitempty"`
+}
+
+// DoubleBakingEvidenceContents is the contents of an operation denouncing a delegate that baked twice
+type DoubleBakingEvidenceContents struct {
+	Kind     string                  `json:"kind"`
+	Bh1      StructHeader            `json:"bh1"`
+	Bh2      StructHeader            `json:"bh2"`
+	Metadata *BalanceUpdatesMetadata `json:"metadata,omitempty"`
+}
+
+// ActivateAccountContents is the contents of an operation activating a fundraiser account
+type ActivateAccountContents struct {
+	Kind     string                  `json:"kind"`
+	Pkh      string                  `json:"pkh"`
+	Secret   string                  `json:"secret"`
+	Metadata *BalanceUpdatesMetadata `json:"metadata,omitempty"`
+}
+
+// ProposalsContents is the contents of an operation upvoting protocol proposals
+type ProposalsContents struct {
+	Kind      string   `json:"kind"`
+	Source    string   `json:"source"`
+	Period    int      `json:"period"`
+	Proposals []string `json:"proposals"`
+}
+
+// BallotContents is the contents of an operation casting a ballot on the current proposal
+type BallotContents struct {
+	Kind     string `json:"kind"`
+	Source   string `json:"source"`
+	Period   int    `json:"period"`
+	Proposal string `json:"proposal"`
+	Ballot   string `json:"ballot"`
+}
+
+// ManagerOperation are the fields shared by the contents of manager operations
+type ManagerOperation struct {
+	Source       string `json:"source"`
+	Fee          Mutez  `json:"fee"`
+	Counter      int    `json:"counter,string"`
+	GasLimit     int    `json:"gas_limit,string"`
+	StorageLimit int    `json:"storage_limit,string"`
+}
+
+// ResultStatus is the status of the result of a manager operation, with the errors of failed and backtracked ones
+type ResultStatus struct {
+	Status string              `json:"status"`
+	Errors []StructResultError `json:"errors,omitempty"`
+}
+
+// RevealContents is the contents of an operation revealing the public key of an account
+type RevealContents struct {
+	Kind string `json:"kind"`
+	ManagerOperation
+	PublicKey string          `json:"public_key"`
+	Metadata  *RevealMetadata `json:"metadata,omitempty"`
+}
+
+// RevealMetadata is the metadata of a reveal
+type RevealMetadata struct {
+	BalanceUpdates           []StructBalanceUpdates   `json:"balance_updates"`
+	OperationResult          RevealOperationResult    `json:"operation_result"`
+	InternalOperationResults InternalOperationResults `json:"internal_operation_results,omitempty"`
+}
+
+// RevealOperationResult is the result of a reveal
+type RevealOperationResult struct {
+	ResultStatus
+	ConsumedGas int `json:"consumed_gas,string,omitempty"`
+}
+
+// TransactionContents is the contents of an operation transferring tez or calling a contract
+type TransactionContents struct {
+	Kind string `json:"kind"`
+	ManagerOperation
+	Amount      Mutez                `json:"amount"`
+	Destination string               `json:"destination"`
+	Parameters  *StructParameters    `json:"parameters,omitempty"`
+	Metadata    *TransactionMetadata `json:"metadata,omitempty"`
+}
+
+// TransactionMetadata is the metadata of a transaction
+type TransactionMetadata struct {
+	BalanceUpdates           []StructBalanceUpdates     `json:"balance_updates"`
+	OperationResult          TransactionOperationResult `json:"operation_result"`
+	InternalOperationResults InternalOperationResults   `json:"internal_operation_results,omitempty"`
+}
+
+// TransactionOperationResult is the result of a transaction
+type TransactionOperationResult struct {
+	ResultStatus
+	Storage                      json.RawMessage        `json:"storage,omitempty"`
+	BigMapDiff                   []StructBigMapDiff     `json:"big_map_diff,omitempty"`
+	BalanceUpdates               []StructBalanceUpdates `json:"balance_updates,omitempty"`
+	OriginatedContracts          []string               `json:"originated_contracts,omitempty"`
+	ConsumedGas                  int                    `json:"consumed_gas,string,omitempty"`
+	StorageSize                  int                    `json:"storage_size,string,omitempty"`
+	PaidStorageSizeDiff          int                    `json:"paid_storage_size_diff,string,omitempty"`
+	AllocatedDestinationContract bool                   `json:"allocated_destination_contract,omitempty"`
+}
+
+// OriginationContents is the contents of an operation originating a contract
+type OriginationContents struct {
+	Kind string `json:"kind"`
+	ManagerOperation
+	Balance  Mutez                `json:"balance"`
+	Delegate string               `json:"delegate,omitempty"`
+	Script   *StructScript        `json:"script,omitempty"`
+	Metadata *OriginationMetadata `json:"metadata,omitempty"`
+}
+
+// OriginationMetadata is the metadata of an origination
+type OriginationMetadata struct {
+	BalanceUpdates           []StructBalanceUpdates     `json:"balance_updates"`
+	OperationResult          OriginationOperationResult `json:"operation_result"`
+	InternalOperationResults InternalOperationResults   `json:"internal_operation_results,omitempty"`
+}
+
+// OriginationOperationResult is the result of an origination
+type OriginationOperationResult struct {
+	ResultStatus
+	BigMapDiff          []StructBigMapDiff     `json:"big_map_diff,omitempty"`
+	BalanceUpdates      []StructBalanceUpdates `json:"balance_updates,omitempty"`
+	OriginatedContracts []string               `json:"originated_contracts,omitempty"`
+	ConsumedGas         int                    `json:"consumed_gas,string,omitempty"`
+	StorageSize         int                    `json:"storage_size,string,omitempty"`
+	PaidStorageSizeDiff int                    `json:"paid_storage_size_diff,string,omitempty"`
+}
+
+// DelegationContents is the contents of an operation setting or withdrawing the delegate of an account. Delegate is
+// empty when the delegate is withdrawn.
+type DelegationContents struct {
+	Kind string `json:"kind"`
+	ManagerOperation
+	Delegate string              `json:"delegate,omitempty"`
+	Metadata *DelegationMetadata `json:"metadata,omitempty"`
+}
+
+// DelegationMetadata is the metadata of a delegation
+type DelegationMetadata struct {
+	BalanceUpdates           []StructBalanceUpdates    `json:"balance_updates"`
+	OperationResult          DelegationOperationResult `json:"operation_result"`
+	InternalOperationResults InternalOperationResults  `json:"internal_operation_results,omitempty"`
+}
+
+// DelegationOperationResult is the result of a delegation
+type DelegationOperationResult struct {
+	ResultStatus
+	ConsumedGas int `json:"consumed_gas,string,omitempty"`
+}
+
+// InternalReveal is a reveal emitted by a smart contract
+type InternalReveal struct {
+	Kind      string                `json:"kind"`
+	Source    string                `json:"source"`
+	Nonce     int                   `json:"nonce"`
+	PublicKey string                `json:"public_key"`
+	Result    RevealOperationResult `json:"result"`
+}
+
+// InternalTransaction is a transaction emitted by a smart contract
+type InternalTransaction struct {
+	Kind        string                     `json:"kind"`
+	Source      string                     `json:"source"`
+	Nonce       int                        `json:"nonce"`
+	Amount      Mutez                      `json:"amount"`
+	Destination string                     `json:"destination"`
+	Parameters  *StructParameters          `json:"parameters,omitempty"`
+	Result      TransactionOperationResult `json:"result"`
+}
+
+// InternalOrigination is an origination emitted by a smart contract
+type InternalOrigination struct {
+	Kind     string                     `json:"kind"`
+	Source   string                     `json:"source"`
+	Nonce    int                        `json:"nonce"`
+	Balance  Mutez                      `json:"balance"`
+	Delegate string                     `json:"delegate,omitempty"`
+	Script   *StructScript              `json:"script,omitempty"`
+	Result   OriginationOperationResult `json:"result"`
+}
+
+// InternalDelegation is a delegation emitted by a smart contract
+type InternalDelegation struct {
+	Kind     string                    `json:"kind"`
+	Source   string                    `json:"source"`
+	Nonce    int                       `json:"nonce"`
+	Delegate string                    `json:"delegate,omitempty"`
+	Result   DelegationOperationResult `json:"result"`
+}
+
+// OperationKind returns the kind of the contents
+func (e *EndorsementContents) OperationKind() string { return KindEndorsement }
+
+// OperationKind returns the kind of the contents
+func (s *SeedNonceRevelationContents) OperationKind() string { return KindSeedNonceRevelation }
+
+// OperationKind returns the kind of the contents
+func (d *DoubleEndorsementEvidenceContents) OperationKind() string {
+	return KindDoubleEndorsementEvidence
+}
+
+// OperationKind returns the kind of the contents
+func (d *DoubleBakingEvidenceContents) OperationKind() string { return KindDoubleBakingEvidence }
+
+// OperationKind returns the kind of the contents
+func (a *ActivateAccountContents) OperationKind() string { return KindActivateAccount }
+
+// OperationKind returns the kind of the contents
+func (p *ProposalsContents) OperationKind() string { return KindProposals }
+
+// OperationKind returns the kind of the contents
+func (b *BallotContents) OperationKind() string { return KindBallot }
+
+// OperationKind returns the kind of the contents
+func (r *RevealContents) OperationKind() string { return KindReveal }
+
+// OperationKind returns the kind of the contents
+func (t *TransactionContents) OperationKind() string { return KindTransaction }
+
+// OperationKind returns the kind of the contents
+func (o *OriginationContents) OperationKind() string { return KindOrigination }
+
+// OperationKind returns the kind of the contents
+func (d *DelegationContents) OperationKind() string { return KindDelegation }
+
+// OperationKind returns the kind of the internal operation
+func (r *InternalReveal) OperationKind() string { return KindReveal }
+
+// OperationKind returns the kind of the internal operation
+func (t *InternalTransaction) OperationKind() string { return KindTransaction }
+
+// OperationKind returns the kind of the internal operation
+func (o *InternalOrigination) OperationKind() string { return KindOrigination }
+
+// OperationKind returns the kind of the internal operation
+func (d *InternalDelegation) OperationKind() string { return KindDelegation }
+
+// OperationKind returns the kind of the contents
+func (u *UnknownOperation) OperationKind() string { return u.Kind }
+
+// MarshalJSON encodes the contents as the raw JSON they were decoded from
+func (u *UnknownOperation) MarshalJSON() ([]byte, error) {
+	return u.Raw, nil
+}
+
+// DecodeContents decodes the JSON contents of an operation into the type of their kind. Contents of an unknown
+// kind are decoded into an *UnknownOperation keeping their raw JSON.
+func DecodeContents(v []byte) (OperationContents, error) {
+	kind, err := operationKind(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode operation contents")
+	}
+
+	var contents OperationContents
+	switch kind {
+	case KindEndorsement:
+		contents = &EndorsementContents{}
+	case KindSeedNonceRevelation:
+		contents = &SeedNonceRevelationContents{}
+	case KindDoubleEndorsementEvidence:
+		contents = &DoubleEndorsementEvidenceContents{}
+	case KindDoubleBakingEvidence:
+		contents = &DoubleBakingEvidenceContents{}
+	case KindActivateAccount:
+		contents = &ActivateAccountContents{}
+	case KindProposals:
+		contents = &ProposalsContents{}
+	case KindBallot:
+		contents = &BallotContents{}
+	case KindReveal:
+		contents = &RevealContents{}
+	case KindTransaction:
+		contents = &TransactionContents{}
+	case KindOrigination:
+		contents = &OriginationContents{}
+	case KindDelegation:
+		contents = &DelegationContents{}
+	default:
+		return &UnknownOperation{Kind: kind, Raw: append(json.RawMessage(nil), v...)}, nil
+	}
+
+	err = json.Unmarshal(v, contents)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode %s contents", kind)
+	}
+
+	return contents, nil
+}
+
+// DecodeInternalOperation decodes the JSON of an internal operation result into the type of its kind. Internal
+// operations of an unknown kind are decoded into an *UnknownOperation keeping their raw JSON.
+func DecodeInternalOperation(v []byte) (InternalOperation, error) {
+	kind, err := operationKind(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode internal operation")
+	}
+
+	var operation InternalOperation
+	switch kind {
+	case KindReveal:
+		operation = &InternalReveal{}
+	case KindTransaction:
+		operation = &InternalTransaction{}
+	case KindOrigination:
+		operation = &InternalOrigination{}
+	case KindDelegation:
+		operation = &InternalDelegation{}
+	default:
+		return &UnknownOperation{Kind: kind, Raw: append(json.RawMessage(nil), v...)}, nil
+	}
+
+	err = json.Unmarshal(v, operation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode internal %s", kind)
+	}
+
+	return operation, nil
+}
+
+// UnmarshalJSON decodes a list of contents with DecodeContents
+func (l *OperationContentsList) UnmarshalJSON(v []byte) error {
+	var raw []json.RawMessage
+	err := json.Unmarshal(v, &raw)
+	if err != nil {
+		return err
+	}
+
+	list := make(OperationContentsList, len(raw))
+	for k, contents := range raw {
+		list[k], err = DecodeContents(contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	*l = list
+	return nil
+}
+
+// UnmarshalJSON decodes a list of internal operation results with DecodeInternalOperation
+func (r *InternalOperationResults) UnmarshalJSON(v []byte) error {
+	var raw []json.RawMessage
+	err := json.Unmarshal(v, &raw)
+	if err != nil {
+		return err
+	}
+
+	results := make(InternalOperationResults, len(raw))
+	for k, operation := range raw {
+		results[k], err = DecodeInternalOperation(operation)
+		if err != nil {
+			return err
+		}
+	}
+
+	*r = results
+	return nil
+}
+
+// operationKind reads the kind of the JSON of an operation
+func operationKind(v []byte) (string, error) {
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	err := json.Unmarshal(v, &kind)
+	if err != nil {
+		return "", err
+	}
+	if kind.Kind == "" {
+		return "", errors.New("operation has no kind")
+	}
+	return kind.Kind, nil
+}
+
+// operationResult is the part of the result of a manager operation or of an internal operation shared by every kind
+type operationResult struct {
+	ResultStatus
+	ConsumedGas                  int
+	StorageSize                  int
+	PaidStorageSizeDiff          int
+	AllocatedDestinationContract bool
+	OriginatedContracts          []string
+}
+
+// managerOperation returns the fields shared by manager contents
+func managerOperation(contents OperationContents) (ManagerOperation, bool) {
+	switch c := contents.(type) {
+	case *RevealContents:
+		return c.ManagerOperation, true
+	case *TransactionContents:
+		return c.ManagerOperation, true
+	case *OriginationContents:
+		return c.ManagerOperation, true
+	case *DelegationContents:
+		return c.ManagerOperation, true
+	}
+	return ManagerOperation{}, false
+}
+
+// renumberReceipts sets the counters of the manager contents of receipts to consecutive counters from counter
+func renumberReceipts(receipts OperationContentsList, counter int) {
+	for _, contents := range receipts {
+		switch c := contents.(type) {
+		case *RevealContents:
+			c.Counter = counter
+		case *TransactionContents:
+			c.Counter = counter
+		case *OriginationContents:
+			c.Counter = counter
+		case *DelegationContents:
+			c.Counter = counter
+		default:
+			continue
+		}
+		counter++
+	}
+}
+
+// contentsSource returns the source of contents, if they have one
+func contentsSource(contents OperationContents) (string, bool) {
+	switch c := contents.(type) {
+	case *ProposalsContents:
+		return c.Source, true
+	case *BallotContents:
+		return c.Source, true
+	}
+	manager, ok := managerOperation(contents)
+	return manager.Source, ok
+}
+
+// contentsResult returns the operation result and the internal operation results of manager contents, if they
+// have metadata
+func contentsResult(contents OperationContents) (operationResult, InternalOperationResults, bool) {
+	switch c := contents.(type) {
+	case *RevealContents:
+		if c.Metadata != nil {
+			return c.Metadata.OperationResult.result(), c.Metadata.InternalOperationResults, true
+		}
+	case *TransactionContents:
+		if c.Metadata != nil {
+			return c.Metadata.OperationResult.result(), c.Metadata.InternalOperationResults, true
+		}
+	case *OriginationContents:
+		if c.Metadata != nil {
+			return c.Metadata.OperationResult.result(), c.Metadata.InternalOperationResults, true
+		}
+	case *DelegationContents:
+		if c.Metadata != nil {
+			return c.Metadata.OperationResult.result(), c.Metadata.InternalOperationResults, true
+		}
+	}
+	return operationResult{}, nil, false
+}
+
+// internalResult returns the result of an internal operation of a known kind
+func internalResult(operation InternalOperation) (operationResult, bool) {
+	switch op := operation.(type) {
+	case *InternalReveal:
+		return op.Result.result(), true
+	case *InternalTransaction:
+		return op.Result.result(), true
+	case *InternalOrigination:
+		return op.Result.result(), true
+	case *InternalDelegation:
+		return op.Result.result(), true
+	}
+	return operationResult{}, false
+}
+
+// result returns the fields of the result shared by every kind
+func (r RevealOperationResult) result() operationResult {
+	return operationResult{ResultStatus: r.ResultStatus, ConsumedGas: r.ConsumedGas}
+}
+
+// result returns the fields of the result shared by every kind
+func (r TransactionOperationResult) result() operationResult {
+	return operationResult{
+		ResultStatus:                 r.ResultStatus,
+		ConsumedGas:                  r.ConsumedGas,
+		StorageSize:                  r.StorageSize,
+		PaidStorageSizeDiff:          r.PaidStorageSizeDiff,
+		AllocatedDestinationContract: r.AllocatedDestinationContract,
+		OriginatedContracts:          r.OriginatedContracts,
+	}
+}
+
+// result returns the fields of the result shared by every kind
+func (r OriginationOperationResult) result() operationResult {
+	return operationResult{
+		ResultStatus:        r.ResultStatus,
+		ConsumedGas:         r.ConsumedGas,
+		StorageSize:         r.StorageSize,
+		PaidStorageSizeDiff: r.PaidStorageSizeDiff,
+		OriginatedContracts: r.OriginatedContracts,
+	}
+}
+
+// result returns the fields of the result shared by every kind
+func (r DelegationOperationResult) result() operationResult {
+	return operationResult{ResultStatus: r.ResultStatus, ConsumedGas: r.ConsumedGas}
+}
+
+// GetOperations returns the operations of a Block at a specific level or hash, by validation pass, with their
+// contents and results decoded into the type of their kind
+func (b *BlockService) GetOperations(id interface{}) ([][]Operation, error) {
+	var operations [][]Operation
+
+	query, err := blockQuery(id)
+	if err != nil {
+		return operations, errors.Wrap(err, "could not get block operations")
+	}
+	query = query + "/operations"
+
+	resp, err := b.gt.Get(query, nil)
+	if err != nil {
+		return operations, errors.Wrapf(err, "could not get block operations '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &operations)
+	if err != nil {
+		return operations, errors.Wrapf(err, "could not get block operations '%s'", query)
+	}
+
+	return operations, nil
+}
@@ -1,23 +1,267 @@
 package gotezos
 
-import "github.com/pkg/errors"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 // ContractService is a struct wrapper for contract functions
 type ContractService struct {
 	gt *GoTezos
 }
 
+// Entrypoints is a representation of the entrypoints of a contract returned by the Tezos RPC API.
+type Entrypoints struct {
+	Entrypoints map[string]json.RawMessage `json:"entrypoints"`
+	Unreachable []json.RawMessage          `json:"unreachable,omitempty"`
+}
+
+// Script is the code and storage of a contract
+type Script struct {
+	Code    Micheline `json:"code"`
+	Storage Micheline `json:"storage"`
+}
+
+// Contract is the state of an implicit or originated account. Counter is only set for implicit accounts, and
+// Script only for originated contracts.
+type Contract struct {
+	Balance  Mutez   `json:"balance"`
+	Delegate string  `json:"delegate,omitempty"`
+	Counter  int     `json:"counter,string,omitempty"`
+	Script   *Script `json:"script,omitempty"`
+}
+
 // returns a new newContractService
 func (gt *GoTezos) newContractService() *ContractService {
 	return &ContractService{gt: gt}
 }
 
 // GetStorage gets the contract storage for a contract
-func (s *ContractService) GetStorage(contract string) ([]byte, error) {
+func (s *ContractService) GetStorage(contract string) (Micheline, error) {
+	var storage Micheline
 	query := "/chains/main/blocks/head/context/contracts/" + contract + "/storage"
 	resp, err := s.gt.Get(query, nil)
 	if err != nil {
-		return resp, errors.Wrap(err, "could not get storage '%s'")
+		return storage, errors.Wrapf(err, "could not get storage '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &storage)
+	if err != nil {
+		return storage, errors.Wrapf(err, "could not get storage '%s'", query)
+	}
+
+	return storage, nil
+}
+
+// GetEntrypoints gets the entrypoints of a contract with the Micheline type of their parameter
+func (s *ContractService) GetEntrypoints(contract string) (map[string]json.RawMessage, error) {
+	var entrypoints Entrypoints
+	query := "/chains/main/blocks/head/context/contracts/" + contract + "/entrypoints"
+	resp, err := s.gt.Get(query, nil)
+	if err != nil {
+		return entrypoints.Entrypoints, errors.Wrapf(err, "could not get entrypoints '%s'", query)
+	}
+
+	entrypoints, err = entrypoints.unmarshalJSON(resp)
+	if err != nil {
+		return entrypoints.Entrypoints, errors.Wrapf(err, "could not get entrypoints '%s'", query)
+	}
+
+	return entrypoints.Entrypoints, nil
+}
+
+// unmarshalJSON unmarshals the bytes received as a parameter, into the type Entrypoints.
+func (e *Entrypoints) unmarshalJSON(v []byte) (Entrypoints, error) {
+	entrypoints := Entrypoints{}
+	err := json.Unmarshal(v, &entrypoints)
+	if err != nil {
+		return entrypoints, errors.Wrap(err, "could not unmarshal bytes into Entrypoints")
+	}
+	return entrypoints, nil
+}
+
+// GetContract gets the state of a contract at the block id
+func (s *ContractService) GetContract(contract string, id interface{}) (Contract, error) {
+	var state Contract
+
+	query, err := contractQuery(contract, id)
+	if err != nil {
+		return state, errors.Wrap(err, "could not get contract")
+	}
+
+	resp, err := s.gt.Get(query, nil)
+	if err != nil {
+		return state, errors.Wrapf(err, "could not get contract '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &state)
+	if err != nil {
+		return state, errors.Wrapf(err, "could not get contract '%s'", query)
+	}
+
+	return state, nil
+}
+
+// GetBalance gets the balance of a contract at the block id
+func (s *ContractService) GetBalance(contract string, id interface{}) (Mutez, error) {
+	var balance Mutez
+	err := s.getContractField(contract, id, "balance", &balance)
+	if err != nil {
+		return balance, errors.Wrap(err, "could not get balance")
+	}
+	return balance, nil
+}
+
+// GetDelegate gets the delegate of a contract at the block id, or an empty string if it has none
+func (s *ContractService) GetDelegate(contract string, id interface{}) (string, error) {
+	var delegate string
+	err := s.getContractField(contract, id, "delegate", &delegate)
+	if err != nil {
+		if strings.HasPrefix(errors.Cause(err).Error(), "404 ") {
+			return "", nil
+		}
+		return delegate, errors.Wrap(err, "could not get delegate")
+	}
+	return delegate, nil
+}
+
+// GetCounter gets the counter of the manager operations of an implicit account at the block id
+func (s *ContractService) GetCounter(contract string, id interface{}) (int, error) {
+	var counter string
+	err := s.getContractField(contract, id, "counter", &counter)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get counter")
+	}
+
+	n, err := strconv.Atoi(counter)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get counter of %s", contract)
+	}
+	return n, nil
+}
+
+// GetScript gets the code and storage of a contract at the block id
+func (s *ContractService) GetScript(contract string, id interface{}) (Script, error) {
+	var script Script
+
+	query, err := contractQuery(contract, id)
+	if err != nil {
+		return script, errors.Wrap(err, "could not get script")
+	}
+	query = query + "/script"
+
+	resp, err := s.gt.Get(query, nil)
+	if err != nil {
+		return script, errors.Wrapf(err, "could not get script '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &script)
+	if err != nil {
+		return script, errors.Wrapf(err, "could not get script '%s'", query)
+	}
+
+	return script, nil
+}
+
+// GetTypedStorage gets the storage of a contract at the block id and decodes it into v with UnmarshalMicheline
+func (s *ContractService) GetTypedStorage(contract string, id interface{}, v interface{}) error {
+	script, err := s.GetScript(contract, id)
+	if err != nil {
+		return errors.Wrap(err, "could not get typed storage")
+	}
+
+	storageType, err := script.StorageType()
+	if err != nil {
+		return errors.Wrapf(err, "could not get typed storage of contract %s", contract)
+	}
+
+	err = UnmarshalMicheline(script.Storage, storageType, v)
+	if err != nil {
+		return errors.Wrapf(err, "could not get typed storage of contract %s", contract)
+	}
+
+	return nil
+}
+
+// ParameterType returns the type of the parameter of the script
+func (s Script) ParameterType() (Micheline, error) {
+	return s.section("parameter")
+}
+
+// StorageType returns the type of the storage of the script
+func (s Script) StorageType() (Micheline, error) {
+	return s.section("storage")
+}
+
+// Instructions returns the code section of the script
+func (s Script) Instructions() (Micheline, error) {
+	return s.section("code")
+}
+
+// Entrypoints returns the entrypoints of the script with the type of their parameter, found like the node does
+// from the field annotations of the parameter type. Entrypoints without an annotation, such as the default
+// entrypoint of a contract that does not name it, are not listed.
+func (s Script) Entrypoints() (map[string]Micheline, error) {
+	parameter, err := s.ParameterType()
+	if err != nil {
+		return nil, err
+	}
+
+	entrypoints := make(map[string]Micheline)
+	var walk func(typ Micheline)
+	walk = func(typ Micheline) {
+		if name := fieldAnnot(typ); name != "" {
+			entrypoints[name] = typ
+		}
+		if typ.Kind == MichelinePrim && typ.Prim == "or" && len(typ.Args) == 2 {
+			walk(typ.Args[0])
+			walk(typ.Args[1])
+		}
+	}
+	walk(parameter)
+
+	return entrypoints, nil
+}
+
+// section returns the argument of a section of the code of the script
+func (s Script) section(name string) (Micheline, error) {
+	for _, section := range s.Code.Seq {
+		if section.Kind == MichelinePrim && section.Prim == name && len(section.Args) == 1 {
+			return section.Args[0], nil
+		}
+	}
+	return Micheline{}, errors.Errorf("script has no %s section", name)
+}
+
+// getContractField gets a field of the state of a contract at the block id and unmarshals it into out
+func (s *ContractService) getContractField(contract string, id interface{}, field string, out interface{}) error {
+	query, err := contractQuery(contract, id)
+	if err != nil {
+		return err
+	}
+	query = query + "/" + field
+
+	resp, err := s.gt.Get(query, nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not get '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, out)
+	if err != nil {
+		return errors.Wrapf(err, "could not get '%s'", query)
+	}
+
+	return nil
+}
+
+// contractQuery returns the RPC path of a contract at the block id
+func contractQuery(contract string, id interface{}) (string, error) {
+	query, err := blockQuery(id)
+	if err != nil {
+		return "", err
 	}
-	return resp, nil
+	return query + "/context/contracts/" + contract, nil
 }
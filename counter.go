@@ -0,0 +1,303 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// CounterManager hands out the counters of manager operations for each source, so operations sent concurrently
+// from the same source do not collide. Counters are synced with the chain and the operations of the source in the
+// node's mempool the first time they are used, and resynced after an operation is rejected, a reservation is
+// released, an operation in flight expires or the source is invalidated. Operations in flight hold their counters
+// until then, whether they were injected yet or not.
+type CounterManager struct {
+	gt      *GoTezos
+	mu      sync.Mutex
+	sources map[string]*sourceCounter
+	hashes  map[string]string
+}
+
+// TrackedOperation is a signed operation using the counters First to Last of a reservation
+type TrackedOperation struct {
+	Hash  string
+	First int
+	Last  int
+}
+
+// sourceCounter is the state of the counters of a single source
+type sourceCounter struct {
+	mu           sync.Mutex
+	synced       bool
+	next         int
+	reservations int
+	inFlight     map[string]inFlightOperation
+}
+
+// inFlightOperation is an operation of a source tracked by the CounterManager, which can no longer be included
+// after its expiry level
+type inFlightOperation struct {
+	first       int
+	last        int
+	expiryLevel int
+}
+
+// newCounterManager returns a new CounterManager
+func newCounterManager(gt *GoTezos) *CounterManager {
+	return &CounterManager{
+		gt:      gt,
+		sources: make(map[string]*sourceCounter),
+		hashes:  make(map[string]string),
+	}
+}
+
+// Next reserves n consecutive counters for an operation of source and returns the first one. The reservation
+// must be followed by Track once the operation is signed, or by Release if it is abandoned.
+func (c *CounterManager) Next(source string, n int) (int, error) {
+	sc := c.source(source)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if err := c.resync(source, sc); err != nil {
+		return 0, errors.Wrapf(err, "could not get next counter for %s", source)
+	}
+
+	first := sc.next
+	sc.next += n
+	sc.reservations++
+
+	return first, nil
+}
+
+// peek returns the counter the next reservation of source would start at, without reserving it
+func (c *CounterManager) peek(source string) (int, error) {
+	sc := c.source(source)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if err := c.resync(source, sc); err != nil {
+		return 0, errors.Wrapf(err, "could not get next counter for %s", source)
+	}
+
+	return sc.next, nil
+}
+
+// Track records the operations using the counters of a reservation of source as in flight. A reservation may be
+// split between several operations, which are all tracked at once. The operations hold their counters until they
+// are confirmed or rejected, or until branch is older than the max operations ttl.
+func (c *CounterManager) Track(source string, branch Block, operations ...TrackedOperation) {
+	expiryLevel := branch.Header.Level + branch.Metadata.MaxOperationsTTL
+
+	sc := c.source(source)
+	sc.mu.Lock()
+	if sc.reservations > 0 {
+		sc.reservations--
+	}
+	for _, operation := range operations {
+		sc.inFlight[operation.Hash] = inFlightOperation{first: operation.First, last: operation.Last, expiryLevel: expiryLevel}
+	}
+	sc.mu.Unlock()
+
+	c.mu.Lock()
+	for _, operation := range operations {
+		c.hashes[operation.Hash] = source
+	}
+	c.mu.Unlock()
+}
+
+// Release abandons a reservation of source. The counters of source are resynced before the next reservation,
+// since later reservations may now be ahead of the chain.
+func (c *CounterManager) Release(source string) {
+	sc := c.source(source)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.reservations > 0 {
+		sc.reservations--
+	}
+	sc.synced = false
+}
+
+// Confirm marks a tracked operation as included in the chain
+func (c *CounterManager) Confirm(hash string) {
+	source, ok := c.forget(hash)
+	if !ok {
+		return
+	}
+
+	sc := c.source(source)
+	sc.mu.Lock()
+	delete(sc.inFlight, hash)
+	sc.mu.Unlock()
+}
+
+// Reject marks a tracked operation as refused or expired, so the counters of its source are resynced
+func (c *CounterManager) Reject(hash string) {
+	source, ok := c.forget(hash)
+	if !ok {
+		return
+	}
+
+	sc := c.source(source)
+	sc.mu.Lock()
+	delete(sc.inFlight, hash)
+	sc.synced = false
+	sc.mu.Unlock()
+}
+
+// Invalidate forces the counters of source to be resynced before the next reservation
+func (c *CounterManager) Invalidate(source string) {
+	sc := c.source(source)
+	sc.mu.Lock()
+	sc.synced = false
+	sc.mu.Unlock()
+}
+
+// source returns the counter state of source, creating it if needed
+func (c *CounterManager) source(source string) *sourceCounter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sc, ok := c.sources[source]
+	if !ok {
+		sc = &sourceCounter{inFlight: make(map[string]inFlightOperation)}
+		c.sources[source] = sc
+	}
+	return sc
+}
+
+// forget stops tracking an operation hash and returns its source
+func (c *CounterManager) forget(hash string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	source, ok := c.hashes[hash]
+	delete(c.hashes, hash)
+	return source, ok
+}
+
+// expire stops tracking the operations in flight of a source that can no longer be included, and resyncs its
+// counters if there were any. The lock of sc must be held.
+func (c *CounterManager) expire(sc *sourceCounter) error {
+	if len(sc.inFlight) == 0 {
+		return nil
+	}
+
+	level, err := c.headLevel()
+	if err != nil {
+		return err
+	}
+
+	for hash, operation := range sc.inFlight {
+		if level > operation.expiryLevel {
+			delete(sc.inFlight, hash)
+			c.forget(hash)
+			sc.synced = false
+		}
+	}
+	return nil
+}
+
+// resync syncs the counters of a source if they are not synced or an operation in flight expired. The lock of sc
+// must be held.
+func (c *CounterManager) resync(source string, sc *sourceCounter) error {
+	if err := c.expire(sc); err != nil {
+		return err
+	}
+
+	if !sc.synced {
+		counter, err := c.sync(source)
+		if err != nil {
+			return err
+		}
+		sc.next = held(sc, counter) + 1
+		sc.synced = true
+	}
+
+	return nil
+}
+
+// held returns the highest of counter and the counters held by the operations in flight and the reservations of a
+// source. The lock of sc must be held.
+func held(sc *sourceCounter, counter int) int {
+	for _, operation := range sc.inFlight {
+		if operation.last > counter {
+			counter = operation.last
+		}
+	}
+	if sc.reservations > 0 && sc.next-1 > counter {
+		counter = sc.next - 1
+	}
+	return counter
+}
+
+// covers returns whether every counter of source between its counter on chain and counter is used by an operation
+// in flight, so an operation at counter is in the future of the chain only because of operations that come before it
+func (c *CounterManager) covers(source string, counter int) (bool, error) {
+	chain, err := c.gt.Operation.getAddressCounter(source)
+	if err != nil {
+		return false, err
+	}
+
+	sc := c.source(source)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if err := c.expire(sc); err != nil {
+		return false, err
+	}
+
+	for n := chain + 1; n < counter; n++ {
+		used := false
+		for _, operation := range sc.inFlight {
+			used = used || (operation.first <= n && n <= operation.last)
+		}
+		if !used {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sync returns the highest counter used by source, either on chain or by its operations in the mempool. The mempool
+// is read first, so an operation included in between is counted on chain.
+func (c *CounterManager) sync(source string) (int, error) {
+	mempool, err := c.gt.Mempool.GetPendingOperations()
+	if err != nil {
+		return 0, err
+	}
+
+	counter, err := c.gt.Operation.getAddressCounter(source)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := mempool.BySource(source)
+	for _, operation := range append(pending.Applied, pending.BranchDelayed...) {
+		for _, contents := range operation.Contents {
+			if manager, ok := managerOperation(contents); ok && manager.Source == source && manager.Counter > counter {
+				counter = manager.Counter
+			}
+		}
+	}
+
+	return counter, nil
+}
+
+// headLevel returns the level of the head block
+func (c *CounterManager) headLevel() (int, error) {
+	var header StructHeader
+	query := "/chains/main/blocks/head/header"
+	resp, err := c.gt.Get(query, nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get head header '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &header)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get head header '%s'", query)
+	}
+
+	return header.Level, nil
+}
@@ -2,6 +2,7 @@ package gotezos
 
 import (
 	"encoding/json"
+	"math/big"
 	"strconv"
 	"time"
 
@@ -16,9 +17,9 @@ type DelegateService struct {
 type delegationReportJob struct {
 	delegatePhk   string
 	delegationPhk string
-	Fee           float64
+	Fee           *big.Rat
 	cycle         int
-	cycleRewards  int
+	cycleRewards  Mutez
 }
 
 type delegationReportJobResult struct {
@@ -47,52 +48,52 @@ type DelegateReport struct {
 	DelegatePhk      string
 	Cycle            int
 	Delegations      []DelegationReport
-	CycleRewards     string
-	TotalFeeRewards  string
-	SelfBakedRewards string
-	TotalRewards     string
+	CycleRewards     Mutez
+	TotalFeeRewards  Mutez
+	SelfBakedRewards Mutez
+	TotalRewards     Mutez
 }
 
 // DelegationReport represents a rewards report for a delegation in DelegateReport
 type DelegationReport struct {
 	DelegationPhk string
-	Share         float64
-	GrossRewards  string
-	Fee           string
-	NetRewards    string
+	Share         *big.Rat
+	GrossRewards  Mutez
+	Fee           Mutez
+	NetRewards    Mutez
 }
 
 // Payment is a helper struct for transfers
 type Payment struct {
 	Address string
-	Amount  float64
+	Amount  Mutez
 }
 
 // Delegate is representation of a delegate on the Tezos Network
 type Delegate struct {
-	Balance              string                 `json:"balance"`
-	FrozenBalance        string                 `json:"frozen_balance"`
+	Balance              Mutez                  `json:"balance"`
+	FrozenBalance        Mutez                  `json:"frozen_balance"`
 	FrozenBalanceByCycle []frozenBalanceByCycle `json:"frozen_balance_by_cycle"`
-	StakingBalance       string                 `json:"staking_balance"`
+	StakingBalance       Mutez                  `json:"staking_balance"`
 	DelegateContracts    []string               `json:"delegated_contracts"`
-	DelegatedBalance     string                 `json:"delegated_balance"`
+	DelegatedBalance     Mutez                  `json:"delegated_balance"`
 	Deactivated          bool                   `json:"deactivated"`
 	GracePeriod          int                    `json:"grace_period"`
 }
 
 // FrozenBalanceByCycle a representation of frozen balance by cycle on the Tezos network
 type frozenBalanceByCycle struct {
-	Cycle   int    `json:"cycle"`
-	Deposit string `json:"deposit"`
-	Fees    string `json:"fees"`
-	Rewards string `json:"rewards"`
+	Cycle   int   `json:"cycle"`
+	Deposit Mutez `json:"deposit"`
+	Fees    Mutez `json:"fees"`
+	Rewards Mutez `json:"rewards"`
 }
 
 // FrozenBalanceRewards is a FrozenBalanceRewards query returned by the Tezos RPC API.
 type FrozenBalanceRewards struct {
-	Deposits string `json:"deposits"`
-	Fees     string `json:"fees"`
-	Rewards  string `json:"rewards"`
+	Deposits Mutez `json:"deposits"`
+	Fees     Mutez `json:"fees"`
+	Rewards  Mutez `json:"rewards"`
 }
 
 // NewDelegateService returns a new DelegateService
@@ -146,7 +147,7 @@ func (d *DelegateService) GetDelegationsAtCycle(delegatePhk string, cycle int) (
 // GetReport gets the total rewards for a delegate earned
 // and calculates the gross rewards earned by each delegation for a single cycle.
 // Also includes the share of each delegation.
-func (d *DelegateService) GetReport(delegatePhk string, cycle int, fee float64) (*DelegateReport, error) {
+func (d *DelegateService) GetReport(delegatePhk string, cycle int, fee *big.Rat) (*DelegateReport, error) {
 	report := DelegateReport{DelegatePhk: delegatePhk, Cycle: cycle}
 
 	cycleRewards, err := d.GetRewards(delegatePhk, cycle)
@@ -165,33 +166,41 @@ func (d *DelegateService) GetReport(delegatePhk string, cycle int, fee float64)
 		return &report, errors.Wrapf(err, "could not get delegate report for %s at cycle %d", delegatePhk, cycle)
 	}
 	report.Delegations = delegationReports
-	intRewards, _ := strconv.Atoi(cycleRewards)
-	selfBakeRewards := strconv.Itoa(intRewards - gross)
-	report.SelfBakedRewards = selfBakeRewards
-	intFeeRewards := int(float64(gross) * fee)
-	report.TotalFeeRewards = strconv.Itoa(intFeeRewards)
-	report.TotalRewards = strconv.Itoa(intFeeRewards + intRewards)
+
+	report.SelfBakedRewards, err = cycleRewards.Sub(gross)
+	if err != nil {
+		return &report, errors.Wrapf(err, "could not get delegate report for %s at cycle %d", delegatePhk, cycle)
+	}
+
+	report.TotalFeeRewards, err = gross.MulRate(fee)
+	if err != nil {
+		return &report, errors.Wrapf(err, "could not get delegate report for %s at cycle %d", delegatePhk, cycle)
+	}
+
+	report.TotalRewards, err = report.TotalFeeRewards.Add(cycleRewards)
+	if err != nil {
+		return &report, errors.Wrapf(err, "could not get delegate report for %s at cycle %d", delegatePhk, cycle)
+	}
 
 	return &report, nil
 }
 
 // GetPayments will convert a delegate report into payments for batch pay with a minimum requirement in mutez
-func (dr *DelegateReport) GetPayments(minimum int) []Payment {
+func (dr *DelegateReport) GetPayments(minimum Mutez) []Payment {
 	payments := []Payment{}
 	for _, delegate := range dr.Delegations {
 		payment := Payment{}
 		payment.Address = delegate.DelegationPhk
-		amount, _ := strconv.ParseFloat(delegate.NetRewards, 64)
-		payment.Amount = amount
+		payment.Amount = delegate.NetRewards
 
-		if payment.Amount >= float64(minimum) && payment.Amount != 0 {
+		if payment.Amount >= minimum && payment.Amount != 0 {
 			payments = append(payments, payment)
 		}
 	}
 	return payments
 }
 
-func (d *DelegateService) getDelegationReports(delegate string, delegations []string, cycle int, cycleRewards string, fee float64) ([]DelegationReport, int, error) {
+func (d *DelegateService) getDelegationReports(delegate string, delegations []string, cycle int, cycleRewards Mutez, fee *big.Rat) ([]DelegationReport, Mutez, error) {
 	reports := []DelegationReport{}
 
 	jobs := make(chan delegationReportJob, 1000)
@@ -201,25 +210,23 @@ func (d *DelegateService) getDelegationReports(delegate string, delegations []st
 		go d.delegationReportWorker(jobs, results)
 	}
 
-	bigIntCycleRewards, err := strconv.Atoi(cycleRewards)
-	if err != nil {
-		return reports, 0, errors.Wrap(err, "could not get delegation reports")
-	}
-
 	for _, delegation := range delegations {
-		job := delegationReportJob{delegatePhk: delegate, delegationPhk: delegation, Fee: fee, cycle: cycle, cycleRewards: bigIntCycleRewards}
+		job := delegationReportJob{delegatePhk: delegate, delegationPhk: delegation, Fee: fee, cycle: cycle, cycleRewards: cycleRewards}
 		jobs <- job
 	}
 
-	totalGross := 0
+	var totalGross Mutez
+	var err error
 	for i := 0; i < len(delegations); i++ {
 		result := <-results
 		if result.err != nil {
 			return reports, 0, result.err
 		}
 		reports = append(reports, result.report)
-		gross, _ := strconv.Atoi(result.report.GrossRewards)
-		totalGross = totalGross + gross
+		totalGross, err = totalGross.Add(result.report.GrossRewards)
+		if err != nil {
+			return reports, 0, errors.Wrap(err, "could not get delegation reports")
+		}
 	}
 	return reports, totalGross, nil
 }
@@ -227,69 +234,80 @@ func (d *DelegateService) getDelegationReports(delegate string, delegations []st
 func (d *DelegateService) delegationReportWorker(jobs <-chan delegationReportJob, results chan<- delegationReportJobResult) {
 	for j := range jobs {
 		result := delegationReportJobResult{}
-		report := DelegationReport{}
-		report.DelegationPhk = j.delegationPhk
-
-		share, _, err := d.getShareOfContract(j.delegatePhk, j.delegationPhk, j.cycle)
-		if err != nil {
-			result.err = err
-		}
-		report.Share = share
-		gross := share * float64(j.cycleRewards)
-		intGross := int(gross)
-		report.GrossRewards = strconv.Itoa(intGross)
-
-		fee := j.Fee * gross
-		intFee := int(fee)
-		report.Fee = strconv.Itoa(intFee)
-
-		intNetRewards := intGross - intFee
-		report.NetRewards = strconv.Itoa(intNetRewards)
-		result.report = report
+		result.report, result.err = d.getDelegationReport(j)
 		results <- result
 	}
 }
 
+// getDelegationReport computes the rewards of a delegation for a report job
+func (d *DelegateService) getDelegationReport(j delegationReportJob) (DelegationReport, error) {
+	report := DelegationReport{}
+	report.DelegationPhk = j.delegationPhk
+
+	delegationBalance, stakingBalance, err := d.getShareOfContract(j.delegatePhk, j.delegationPhk, j.cycle)
+	if err != nil {
+		return report, err
+	}
+
+	report.GrossRewards, err = j.cycleRewards.MulDiv(delegationBalance, stakingBalance)
+	if err != nil {
+		return report, errors.Wrapf(err, "could not get delegation report for %s", j.delegationPhk)
+	}
+	report.Share = big.NewRat(int64(delegationBalance), int64(stakingBalance))
+
+	report.Fee, err = report.GrossRewards.MulRate(j.Fee)
+	if err != nil {
+		return report, errors.Wrapf(err, "could not get delegation report for %s", j.delegationPhk)
+	}
+
+	report.NetRewards, err = report.GrossRewards.Sub(report.Fee)
+	if err != nil {
+		return report, errors.Wrapf(err, "could not get delegation report for %s", j.delegationPhk)
+	}
+
+	return report, nil
+}
+
 // GetRewards gets the rewards earned by a delegate for a specific cycle.
-func (d *DelegateService) GetRewards(delegatePhk string, cycle int) (string, error) {
+func (d *DelegateService) GetRewards(delegatePhk string, cycle int) (Mutez, error) {
 	rewards := FrozenBalanceRewards{}
 	level := (cycle+1)*(d.gt.Constants.BlocksPerCycle) + 1
 
 	head, err := d.gt.Block.Get(level)
 	if err != nil {
-		return "", errors.Wrapf(err, "could not get rewards for %s at %d cycle", delegatePhk, cycle)
+		return 0, errors.Wrapf(err, "could not get rewards for %s at %d cycle", delegatePhk, cycle)
 	}
 
 	query := "/chains/main/blocks/" + head.Hash + "/context/raw/json/contracts/index/" + delegatePhk + "/frozen_balance/" + strconv.Itoa(cycle) + "/"
 	resp, err := d.gt.Get(query, nil)
 	if err != nil {
-		return "", errors.Wrapf(err, "could not get rewards '%s'", query)
+		return 0, errors.Wrapf(err, "could not get rewards '%s'", query)
 	}
 	rewards, err = rewards.unmarshalJSON(resp)
 	if err != nil {
 		return rewards.Rewards, errors.Wrapf(err, "could not get rewards '%s'", query)
 	}
 
-	if rewards.Rewards == "" {
-		return rewards.Rewards, errors.Errorf("could not get rewards '%s', empty string", query)
-	}
-
 	return rewards.Rewards, nil
 }
 
-// getShareOfContract returns the share of a delegation for a specific cycle.
-func (d *DelegateService) getShareOfContract(delegatePhk, delegationPhk string, cycle int) (float64, float64, error) {
+// getShareOfContract returns the balance of a delegation and the staking balance of its delegate for a specific cycle.
+func (d *DelegateService) getShareOfContract(delegatePhk, delegationPhk string, cycle int) (Mutez, Mutez, error) {
 	stakingBalance, err := d.GetStakingBalance(delegatePhk, cycle)
 	if err != nil {
-		return 0, 0, errors.Wrap(err, "could not get share of contract %s")
+		return 0, 0, errors.Wrapf(err, "could not get share of contract %s", delegationPhk)
 	}
 
 	delegationBalance, err := d.gt.Account.GetBalanceAtSnapshot(delegationPhk, cycle)
 	if err != nil {
-		return 0, 0, errors.Wrap(err, "could not get share of contract %s")
+		return 0, 0, errors.Wrapf(err, "could not get share of contract %s", delegationPhk)
+	}
+
+	if stakingBalance == 0 {
+		return 0, 0, errors.Errorf("could not get share of contract %s, staking balance of %s is zero", delegationPhk, delegatePhk)
 	}
 
-	return delegationBalance / stakingBalance, delegationBalance, nil
+	return delegationBalance, stakingBalance, nil
 }
 
 // GetDelegate retrieves information about a delegate at the head block
@@ -309,8 +327,8 @@ func (d *DelegateService) GetDelegate(delegatePhk string) (Delegate, error) {
 }
 
 // GetStakingBalanceAtCycle gets the staking balance of a delegate at a specific cycle
-func (d *DelegateService) GetStakingBalanceAtCycle(delegateAddr string, cycle int) (string, error) {
-	balance := ""
+func (d *DelegateService) GetStakingBalanceAtCycle(delegateAddr string, cycle int) (Mutez, error) {
+	var balance Mutez
 	snapShot, err := d.gt.SnapShot.Get(cycle)
 	if err != nil {
 		return balance, errors.Wrapf(err, "could not get staking balance for %s at cycle %d", delegateAddr, cycle)
@@ -320,7 +338,7 @@ func (d *DelegateService) GetStakingBalanceAtCycle(delegateAddr string, cycle in
 	if err != nil {
 		return balance, errors.Wrapf(err, "could not get staking balance '%s'", query)
 	}
-	balance, err = unmarshalString(resp)
+	balance, err = unmarshalMutez(resp)
 	if err != nil {
 		return balance, errors.Wrapf(err, "could not get staking balance '%s'", query)
 	}
@@ -466,7 +484,7 @@ func (d *DelegateService) GetAllDelegates() ([]string, error) {
 }
 
 // GetStakingBalance gets the staking balance for a delegate at a specific snapshot for a cycle.
-func (d *DelegateService) GetStakingBalance(delegateAddr string, cycle int) (float64, error) {
+func (d *DelegateService) GetStakingBalance(delegateAddr string, cycle int) (Mutez, error) {
 
 	snapShot, err := d.gt.SnapShot.Get(cycle)
 	if err != nil {
@@ -485,17 +503,12 @@ func (d *DelegateService) GetStakingBalance(delegateAddr string, cycle int) (flo
 		return 0, errors.Wrapf(err, "could not get staking balance '%s'", query)
 	}
 
-	strBalance, err := unmarshalString(resp)
+	balance, err := unmarshalMutez(resp)
 	if err != nil {
 		return 0, errors.Wrapf(err, "could not get staking balance '%s'", query)
 	}
 
-	floatBalance, err := strconv.ParseFloat(strBalance, 64) //TODO error checking
-	if err != nil {
-		return 0, errors.Wrapf(err, "could not get staking balance '%s'", query)
-	}
-
-	return floatBalance / MUTEZ, nil
+	return balance, nil
 }
 
 // unmarshalJSON unmarshalls bytes into StructDelegate
@@ -530,12 +543,19 @@ func (er *EndorsingRights) unmarshalJSON(v []byte) (EndorsingRights, error) {
 
 // UnmarshalJSON unmarshals the bytes received as a parameter, into the type SnapShotQuery.
 func (fb *FrozenBalanceRewards) unmarshalJSON(v []byte) (FrozenBalanceRewards, error) {
-	frozenBalance := FrozenBalanceRewards{}
+	var frozenBalance struct {
+		FrozenBalanceRewards
+		Rewards *Mutez `json:"rewards"`
+	}
 	err := json.Unmarshal(v, &frozenBalance)
 	if err != nil {
-		return frozenBalance, errors.Wrap(err, "could not unmarshal bytes into FrozenBalanceRewards")
+		return frozenBalance.FrozenBalanceRewards, errors.Wrap(err, "could not unmarshal bytes into FrozenBalanceRewards")
+	}
+	if frozenBalance.Rewards == nil {
+		return frozenBalance.FrozenBalanceRewards, errors.New("could not unmarshal bytes into FrozenBalanceRewards, empty rewards")
 	}
-	return frozenBalance, nil
+	frozenBalance.FrozenBalanceRewards.Rewards = *frozenBalance.Rewards
+	return frozenBalance.FrozenBalanceRewards, nil
 }
 
 // UnmarshalJSON unmarshals the bytes received as a parameter, into the type an array of strings.
@@ -0,0 +1,207 @@
+package gotezos
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UnsignedOperation is a forged operation to be signed on another machine, possibly offline. It is prepared by
+// PrepareOperation on a machine connected to a node, signed by SignOperation with nothing but a Signer, and
+// injected by InjectSignedOperation. The operation can not be included in a block after ExpiryLevel.
+type UnsignedOperation struct {
+	ChainID        string           `json:"chain_id"`
+	Branch         string           `json:"branch"`
+	Contents       []StructContents `json:"contents"`
+	OperationBytes string           `json:"operation_bytes"`
+	ExpiryLevel    int              `json:"expiry_level"`
+}
+
+// SignedOperation is an UnsignedOperation signed by SignOperation
+type SignedOperation struct {
+	UnsignedOperation
+	Signature     string `json:"signature"`
+	SignedBytes   string `json:"signed_bytes"`
+	OperationHash string `json:"operation_hash"`
+}
+
+// PrepareOperation forges the contents of an operation for offline signing. Contents must have their counters,
+// fees and limits set, and are forged on the head block when they have no branch. The bytes forged by the node
+// are checked against the contents by forging them locally.
+func (o *OperationService) PrepareOperation(contents Conts) (UnsignedOperation, error) {
+	var operation UnsignedOperation
+
+	head, err := o.gt.Block.GetHead()
+	if err != nil {
+		return operation, errors.Wrap(err, "could not prepare operation")
+	}
+
+	branch := head
+	if contents.Branch != "" && contents.Branch != head.Hash {
+		branch, err = o.gt.Block.Get(contents.Branch)
+		if err != nil {
+			return operation, errors.Wrap(err, "could not prepare operation")
+		}
+	}
+
+	operation.ChainID = head.ChainID
+	operation.Branch = branch.Hash
+	operation.ExpiryLevel = branch.Header.Level + head.Metadata.MaxOperationsTTL
+	for _, content := range contents.Contents {
+		content.Metadata = nil
+		operation.Contents = append(operation.Contents, content)
+	}
+
+	operation.OperationBytes, err = o.forgeOperation(Conts{Branch: operation.Branch, Contents: operation.Contents})
+	if err != nil {
+		return operation, errors.Wrap(err, "could not prepare operation")
+	}
+
+	err = verifyForgedOperation(operation)
+	if err != nil {
+		return operation, errors.Wrap(err, "could not prepare operation")
+	}
+
+	return operation, nil
+}
+
+// PrepareBatchPayment packs payments from source into batches like CreateBatchPaymentWithOptions, and prepares every
+// batch for offline signing. The batches use consecutive counters, so they must be injected in order, each one once
+// the previous one is included.
+func (o *OperationService) PrepareBatchPayment(payments []Payment, source string, opts BatchOptions) ([]UnsignedOperation, error) {
+	var operations []UnsignedOperation
+
+	opts, err := o.batchOptions(opts)
+	if err != nil {
+		return operations, errors.Wrap(err, "could not prepare batch payment")
+	}
+
+	blockHead, err := o.gt.Block.GetHead()
+	if err != nil {
+		return operations, errors.Wrap(err, "could not prepare batch payment")
+	}
+
+	counter, err := o.Counter.peek(source)
+	if err != nil {
+		return operations, errors.Wrap(err, "could not prepare batch payment")
+	}
+
+	batches := o.planBatches(blockHead.Hash, counter, source, payments, opts)
+
+	for _, batch := range batches {
+		if batch.err != nil {
+			return operations, errors.Wrap(batch.err, "could not prepare batch payment")
+		}
+		if len(batch.contents.Contents) == 0 {
+			continue
+		}
+
+		operation, err := o.PrepareOperation(batch.contents)
+		if err != nil {
+			return operations, errors.Wrap(err, "could not prepare batch payment")
+		}
+		operations = append(operations, operation)
+	}
+
+	return operations, nil
+}
+
+// SignOperation signs an operation prepared by PrepareOperation, without a node. The operation is forged again
+// locally so the signer only signs the contents it was shown, and every content must have the signer as source.
+func SignOperation(operation UnsignedOperation, signer Signer) (SignedOperation, error) {
+	signed := SignedOperation{UnsignedOperation: operation}
+
+	err := verifyForgedOperation(operation)
+	if err != nil {
+		return signed, errors.Wrap(err, "could not sign operation")
+	}
+
+	for k, content := range operation.Contents {
+		if content.Source != signer.PublicKeyHash() {
+			return signed, errors.Errorf("could not sign operation, source %s of content %d is not signer %s", content.Source, k, signer.PublicKeyHash())
+		}
+	}
+
+	signed.Signature, signed.SignedBytes, err = signOperation(operation.OperationBytes, signer)
+	if err != nil {
+		return signed, errors.Wrap(err, "could not sign operation")
+	}
+
+	signed.OperationHash, err = operationHash(signed.SignedBytes)
+	if err != nil {
+		return signed, errors.Wrap(err, "could not sign operation")
+	}
+
+	return signed, nil
+}
+
+// InjectSignedOperation preapplies and injects an operation signed by SignOperation, and returns its hash. The
+// operation must be for the chain of the node and must not have expired.
+func (o *OperationService) InjectSignedOperation(signed SignedOperation) (string, error) {
+	head, err := o.gt.Block.GetHead()
+	if err != nil {
+		return "", errors.Wrap(err, "could not inject signed operation")
+	}
+
+	if signed.ChainID != head.ChainID {
+		return "", errors.Errorf("could not inject signed operation, it is for chain %s but the node is on chain %s", signed.ChainID, head.ChainID)
+	}
+	if head.Header.Level > signed.ExpiryLevel {
+		return "", errors.Errorf("could not inject signed operation, it expired at level %d", signed.ExpiryLevel)
+	}
+
+	signature, err := signatureBytes(signed.Signature)
+	if err != nil {
+		return "", errors.Wrap(err, "could not inject signed operation")
+	}
+	if !strings.EqualFold(signed.SignedBytes, signed.OperationBytes+hex.EncodeToString(signature)) {
+		return "", errors.New("could not inject signed operation, signed bytes do not match the operation and its signature")
+	}
+
+	opHash, err := operationHash(signed.SignedBytes)
+	if err != nil {
+		return "", errors.Wrap(err, "could not inject signed operation")
+	}
+
+	contents := Conts{Branch: signed.Branch, Contents: signed.Contents}
+	preapplied, err := o.preApplyOperations(contents, signed.Signature, Block{Hash: signed.Branch, Protocol: head.Protocol})
+	if err != nil {
+		return "", errors.Wrap(err, "could not inject signed operation")
+	}
+	if len(preapplied) != 1 || len(preapplied[0].Contents) != len(signed.Contents) {
+		return "", errors.New("could not inject signed operation, unexpected preapply response")
+	}
+	for _, content := range preapplied[0].Contents {
+		if _, err := appliedResult(content); err != nil {
+			return "", errors.Wrap(err, "could not inject signed operation")
+		}
+	}
+
+	resp, err := o.InjectOperation(signed.SignedBytes)
+	if err != nil {
+		return "", errors.Wrap(err, "could not inject signed operation")
+	}
+
+	injectedHash, err := unmarshalString(resp)
+	if err != nil {
+		return "", errors.Wrap(err, "could not inject signed operation")
+	}
+	if injectedHash != opHash {
+		return "", errors.Errorf("could not inject signed operation, injected operation hash '%s' does not match computed hash '%s'", injectedHash, opHash)
+	}
+
+	return opHash, nil
+}
+
+// verifyForgedOperation checks that the forged bytes of an operation are the bytes of its branch and contents
+func verifyForgedOperation(operation UnsignedOperation) error {
+	forged, err := forgeOperationLocally(Conts{Branch: operation.Branch, Contents: operation.Contents})
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(forged, operation.OperationBytes) {
+		return errors.New("forged bytes do not match the contents of the operation")
+	}
+	return nil
+}
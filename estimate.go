@@ -0,0 +1,225 @@
+package gotezos
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Fee added on top of the minimal fee of each content
+	feeSafetyMargin Mutez = 10
+)
+
+// OperationEstimate is the gas, storage and fee needed by a content of an operation, as simulated by Estimate.
+type OperationEstimate struct {
+	ConsumedGas          int
+	GasLimit             int
+	PaidStorageSizeDiff  int
+	AllocatedDestination bool
+	StorageLimit         int
+	Fee                  Mutez
+	Burn                 Mutez
+}
+
+// Estimate simulates the contents of an operation with the node and returns the gas, storage and fee each
+// content needs. Gas limits include a safety margin, storage limits cover the paid storage and any allocated
+// or originated contracts, and fees are the minimal fees accepted by the node's mempool plus a safety margin.
+// The fee, gas and storage limit of the contents passed in are ignored. Their counters are simulated from the
+// current counter of the source so contents queued behind pending operations can still be estimated, and fees are
+// computed with the counters of the contents, such as counters reserved with the CounterManager, when they all
+// have one.
+func (o *OperationService) Estimate(contents Conts) ([]OperationEstimate, error) {
+	if len(contents.Contents) == 0 {
+		return []OperationEstimate{}, nil
+	}
+
+	simulation, simulated, err := o.simulate(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not estimate operation")
+	}
+
+	estimates, err := o.estimatesFromSimulation(simulated)
+	if err != nil {
+		return estimates, errors.Wrap(err, "could not estimate operation")
+	}
+
+	// Forge the contents with the counters they will be injected with, the counter size is part of the fee
+	if !hasCounters(contents) {
+		contents = simulation
+	}
+	estimates, err = o.estimateFees(contents, estimates)
+	if err != nil {
+		return estimates, errors.Wrap(err, "could not estimate operation")
+	}
+
+	return estimates, nil
+}
+
+// simulate runs the contents of an operation with the node without fees and with the highest limits a batch of
+// its size can use, and returns the contents as simulated
+func (o *OperationService) simulate(contents Conts) (Conts, Operation, error) {
+	hardGasLimit, err := strconv.Atoi(o.gt.Constants.HardGasLimitPerOperation)
+	if err != nil {
+		return Conts{}, Operation{}, errors.Wrap(err, "invalid hard gas limit per operation constant")
+	}
+	hardGasLimitPerBlock, err := strconv.Atoi(o.gt.Constants.HardGasLimitPerBlock)
+	if err != nil {
+		return Conts{}, Operation{}, errors.Wrap(err, "invalid hard gas limit per block constant")
+	}
+
+	// The gas limits of a batch must fit in a block
+	simulationGasLimit := hardGasLimit
+	if hardGasLimitPerBlock/len(contents.Contents) < simulationGasLimit {
+		simulationGasLimit = hardGasLimitPerBlock / len(contents.Contents)
+	}
+
+	simulation := Conts{Branch: contents.Branch, Contents: make([]StructContents, len(contents.Contents))}
+	for i, content := range contents.Contents {
+		content.Fee = 0
+		content.GasLimit = strconv.Itoa(simulationGasLimit)
+		content.StorageLimit = o.gt.Constants.HardStorageLimitPerOperation
+		simulation.Contents[i] = content
+	}
+
+	return o.simulateAtCounter(simulation)
+}
+
+// simulateAtCounter runs the contents of an operation with the node, numbering their counters from the current
+// counter of the source, and returns the renumbered contents
+func (o *OperationService) simulateAtCounter(contents Conts) (Conts, Operation, error) {
+	counter, err := o.getAddressCounter(contents.Contents[0].Source)
+	if err != nil {
+		return Conts{}, Operation{}, err
+	}
+
+	simulation := renumberContents(contents, counter+1)
+	for i := range simulation.Contents {
+		simulation.Contents[i].Metadata = nil
+	}
+
+	simulated, err := o.runOperation(simulation)
+	return simulation, simulated, err
+}
+
+// hasCounters returns whether every content of an operation has a counter
+func hasCounters(contents Conts) bool {
+	for _, content := range contents.Contents {
+		if content.Counter == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// estimatesFromSimulation computes the gas and storage limits of simulated contents, without their fees
+func (o *OperationService) estimatesFromSimulation(simulated Operation) ([]OperationEstimate, error) {
+	estimates := make([]OperationEstimate, len(simulated.Contents))
+
+	hardGasLimit, err := strconv.Atoi(o.gt.Constants.HardGasLimitPerOperation)
+	if err != nil {
+		return estimates, errors.Wrap(err, "invalid hard gas limit per operation constant")
+	}
+
+	for i, content := range simulated.Contents {
+		result, err := appliedResult(content)
+		if err != nil {
+			return estimates, errors.Wrapf(err, "content %d was not applied", i)
+		}
+
+		results := []operationResult{result}
+		_, internal, _ := contentsResult(content)
+		for _, operation := range internal {
+			if r, ok := internalResult(operation); ok {
+				results = append(results, r)
+			}
+		}
+
+		estimate := OperationEstimate{}
+		originated := 0
+		for _, r := range results {
+			estimate.ConsumedGas += r.ConsumedGas
+			estimate.PaidStorageSizeDiff += r.PaidStorageSizeDiff
+			estimate.AllocatedDestination = estimate.AllocatedDestination || r.AllocatedDestinationContract
+			originated += len(r.OriginatedContracts)
+		}
+
+		estimate.GasLimit = estimate.ConsumedGas + gasSafetyMargin
+		if estimate.GasLimit > hardGasLimit {
+			estimate.GasLimit = hardGasLimit
+		}
+
+		estimate.StorageLimit = estimate.PaidStorageSizeDiff + originated*o.gt.Constants.OriginationSize
+		if estimate.AllocatedDestination {
+			estimate.StorageLimit += o.gt.Constants.OriginationSize
+		}
+		estimate.Burn, err = o.gt.Constants.CostPerByte.Mul(int64(estimate.StorageLimit))
+		if err != nil {
+			return estimates, err
+		}
+
+		estimates[i] = estimate
+	}
+
+	return estimates, nil
+}
+
+// applyEstimates sets the fee, gas limit and storage limit of contents from their estimates
+func applyEstimates(contents Conts, estimates []OperationEstimate) Conts {
+	applied := Conts{Branch: contents.Branch, Contents: make([]StructContents, len(contents.Contents))}
+	for i, content := range contents.Contents {
+		content.Fee = estimates[i].Fee
+		content.GasLimit = strconv.Itoa(estimates[i].GasLimit)
+		content.StorageLimit = strconv.Itoa(estimates[i].StorageLimit)
+		applied.Contents[i] = content
+	}
+	return applied
+}
+
+// estimateFees computes the minimal fee of each content from the mempool filter of the node. The filter
+// compares the total fee of an operation to the total of its gas limits and its size, so the size of the
+// operation is shared between its contents and the minimal fee is charged once.
+func (o *OperationService) estimateFees(contents Conts, estimates []OperationEstimate) ([]OperationEstimate, error) {
+	filter, err := o.gt.Mempool.GetFilter()
+	if err != nil {
+		return estimates, err
+	}
+
+	minimalFees := int64(filter.MinimalFees)
+	perGasUnit, err := strconv.ParseInt(filter.MinimalNanotezPerGasUnit, 10, 64)
+	if err != nil {
+		return estimates, errors.Wrap(err, "invalid minimal nanotez per gas unit in mempool filter")
+	}
+	perByte, err := strconv.ParseInt(filter.MinimalNanotezPerByte, 10, 64)
+	if err != nil {
+		return estimates, errors.Wrap(err, "invalid minimal nanotez per byte in mempool filter")
+	}
+
+	n := int64(len(estimates))
+	size := int64(0)
+
+	// Fees change the size of the forged operation, so recompute them until the size is stable
+	for i := 0; i < 3; i++ {
+		forged, err := o.forgeOperation(applyEstimates(contents, estimates))
+		if err != nil {
+			return estimates, err
+		}
+
+		// The signature is appended to the forged bytes
+		forgedSize := int64(len(forged)/2 + 64)
+		if forgedSize == size {
+			break
+		}
+		size = forgedSize
+
+		for k := range estimates {
+			nanotez := perGasUnit*int64(estimates[k].GasLimit) + perByte*(size/n)
+			if k == 0 {
+				nanotez += minimalFees*1000 + perByte*(size%n)
+			}
+			estimates[k].Fee = Mutez((nanotez+999)/1000) + feeSafetyMargin
+		}
+	}
+
+	return estimates, nil
+}
@@ -0,0 +1,359 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// FA12Service is a client of FA1.2 token contracts
+type FA12Service struct {
+	gt *GoTezos
+}
+
+// TokenPayment is an amount of tokens to pay to an address
+type TokenPayment struct {
+	Address string
+	Amount  *big.Int
+}
+
+// newFA12Service returns a new FA12Service
+func (gt *GoTezos) newFA12Service() *FA12Service {
+	return &FA12Service{gt: gt}
+}
+
+// GetBalance gets the balance of owner in a token at the block id from the ledger big map of the token contract.
+// The ledger is the big map annotated %ledger or %balances, or else the only big map keyed by address, and its
+// values are either the balance or a pair holding a %balance nat.
+func (s *FA12Service) GetBalance(token, owner string, id interface{}) (*big.Int, error) {
+	ledger, err := s.ledger(token, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balance of %s in token %s", owner, token)
+	}
+
+	value, ok, err := s.gt.Contract.GetBigMapValue(ledger, NewMichelineString(owner), id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balance of %s in token %s", owner, token)
+	}
+	if !ok {
+		return big.NewInt(0), nil
+	}
+
+	balance, _, err := fa12Account(value, ledger.ValueType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balance of %s in token %s", owner, token)
+	}
+
+	return balance, nil
+}
+
+// GetAllowance gets the amount of tokens of owner that spender may transfer at the block id. Allowances are looked
+// up in the big map annotated %allowances keyed by the pair of owner and spender, or else in the map of approvals
+// held next to the balance of owner in the ledger.
+func (s *FA12Service) GetAllowance(token, owner, spender string, id interface{}) (*big.Int, error) {
+	bigMaps, err := s.gt.Contract.GetBigMaps(token, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get allowance of %s for %s in token %s", spender, owner, token)
+	}
+
+	for _, bigMap := range bigMaps {
+		if !hasFieldAnnot(bigMap.Annots, "allowances") {
+			continue
+		}
+		key := NewMichelinePrim("Pair", []Micheline{NewMichelineString(owner), NewMichelineString(spender)})
+		value, ok, err := s.gt.Contract.GetBigMapValue(bigMap, key, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get allowance of %s for %s in token %s", spender, owner, token)
+		}
+		if !ok || value.Kind != MichelineInt {
+			return big.NewInt(0), nil
+		}
+		return value.Int, nil
+	}
+
+	ledger, err := findLedger(bigMaps)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get allowance of %s for %s in token %s", spender, owner, token)
+	}
+
+	value, ok, err := s.gt.Contract.GetBigMapValue(ledger, NewMichelineString(owner), id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get allowance of %s for %s in token %s", spender, owner, token)
+	}
+	if !ok {
+		return big.NewInt(0), nil
+	}
+
+	_, approvals, err := fa12Account(value, ledger.ValueType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get allowance of %s for %s in token %s", spender, owner, token)
+	}
+	if approvals == nil {
+		return nil, errors.Errorf("could not get allowance of %s for %s in token %s, ledger has no approvals", spender, owner, token)
+	}
+
+	allowance, ok := approvals[spender]
+	if !ok {
+		return big.NewInt(0), nil
+	}
+	return allowance, nil
+}
+
+// ViewBalance gets the balance of owner in a token by running the getBalance view of the token contract with the
+// node. The view calls back callback, which must be a contract taking a nat.
+func (s *FA12Service) ViewBalance(token, owner, callback string) (*big.Int, error) {
+	input := NewMichelinePrim("Pair", []Micheline{NewMichelineString(owner), NewMichelineString(callback)})
+	balance, err := s.gt.Contract.viewNat(token, "getBalance", input, callback)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not view balance of %s in token %s", owner, token)
+	}
+	return balance, nil
+}
+
+// ViewAllowance gets the amount of tokens of owner that spender may transfer by running the getAllowance view of the
+// token contract with the node. The view calls back callback, which must be a contract taking a nat.
+func (s *FA12Service) ViewAllowance(token, owner, spender, callback string) (*big.Int, error) {
+	input := NewMichelinePrim("Pair", []Micheline{
+		NewMichelinePrim("Pair", []Micheline{NewMichelineString(owner), NewMichelineString(spender)}),
+		NewMichelineString(callback),
+	})
+	allowance, err := s.gt.Contract.viewNat(token, "getAllowance", input, callback)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not view allowance of %s for %s in token %s", spender, owner, token)
+	}
+	return allowance, nil
+}
+
+// ViewTotalSupply gets the total supply of a token by running the getTotalSupply view of the token contract with
+// the node. The view calls back callback, which must be a contract taking a nat.
+func (s *FA12Service) ViewTotalSupply(token, callback string) (*big.Int, error) {
+	input := NewMichelinePrim("Pair", []Micheline{NewMichelinePrim("Unit", nil), NewMichelineString(callback)})
+	supply, err := s.gt.Contract.viewNat(token, "getTotalSupply", input, callback)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not view total supply of token %s", token)
+	}
+	return supply, nil
+}
+
+// Transfer transfers an amount of tokens from an address to another with the transfer entrypoint of the token
+// contract. Tokens of an address other than the wallet need an allowance for the wallet.
+func (s *FA12Service) Transfer(token, from, to string, amount *big.Int, wallet Wallet) (ContractCallResult, error) {
+	parameters, err := fa12TransferParameters(from, to, amount)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not transfer token %s", token)
+	}
+	call, err := fa12Call(token, "transfer", parameters)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not transfer token %s", token)
+	}
+
+	result, err := s.gt.Operation.Call(call, wallet)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not transfer token %s", token)
+	}
+	return result, nil
+}
+
+// Approve allows spender to transfer an amount of tokens of the wallet with the approve entrypoint of the token
+// contract. FA1.2 contracts refuse to change an allowance that is not zero to another amount that is not zero.
+func (s *FA12Service) Approve(token, spender string, amount *big.Int, wallet Wallet) (ContractCallResult, error) {
+	parameters, err := fa12ApproveParameters(spender, amount)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not approve %s for token %s", spender, token)
+	}
+	call, err := fa12Call(token, "approve", parameters)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not approve %s for token %s", spender, token)
+	}
+
+	result, err := s.gt.Operation.Call(call, wallet)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not approve %s for token %s", spender, token)
+	}
+	return result, nil
+}
+
+// CreateBatchPayment forges batches of token transfers from the wallet and returns them ready to inject to a Tezos
+// RPC, like OperationService.CreateBatchPaymentWithOptions. Payments without an amount are skipped.
+func (s *FA12Service) CreateBatchPayment(token string, payments []TokenPayment, wallet Wallet, opts BatchOptions) ([]string, error) {
+	var calls []ContractCall
+	for _, payment := range payments {
+		if payment.Amount == nil || payment.Amount.Sign() <= 0 {
+			continue
+		}
+		parameters, err := fa12TransferParameters(wallet.Address, payment.Address, payment.Amount)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not create batch payment of token %s", token)
+		}
+		call, err := fa12Call(token, "transfer", parameters)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not create batch payment of token %s", token)
+		}
+		calls = append(calls, call)
+	}
+
+	operations, err := s.gt.Operation.CreateBatchCall(calls, wallet, opts)
+	if err != nil {
+		return operations, errors.Wrapf(err, "could not create batch payment of token %s", token)
+	}
+	return operations, nil
+}
+
+// ledger finds the ledger big map of a token contract at the block id
+func (s *FA12Service) ledger(token string, id interface{}) (BigMap, error) {
+	bigMaps, err := s.gt.Contract.GetBigMaps(token, id)
+	if err != nil {
+		return BigMap{}, err
+	}
+	return findLedger(bigMaps)
+}
+
+// viewNat runs a view entrypoint of a contract with the node, and returns the nat the view passed to callback
+func (s *ContractService) viewNat(contract, entrypoint string, input Micheline, callback string) (*big.Int, error) {
+	value, err := s.runView(contract, entrypoint, input, callback)
+	if err != nil {
+		return nil, err
+	}
+	if value.Kind != MichelineInt {
+		return nil, errors.Errorf("callback %s was passed %s instead of a nat", callback, value.Michelson())
+	}
+	return value.Int, nil
+}
+
+// runView runs a view entrypoint of a contract with the node, and returns the value the view passed to callback
+func (s *ContractService) runView(contract, entrypoint string, input Micheline, callback string) (Micheline, error) {
+	script, err := s.GetScript(contract, "head")
+	if err != nil {
+		return Micheline{}, err
+	}
+
+	result, err := s.RunCode(RunCodeInput{
+		Script:     script.Code,
+		Storage:    script.Storage,
+		Input:      input,
+		Entrypoint: entrypoint,
+	})
+	if err != nil {
+		return Micheline{}, err
+	}
+
+	for _, operation := range result.Operations {
+		transaction, ok := operation.(*InternalTransaction)
+		if !ok || transaction.Destination != callback || transaction.Parameters == nil {
+			continue
+		}
+		var value Micheline
+		if err := json.Unmarshal(transaction.Parameters.Value, &value); err != nil {
+			return value, errors.Wrapf(err, "invalid parameters of callback %s", callback)
+		}
+		return value, nil
+	}
+
+	return Micheline{}, errors.Errorf("view %s did not call back %s", entrypoint, callback)
+}
+
+// findLedger returns the big map annotated %ledger or %balances, or else the only big map keyed by address
+func findLedger(bigMaps []BigMap) (BigMap, error) {
+	var byAddress []BigMap
+	for _, bigMap := range bigMaps {
+		if hasFieldAnnot(bigMap.Annots, "ledger") || hasFieldAnnot(bigMap.Annots, "balances") {
+			return bigMap, nil
+		}
+		if bigMap.KeyType.Prim == "address" {
+			byAddress = append(byAddress, bigMap)
+		}
+	}
+	if len(byAddress) != 1 {
+		return BigMap{}, errors.Errorf("found %d big maps keyed by address instead of a ledger", len(byAddress))
+	}
+	return byAddress[0], nil
+}
+
+// fa12Account returns the balance and approvals of a value of the ledger of an FA1.2 token. Approvals are nil when
+// the value holds no map of approvals.
+func fa12Account(value, typ Micheline) (*big.Int, map[string]*big.Int, error) {
+	if typ.Prim == "nat" && value.Kind == MichelineInt {
+		return value.Int, nil, nil
+	}
+
+	leaves, err := michelineLeaves(value, typ, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var balance *big.Int
+	var approvals map[string]*big.Int
+	for _, leaf := range leaves {
+		switch {
+		case leaf.typ.Prim == "nat" && leaf.value.Kind == MichelineInt:
+			if balance == nil || leaf.annot == "balance" {
+				balance = leaf.value.Int
+			}
+		case leaf.typ.Prim == "map" && len(leaf.typ.Args) == 2 && leaf.typ.Args[0].Prim == "address":
+			if approvals != nil && leaf.annot != "approvals" && leaf.annot != "allowances" {
+				continue
+			}
+			approvals = make(map[string]*big.Int)
+			if err := UnmarshalMicheline(leaf.value, leaf.typ, &approvals); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if balance == nil {
+		return nil, nil, errors.Errorf("ledger value %s has no balance", value.Michelson())
+	}
+
+	return balance, approvals, nil
+}
+
+// fa12TransferParameters returns the parameters of the transfer entrypoint of an FA1.2 token
+func fa12TransferParameters(from, to string, amount *big.Int) (Micheline, error) {
+	value, err := newMichelineNat("amount", amount)
+	if err != nil {
+		return Micheline{}, err
+	}
+	return NewMichelinePrim("Pair", []Micheline{
+		NewMichelineString(from),
+		NewMichelinePrim("Pair", []Micheline{NewMichelineString(to), value}),
+	}), nil
+}
+
+// fa12ApproveParameters returns the parameters of the approve entrypoint of an FA1.2 token
+func fa12ApproveParameters(spender string, amount *big.Int) (Micheline, error) {
+	value, err := newMichelineNat("amount", amount)
+	if err != nil {
+		return Micheline{}, err
+	}
+	return NewMichelinePrim("Pair", []Micheline{NewMichelineString(spender), value}), nil
+}
+
+// newMichelineNat returns the nat literal of a token parameter, refusing missing and negative values
+func newMichelineNat(name string, n *big.Int) (Micheline, error) {
+	if n == nil {
+		return Micheline{}, errors.Errorf("missing %s", name)
+	}
+	if n.Sign() < 0 {
+		return Micheline{}, errors.Errorf("negative %s %s", name, n)
+	}
+	return NewMichelineInt(n), nil
+}
+
+// fa12Call returns the call of an entrypoint of a token contract with parameters
+func fa12Call(token, entrypoint string, parameters Micheline) (ContractCall, error) {
+	v, err := json.Marshal(parameters)
+	if err != nil {
+		return ContractCall{}, err
+	}
+	return ContractCall{Destination: token, Entrypoint: entrypoint, Parameters: v}, nil
+}
+
+// hasFieldAnnot returns whether annots hold the field annotation %name
+func hasFieldAnnot(annots []string, name string) bool {
+	for _, annot := range annots {
+		if annot == "%"+name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// The parameter types of the FA2 entrypoints used by FA2Service, as laid out by the standard
+var fa2EntrypointTypes = map[string]string{
+	"transfer": `list (pair (address %from_) (list %txs (pair (address %to_) (pair (nat %token_id) (nat %amount)))))`,
+	"balance_of": `pair (list %requests (pair (address %owner) (nat %token_id)))
+		(contract %callback (list (pair (pair %request (address %owner) (nat %token_id)) (nat %balance))))`,
+	"update_operators": `list (or (pair %add_operator (address %owner) (pair (address %operator) (nat %token_id)))
+		(pair %remove_operator (address %owner) (pair (address %operator) (nat %token_id))))`,
+}
+
+// FA2Service is a client of FA2 multi-asset token contracts
+type FA2Service struct {
+	gt *GoTezos
+}
+
+// FA2Transfer is a transfer of tokens from an address to several destinations
+type FA2Transfer struct {
+	From string
+	Txs  []FA2Destination
+}
+
+// FA2Destination is an amount of a token sent to an address by an FA2Transfer
+type FA2Destination struct {
+	To      string
+	TokenID int
+	Amount  *big.Int
+}
+
+// FA2BalanceRequest is an owner and token whose balance is requested from balance_of
+type FA2BalanceRequest struct {
+	Owner   string
+	TokenID int
+}
+
+// FA2Balance is the balance of a request answered by balance_of
+type FA2Balance struct {
+	Request FA2BalanceRequest
+	Balance *big.Int
+}
+
+// FA2OperatorUpdate adds, or removes when Remove is set, an operator allowed to transfer a token of owner
+type FA2OperatorUpdate struct {
+	Owner    string
+	Operator string
+	TokenID  int
+	Remove   bool
+}
+
+// FA2TokenMetadata is the metadata of a token, with the raw bytes of each field of its info
+type FA2TokenMetadata struct {
+	TokenID int               `michelson:"token_id"`
+	Info    map[string][]byte `michelson:"token_info"`
+}
+
+// newFA2Service returns a new FA2Service
+func (gt *GoTezos) newFA2Service() *FA2Service {
+	return &FA2Service{gt: gt}
+}
+
+// BalanceOf gets the balances of owners in tokens by running the balance_of entrypoint of the token contract with
+// the node. The entrypoint calls back callback, which must be a contract taking the list of balances.
+func (s *FA2Service) BalanceOf(token string, requests []FA2BalanceRequest, callback string) ([]FA2Balance, error) {
+	typ, err := s.checkEntrypoint(token, "balance_of")
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balances in token %s", token)
+	}
+
+	parameters, err := fa2BalanceOfParameters(requests, callback)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balances in token %s", token)
+	}
+
+	value, err := s.gt.Contract.runView(token, "balance_of", parameters, callback)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balances in token %s", token)
+	}
+
+	// The type of the callback is the argument of the contract type of the entrypoint
+	var balances []FA2Balance
+	err = UnmarshalMicheline(value, typ.Args[1].Args[0], &balances)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get balances in token %s", token)
+	}
+
+	return balances, nil
+}
+
+// GetTokenMetadata gets the metadata of a token at the block id from the %token_metadata big map of the token
+// contract
+func (s *FA2Service) GetTokenMetadata(token string, tokenID int, id interface{}) (FA2TokenMetadata, error) {
+	var metadata FA2TokenMetadata
+
+	key, err := newMichelineNat("token id", big.NewInt(int64(tokenID)))
+	if err != nil {
+		return metadata, errors.Wrapf(err, "could not get metadata of token %d of %s", tokenID, token)
+	}
+
+	bigMaps, err := s.gt.Contract.GetBigMaps(token, id)
+	if err != nil {
+		return metadata, errors.Wrapf(err, "could not get metadata of token %d of %s", tokenID, token)
+	}
+
+	for _, bigMap := range bigMaps {
+		if !hasFieldAnnot(bigMap.Annots, "token_metadata") {
+			continue
+		}
+
+		value, ok, err := s.gt.Contract.GetBigMapValue(bigMap, key, id)
+		if err != nil {
+			return metadata, errors.Wrapf(err, "could not get metadata of token %d of %s", tokenID, token)
+		}
+		if !ok {
+			return metadata, errors.Errorf("could not get metadata of token %d of %s, unknown token", tokenID, token)
+		}
+
+		err = UnmarshalMicheline(value, bigMap.ValueType, &metadata)
+		if err != nil {
+			return metadata, errors.Wrapf(err, "could not get metadata of token %d of %s", tokenID, token)
+		}
+		return metadata, nil
+	}
+
+	return metadata, errors.Errorf("could not get metadata of token %d of %s, contract has no token_metadata big map", tokenID, token)
+}
+
+// Transfer sends tokens from the wallet, or from owners the wallet operates, to several destinations in a single
+// call of the transfer entrypoint of the token contract
+func (s *FA2Service) Transfer(token string, transfers []FA2Transfer, wallet Wallet) (ContractCallResult, error) {
+	parameters, err := fa2TransferParameters(transfers)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not transfer token %s", token)
+	}
+
+	result, err := s.call(token, "transfer", parameters, wallet)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not transfer token %s", token)
+	}
+	return result, nil
+}
+
+// UpdateOperators adds and removes operators of the tokens of the wallet with the update_operators entrypoint of
+// the token contract
+func (s *FA2Service) UpdateOperators(token string, updates []FA2OperatorUpdate, wallet Wallet) (ContractCallResult, error) {
+	parameters, err := fa2UpdateOperatorsParameters(updates)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not update operators of token %s", token)
+	}
+
+	result, err := s.call(token, "update_operators", parameters, wallet)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not update operators of token %s", token)
+	}
+	return result, nil
+}
+
+// call checks an entrypoint of a token contract and calls it with parameters
+func (s *FA2Service) call(token, entrypoint string, parameters Micheline, wallet Wallet) (ContractCallResult, error) {
+	if _, err := s.checkEntrypoint(token, entrypoint); err != nil {
+		return ContractCallResult{}, err
+	}
+
+	v, err := json.Marshal(parameters)
+	if err != nil {
+		return ContractCallResult{}, err
+	}
+
+	return s.gt.Operation.Call(ContractCall{Destination: token, Entrypoint: entrypoint, Parameters: v}, wallet)
+}
+
+// checkEntrypoint checks that the parameter type of an entrypoint of a token contract is the type of the standard,
+// and returns the type of the standard
+func (s *FA2Service) checkEntrypoint(token, entrypoint string) (Micheline, error) {
+	expected, err := ParseMichelson(fa2EntrypointTypes[entrypoint])
+	if err != nil {
+		return expected, err
+	}
+
+	entrypoints, err := s.gt.Contract.GetEntrypoints(token)
+	if err != nil {
+		return expected, err
+	}
+	raw, ok := entrypoints[entrypoint]
+	if !ok {
+		return expected, errors.Errorf("contract has no %s entrypoint", entrypoint)
+	}
+
+	var typ Micheline
+	if err := json.Unmarshal(raw, &typ); err != nil {
+		return expected, errors.Wrapf(err, "invalid type of entrypoint %s", entrypoint)
+	}
+	if !sameMichelineType(typ, expected) {
+		return expected, errors.Errorf("entrypoint %s takes %s instead of %s", entrypoint, typ.Michelson(), expected.Michelson())
+	}
+
+	return expected, nil
+}
+
+// fa2TransferParameters returns the parameters of the transfer entrypoint of an FA2 token
+func fa2TransferParameters(transfers []FA2Transfer) (Micheline, error) {
+	items := make([]Micheline, len(transfers))
+	for k, transfer := range transfers {
+		txs := make([]Micheline, len(transfer.Txs))
+		for i, tx := range transfer.Txs {
+			tokenID, err := newMichelineNat("token id", big.NewInt(int64(tx.TokenID)))
+			if err != nil {
+				return Micheline{}, errors.Wrapf(err, "invalid transfer to %s", tx.To)
+			}
+			amount, err := newMichelineNat("amount", tx.Amount)
+			if err != nil {
+				return Micheline{}, errors.Wrapf(err, "invalid transfer to %s", tx.To)
+			}
+			txs[i] = NewMichelinePrim("Pair", []Micheline{
+				NewMichelineString(tx.To),
+				NewMichelinePrim("Pair", []Micheline{tokenID, amount}),
+			})
+		}
+		items[k] = NewMichelinePrim("Pair", []Micheline{NewMichelineString(transfer.From), NewMichelineSeq(txs...)})
+	}
+	return NewMichelineSeq(items...), nil
+}
+
+// fa2BalanceOfParameters returns the parameters of the balance_of entrypoint of an FA2 token
+func fa2BalanceOfParameters(requests []FA2BalanceRequest, callback string) (Micheline, error) {
+	items := make([]Micheline, len(requests))
+	for k, request := range requests {
+		tokenID, err := newMichelineNat("token id", big.NewInt(int64(request.TokenID)))
+		if err != nil {
+			return Micheline{}, errors.Wrapf(err, "invalid balance request of %s", request.Owner)
+		}
+		items[k] = NewMichelinePrim("Pair", []Micheline{NewMichelineString(request.Owner), tokenID})
+	}
+	return NewMichelinePrim("Pair", []Micheline{NewMichelineSeq(items...), NewMichelineString(callback)}), nil
+}
+
+// fa2UpdateOperatorsParameters returns the parameters of the update_operators entrypoint of an FA2 token
+func fa2UpdateOperatorsParameters(updates []FA2OperatorUpdate) (Micheline, error) {
+	items := make([]Micheline, len(updates))
+	for k, update := range updates {
+		tokenID, err := newMichelineNat("token id", big.NewInt(int64(update.TokenID)))
+		if err != nil {
+			return Micheline{}, errors.Wrapf(err, "invalid update of operator %s", update.Operator)
+		}
+		operator := NewMichelinePrim("Pair", []Micheline{
+			NewMichelineString(update.Owner),
+			NewMichelinePrim("Pair", []Micheline{NewMichelineString(update.Operator), tokenID}),
+		})
+		branch := "Left"
+		if update.Remove {
+			branch = "Right"
+		}
+		items[k] = NewMichelinePrim(branch, []Micheline{operator})
+	}
+	return NewMichelineSeq(items...), nil
+}
+
+// sameMichelineType returns whether two types are the same, ignoring their annotations
+func sameMichelineType(a, b Micheline) bool {
+	if a.Kind != MichelinePrim || b.Kind != MichelinePrim {
+		return a.Kind == b.Kind && a.Michelson() == b.Michelson()
+	}
+	if a.Prim != b.Prim || len(a.Args) != len(b.Args) {
+		return false
+	}
+	for k := range a.Args {
+		if !sameMichelineType(a.Args[k], b.Args[k]) {
+			return false
+		}
+	}
+	return true
+}
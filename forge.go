@@ -0,0 +1,345 @@
+package gotezos
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/Messer4/base58check"
+	"github.com/pkg/errors"
+)
+
+var (
+	// For (de)constructing addresses, keys and hashes forged locally
+	tz2             = []byte{6, 161, 161}
+	tz3             = []byte{6, 161, 164}
+	sppk            = []byte{3, 254, 226, 86}
+	p2pk            = []byte{3, 178, 139, 127}
+	blockHashPrefix = []byte{1, 52}
+	protocolPrefix  = []byte{2, 170}
+
+	// Tags of the operations that can be forged locally
+	operationTags = map[string]byte{
+		"proposals":   5,
+		"ballot":      6,
+		"reveal":      107,
+		"transaction": 108,
+		"origination": 109,
+		"delegation":  110,
+	}
+
+	// Ballots in the order of their binary encoding
+	ballotTags = map[string]byte{
+		"yay":  0,
+		"nay":  1,
+		"pass": 2,
+	}
+
+	// Entrypoints with a dedicated tag, other entrypoints are forged by name
+	entrypointTags = map[string]byte{
+		"default":         0,
+		"root":            1,
+		"do":              2,
+		"set_delegate":    3,
+		"remove_delegate": 4,
+	}
+
+	// Michelson primitives in the order of their binary encoding
+	michelinePrims = []string{
+		"parameter", "storage", "code", "False", "Elt", "Left", "None", "Pair", "Right", "Some",
+		"True", "Unit", "PACK", "UNPACK", "BLAKE2B", "SHA256", "SHA512", "ABS", "ADD", "AMOUNT",
+		"AND", "BALANCE", "CAR", "CDR", "CHECK_SIGNATURE", "COMPARE", "CONCAT", "CONS", "CREATE_ACCOUNT", "CREATE_CONTRACT",
+		"IMPLICIT_ACCOUNT", "DIP", "DROP", "DUP", "EDIV", "EMPTY_MAP", "EMPTY_SET", "EQ", "EXEC", "FAILWITH",
+		"GE", "GET", "GT", "HASH_KEY", "IF", "IF_CONS", "IF_LEFT", "IF_NONE", "INT", "LAMBDA",
+		"LE", "LEFT", "LOOP", "LSL", "LSR", "LT", "MAP", "MEM", "MUL", "NEG",
+		"NEQ", "NIL", "NONE", "NOT", "NOW", "OR", "PAIR", "PUSH", "RIGHT", "SIZE",
+		"SOME", "SOURCE", "SENDER", "SELF", "STEPS_TO_QUOTA", "SUB", "SWAP", "TRANSFER_TOKENS", "SET_DELEGATE", "UNIT",
+		"UPDATE", "XOR", "ITER", "LOOP_LEFT", "ADDRESS", "CONTRACT", "ISNAT", "CAST", "RENAME", "bool",
+		"contract", "int", "key", "key_hash", "lambda", "list", "map", "big_map", "nat", "option",
+		"or", "pair", "set", "signature", "string", "bytes", "mutez", "timestamp", "unit", "operation",
+		"address", "SLICE", "DIG", "DUG", "EMPTY_BIG_MAP", "APPLY", "chain_id", "CHAIN_ID",
+	}
+)
+
+// forgeOperationLocally forges the manager and voting operations of contents without a node, and returns the
+// forged bytes as hex like forgeOperation
+func forgeOperationLocally(contents Conts) (string, error) {
+	forged, err := decodeBase58(contents.Branch, blockHashPrefix, 32)
+	if err != nil {
+		return "", errors.Wrap(err, "could not forge operation, invalid branch")
+	}
+
+	for k, content := range contents.Contents {
+		forgedContent, err := forgeContent(content)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not forge content %d of operation", k)
+		}
+		forged = append(forged, forgedContent...)
+	}
+
+	return hex.EncodeToString(forged), nil
+}
+
+// forgeContent forges a manager or voting operation
+func forgeContent(content StructContents) ([]byte, error) {
+	tag, ok := operationTags[content.Kind]
+	if !ok {
+		return nil, errors.Errorf("%s operations can not be forged locally", content.Kind)
+	}
+
+	source, err := forgePublicKeyHash(content.Source)
+	if err != nil {
+		return nil, err
+	}
+	forged := append([]byte{tag}, source...)
+
+	if content.Kind == "proposals" || content.Kind == "ballot" {
+		voting, err := forgeVotingContent(content)
+		if err != nil {
+			return nil, err
+		}
+		return append(forged, voting...), nil
+	}
+
+	for _, n := range []string{content.Fee.String(), content.Counter, content.GasLimit, content.StorageLimit} {
+		z, err := forgeNat(n)
+		if err != nil {
+			return nil, err
+		}
+		forged = append(forged, z...)
+	}
+
+	switch content.Kind {
+	case "reveal":
+		publicKey, err := forgePublicKey(content.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		forged = append(forged, publicKey...)
+
+	case "transaction":
+		amount, err := forgeNat(content.Amount.String())
+		if err != nil {
+			return nil, err
+		}
+		destination, err := forgeContractID(content.Destination)
+		if err != nil {
+			return nil, err
+		}
+		parameters, err := forgeParameters(content.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		forged = append(forged, amount...)
+		forged = append(forged, destination...)
+		forged = append(forged, parameters...)
+
+	case "origination":
+		balance, err := forgeNat(content.Balance.String())
+		if err != nil {
+			return nil, err
+		}
+		delegate, err := forgeOptionalPublicKeyHash(content.Delegate)
+		if err != nil {
+			return nil, err
+		}
+		if content.Script == nil {
+			return nil, errors.New("origination has no script")
+		}
+		code, err := forgeMicheline(content.Script.Code)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid code")
+		}
+		storage, err := forgeMicheline(content.Script.Storage)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid storage")
+		}
+		forged = append(forged, balance...)
+		forged = append(forged, delegate...)
+		forged = append(forged, forgeBytes(code)...)
+		forged = append(forged, forgeBytes(storage)...)
+
+	case "delegation":
+		delegate, err := forgeOptionalPublicKeyHash(content.Delegate)
+		if err != nil {
+			return nil, err
+		}
+		forged = append(forged, delegate...)
+	}
+
+	return forged, nil
+}
+
+// forgeVotingContent forges the period and votes of a proposals or ballot operation
+func forgeVotingContent(content StructContents) ([]byte, error) {
+	if content.Period == nil {
+		return nil, errors.Errorf("%s has no voting period", content.Kind)
+	}
+	forged := make([]byte, 4)
+	binary.BigEndian.PutUint32(forged, uint32(*content.Period))
+
+	if content.Kind == "proposals" {
+		var proposals []byte
+		for _, proposal := range content.Proposals {
+			hash, err := decodeBase58(proposal, protocolPrefix, 32)
+			if err != nil {
+				return nil, errors.Errorf("invalid protocol hash '%s'", proposal)
+			}
+			proposals = append(proposals, hash...)
+		}
+		return append(forged, forgeBytes(proposals)...), nil
+	}
+
+	proposal, err := decodeBase58(content.Proposal, protocolPrefix, 32)
+	if err != nil {
+		return nil, errors.Errorf("invalid protocol hash '%s'", content.Proposal)
+	}
+	ballot, ok := ballotTags[content.Ballot]
+	if !ok {
+		return nil, errors.Errorf("invalid ballot '%s'", content.Ballot)
+	}
+	forged = append(forged, proposal...)
+	return append(forged, ballot), nil
+}
+
+// forgeParameters forges the optional parameters of a transaction
+func forgeParameters(parameters *StructParameters) ([]byte, error) {
+	if parameters == nil {
+		return []byte{0}, nil
+	}
+
+	forged := []byte{255}
+	entrypoint := parameters.Entrypoint
+	if entrypoint == "" {
+		entrypoint = "default"
+	}
+	if tag, ok := entrypointTags[entrypoint]; ok {
+		forged = append(forged, tag)
+	} else {
+		if len(entrypoint) > 31 {
+			return nil, errors.Errorf("entrypoint '%s' is longer than 31 bytes", entrypoint)
+		}
+		forged = append(forged, 255, byte(len(entrypoint)))
+		forged = append(forged, entrypoint...)
+	}
+
+	value, err := forgeMicheline(parameters.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid parameters")
+	}
+
+	return append(forged, forgeBytes(value)...), nil
+}
+
+// forgePublicKeyHash forges an implicit account address
+func forgePublicKeyHash(address string) ([]byte, error) {
+	for tag, prefix := range [][]byte{tz1, tz2, tz3} {
+		if hash, err := decodeBase58(address, prefix, 20); err == nil {
+			return append([]byte{byte(tag)}, hash...), nil
+		}
+	}
+	return nil, errors.Errorf("invalid public key hash '%s'", address)
+}
+
+// forgeOptionalPublicKeyHash forges an implicit account address that may be empty
+func forgeOptionalPublicKeyHash(address string) ([]byte, error) {
+	if address == "" {
+		return []byte{0}, nil
+	}
+	hash, err := forgePublicKeyHash(address)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{255}, hash...), nil
+}
+
+// forgeContractID forges an implicit or originated account address
+func forgeContractID(address string) ([]byte, error) {
+	if strings.HasPrefix(address, "KT1") {
+		hash, err := decodeBase58(address, kt1, 20)
+		if err != nil {
+			return nil, errors.Errorf("invalid contract address '%s'", address)
+		}
+		forged := append([]byte{1}, hash...)
+		return append(forged, 0), nil
+	}
+
+	hash, err := forgePublicKeyHash(address)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{0}, hash...), nil
+}
+
+// forgePublicKey forges a public key
+func forgePublicKey(key string) ([]byte, error) {
+	prefixes := []struct {
+		prefix []byte
+		length int
+	}{{edpk, 32}, {sppk, 33}, {p2pk, 33}}
+
+	for tag, p := range prefixes {
+		if k, err := decodeBase58(key, p.prefix, p.length); err == nil {
+			return append([]byte{byte(tag)}, k...), nil
+		}
+	}
+	return nil, errors.Errorf("invalid public key '%s'", key)
+}
+
+// forgeNat forges a decimal natural number as a zarith number
+func forgeNat(n string) ([]byte, error) {
+	v, ok := new(big.Int).SetString(n, 10)
+	if !ok || v.Sign() < 0 {
+		return nil, errors.Errorf("invalid natural number '%s'", n)
+	}
+
+	var forged []byte
+	for {
+		b := byte(new(big.Int).And(v, big.NewInt(0x7f)).Int64())
+		v.Rsh(v, 7)
+		if v.Sign() == 0 {
+			return append(forged, b), nil
+		}
+		forged = append(forged, b|0x80)
+	}
+}
+
+// forgeInt forges a decimal integer as a signed zarith number, as used by Micheline
+func forgeInt(n string) ([]byte, error) {
+	v, ok := new(big.Int).SetString(n, 10)
+	if !ok {
+		return nil, errors.Errorf("invalid integer '%s'", n)
+	}
+	return forgeZarithInt(v), nil
+}
+
+// forgeBytes prefixes bytes with their length
+func forgeBytes(b []byte) []byte {
+	forged := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(forged, uint32(len(b)))
+	return append(forged, b...)
+}
+
+// forgeMicheline forges a Micheline expression in JSON to its binary encoding
+func forgeMicheline(expression json.RawMessage) ([]byte, error) {
+	var node Micheline
+	err := json.Unmarshal(expression, &node)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse micheline")
+	}
+	return encodeMicheline(node)
+}
+
+// decodeBase58 decodes a base58check string with prefix into a payload of length bytes
+func decodeBase58(s string, prefix []byte, length int) ([]byte, error) {
+	decoded, err := base58check.Decode(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode '%s'", s)
+	}
+	if len(decoded) != len(prefix)+length || string(decoded[:len(prefix)]) != string(prefix) {
+		return nil, errors.Errorf("could not decode '%s', unexpected prefix or length", s)
+	}
+	return decoded[len(prefix):], nil
+}
@@ -4,5 +4,6 @@ go 1.12
 
 require (
 	github.com/Messer4/base58check v0.0.0-20180328134002-7531a92ae9ba
+	github.com/pkg/errors v0.9.1
 	golang.org/x/crypto v0.0.0-20190426145343-a29dc8fdc734
 )
@@ -12,7 +12,7 @@ import (
 const MUTEZ = 1000000
 
 // GoTezos is the driver of the library, it inludes the several RPC services
-// like Block, SnapSHot, Cycle, Account, Delegate, Operations, Contract, and Network
+// like Block, SnapSHot, Cycle, Account, Delegate, Operations, Contract, Network, Voting, Mempool, FA12, FA2 and Multisig
 type GoTezos struct {
 	client    *client
 	Constants NetworkConstants
@@ -25,6 +25,11 @@ type GoTezos struct {
 	Operation *OperationService
 	Contract  *ContractService
 	Node      *NodeService
+	Voting    *VotingService
+	Mempool   *MempoolService
+	FA12      *FA12Service
+	FA2       *FA2Service
+	Multisig  *MultisigService
 }
 
 // ResponseRaw represents a raw RPC/HTTP response
@@ -53,6 +58,11 @@ func NewGoTezos(URL string) (*GoTezos, error) {
 	gt.Operation = gt.newOperationService()
 	gt.Contract = gt.newContractService()
 	gt.Node = gt.newNodeService()
+	gt.Voting = gt.newVotingService()
+	gt.Mempool = gt.newMempoolService()
+	gt.FA12 = gt.newFA12Service()
+	gt.FA2 = gt.newFA2Service()
+	gt.Multisig = gt.newMultisigService()
 
 	gt.client = newClient(URL)
 
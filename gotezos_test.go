@@ -1,8 +1,21 @@
 package gotezos
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ed25519"
 )
 
 func TestCreateWalletWithMnemonic(t *testing.T) {
@@ -315,7 +328,7 @@ func TestDelegateGetReport(t *testing.T) {
 		t.Errorf("could not connect to network")
 	}
 
-	report, err := gt.Delegate.GetReport("tz1T8UYSbVuRm6CdhjvwCfXsKXb4yL9ai9Q3", 172, 0.05)
+	report, err := gt.Delegate.GetReport("tz1T8UYSbVuRm6CdhjvwCfXsKXb4yL9ai9Q3", 172, big.NewRat(5, 100))
 	if err != nil {
 		t.Errorf("%s", err)
 	}
@@ -330,7 +343,7 @@ func TestGetPayments(t *testing.T) {
 		t.Errorf("could not connect to network")
 	}
 
-	report, err := gt.Delegate.GetReport("tz1T8UYSbVuRm6CdhjvwCfXsKXb4yL9ai9Q3", 172, 0.05)
+	report, err := gt.Delegate.GetReport("tz1T8UYSbVuRm6CdhjvwCfXsKXb4yL9ai9Q3", 172, big.NewRat(5, 100))
 	if err != nil {
 		t.Errorf("%s", err)
 	}
@@ -480,11 +493,1740 @@ func TestConnections(t *testing.T) {
 	t.Log(PrettyReport(c))
 }
 
-//Takes an interface v and returns a pretty json string.
-func PrettyReport(v interface{}) string {
-	b, err := json.MarshalIndent(v, "", "  ")
-	if err == nil {
-		return string(b)
+func TestGetEntrypoints(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
 	}
-	return ""
+
+	entrypoints, err := gt.Contract.GetEntrypoints("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn")
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	if _, ok := entrypoints["transfer"]; !ok {
+		t.Errorf("transfer entrypoint not found: %v", entrypoints)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	head, err := gt.Block.GetHead()
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	contents := Conts{
+		Branch: head.Hash,
+		Contents: []StructContents{
+			{Kind: "transaction", Source: "tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB", Amount: 1, Destination: "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1"},
+		},
+	}
+
+	estimates, err := gt.Operation.Estimate(contents)
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	for _, estimate := range estimates {
+		if estimate.GasLimit <= estimate.ConsumedGas || estimate.Fee <= 0 {
+			t.Errorf("invalid estimate %v", estimate)
+		}
+	}
+	t.Log(PrettyReport(estimates))
+}
+
+func TestWait(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	head, err := gt.Block.GetHead()
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	hashes, err := gt.Operation.GetBlockOperationHashes(head.Hash)
+	if err != nil || len(hashes) == 0 {
+		t.Fatalf("could not get operation hashes of head: %v", err)
+	}
+
+	receipt, err := gt.Operation.Wait(hashes[0], WaitOptions{Branch: head.Header.Predecessor})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	if receipt.BlockHash != head.Hash || receipt.Confirmations < 1 {
+		t.Errorf("operation %s not found in block %s: %v", hashes[0], head.Hash, receipt)
+	}
+
+	// Without a branch, operations included before Wait is called are found as well
+	hashes, err = gt.Operation.GetBlockOperationHashes(head.Header.Predecessor)
+	if err != nil || len(hashes) == 0 {
+		t.Fatalf("could not get operation hashes of %s: %v", head.Header.Predecessor, err)
+	}
+
+	receipt, err = gt.Operation.Wait(hashes[0], WaitOptions{})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	if receipt.BlockHash != head.Header.Predecessor || receipt.Confirmations < 2 {
+		t.Errorf("operation %s not found in block %s: %v", hashes[0], head.Header.Predecessor, receipt)
+	}
+}
+
+func TestCounterManagerNext(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	source := "tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB"
+	results := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			counter, err := gt.Operation.Counter.Next(source, 2)
+			if err != nil {
+				t.Errorf("%s", err)
+			}
+			results <- counter
+		}()
+	}
+
+	seen := make(map[int]bool)
+	for i := 0; i < 10; i++ {
+		counter := <-results
+		if seen[counter] || seen[counter+1] {
+			t.Errorf("counter %d handed out twice", counter)
+		}
+		seen[counter] = true
+		seen[counter+1] = true
+	}
+}
+
+func TestCounterManagerTrack(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	head, err := gt.Block.GetHead()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	source := "tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB"
+	first, err := gt.Operation.Counter.Next(source, 3)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// An operation signed but not injected yet holds its counters, and covers the counters before the next one
+	hash := b58cencode(make([]byte, 32), operationHashPrefix)
+	gt.Operation.Counter.Track(source, head, TrackedOperation{Hash: hash, First: first, Last: first + 2})
+	gt.Operation.Counter.Invalidate(source)
+	counter, err := gt.Operation.Counter.Next(source, 1)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if counter != first+3 {
+		t.Errorf("counter held by a tracked operation was reused: got %d, want %d", counter, first+3)
+	}
+	gt.Operation.Counter.Release(source)
+	if covered, err := gt.Operation.Counter.covers(source, first+3); err != nil || !covered {
+		t.Errorf("counters before %d not reported covered: %v", first+3, err)
+	}
+
+	// A rejected operation gives its counters back
+	gt.Operation.Counter.Reject(hash)
+	counter, err = gt.Operation.Counter.Next(source, 1)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if counter != first {
+		t.Errorf("counter of rejected operation was not reused: got %d, want %d", counter, first)
+	}
+	gt.Operation.Counter.Release(source)
+	if covered, err := gt.Operation.Counter.covers(source, first+1); err != nil || covered {
+		t.Errorf("counters before %d reported covered: %v", first+1, err)
+	}
+}
+
+func TestDryRunBatchPayment(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	payments := []Payment{
+		{Address: "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1", Amount: 1},
+		{Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", Amount: 2},
+	}
+
+	previews, err := gt.Operation.DryRunBatchPayment(payments, "tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB", BatchOptions{})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	for _, preview := range previews {
+		if len(preview.Failures) > 0 {
+			t.Errorf("batch would fail: %v", preview.Failures)
+		}
+		if preview.Amount != 3 || preview.TotalDebited <= preview.Amount || preview.OperationBytes == "" {
+			t.Errorf("invalid preview %v", preview)
+		}
+	}
+}
+
+func TestBatchFits(t *testing.T) {
+	batch := plannedBatch{
+		contents: Conts{Contents: []StructContents{
+			{Kind: "transaction", GasLimit: "10300"},
+			{Kind: "transaction", GasLimit: "10300"},
+		}},
+		operationBytes: strings.Repeat("00", 136),
+	}
+
+	if size, gas := batchUsage(batch); size != 200 || gas != 20600 {
+		t.Errorf("batch usage is %d bytes and %d gas, expected 200 bytes and 20600 gas", size, gas)
+	}
+
+	cases := []struct {
+		opts BatchOptions
+		fits bool
+	}{
+		{BatchOptions{MaxSize: 200, MaxGas: 20600}, true},
+		{BatchOptions{MaxSize: 199, MaxGas: 20600}, false},
+		{BatchOptions{MaxSize: 200, MaxGas: 20599}, false},
+	}
+	for _, c := range cases {
+		if fits := batchFits(batch, c.opts); fits != c.fits {
+			t.Errorf("batch fits %v is %v, expected %v", c.opts, fits, c.fits)
+		}
+	}
+
+	if !isBatchLimitError(errors.New(`500 error: [{"kind":"temporary","id":"proto.005-PsBabyM1.gas_exhausted.block"}]`)) {
+		t.Errorf("block gas exhaustion not detected")
+	}
+}
+
+func TestFileJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	journal, err := NewFileJournal(dir)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	payments := []Payment{{Address: "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1", Amount: 1}}
+	records := []PayoutBatch{
+		{Index: 1, Payments: payments, Status: PayoutBatchSigned},
+		{Index: 0, Payments: payments, Status: PayoutBatchSigned},
+		{Index: 1, Payments: payments, Status: PayoutBatchInjected},
+		{Index: 1, Payments: payments, Status: PayoutBatchApplied, BlockHash: "BLuB4mCSE7i8eT8SiKUp8wKJwrRs3QgjFhpEbQJZYgrMVG3LJvC"},
+	}
+	for _, record := range records {
+		if err := journal.Record("cycle-180", record); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	batches, err := journal.Batches("cycle-180")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(batches) != 2 || batches[0].Index != 0 || batches[1].Status != PayoutBatchApplied || batches[1].Payments[0] != payments[0] {
+		t.Errorf("unexpected batches %v", batches)
+	}
+
+	if batches, err := journal.Batches("cycle-181"); err != nil || len(batches) != 0 {
+		t.Errorf("unexpected batches %v for new run: %v", batches, err)
+	}
+
+	if err := journal.Record("../cycle-180", records[0]); err == nil {
+		t.Errorf("run name escaping the journal directory was accepted")
+	}
+
+	// A partially written last line is ignored, and removed by the next record
+	path := filepath.Join(dir, "cycle-180.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := f.WriteString(`{"Index":2,"Sta`); err != nil {
+		t.Fatalf("%s", err)
+	}
+	f.Close()
+
+	if batches, err := journal.Batches("cycle-180"); err != nil || len(batches) != 2 {
+		t.Errorf("unexpected batches %v with a partial last line: %v", batches, err)
+	}
+	if err := journal.Record("cycle-180", PayoutBatch{Index: 2, Payments: payments, Status: PayoutBatchSigned}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if batches, err := journal.Batches("cycle-180"); err != nil || len(batches) != 3 {
+		t.Errorf("unexpected batches %v after recording over a partial line: %v", batches, err)
+	}
+
+	// Any other invalid line is an error
+	if err := ioutil.WriteFile(path, []byte("{\"Index\":0\n{\"Index\":1}\n"), 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := journal.Batches("cycle-180"); err == nil {
+		t.Errorf("read a journal with an invalid line before the last one")
+	}
+}
+
+func TestUnpaidPayments(t *testing.T) {
+	a := Payment{Address: "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1", Amount: 1}
+	b := Payment{Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", Amount: 2}
+
+	batches := []PayoutBatch{
+		{Index: 0, Payments: []Payment{a}, Status: PayoutBatchApplied},
+		{Index: 1, Payments: []Payment{b}, Status: PayoutBatchFailed},
+	}
+
+	unpaid := unpaidPayments([]Payment{a, b, a}, batches)
+	if len(unpaid) != 2 || unpaid[0] != b || unpaid[1] != a {
+		t.Errorf("unpaid payments are %v, expected %v", unpaid, []Payment{b, a})
+	}
+}
+
+func TestPayerTransfers(t *testing.T) {
+	payer := "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc"
+	block := `[{"hash":"ooQqVJQDqY6NS5YDRjeoD6PeBHAmDTbqZFSBRxHhkiFmXGdkyo7","contents":[
+		{"kind":"transaction","source":"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc","amount":"1500","destination":"tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1",
+			"metadata":{"operation_result":{"status":"applied"}}},
+		{"kind":"transaction","source":"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc","amount":"2500","destination":"tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1",
+			"metadata":{"operation_result":{"status":"backtracked"}}},
+		{"kind":"transaction","source":"tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1","amount":"0","destination":"KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn",
+			"metadata":{"operation_result":{"status":"applied"},"internal_operation_results":[
+				{"kind":"transaction","source":"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc","nonce":0,"amount":"300","destination":"tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB","result":{"status":"applied"}}]}}]}]`
+
+	var operations []Operation
+	if err := json.Unmarshal([]byte(block), &operations); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	transfers := payerTransfers(payer, 42, operations)
+	expected := []Payment{
+		{Address: "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1", Amount: 1500},
+		{Address: "tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB", Amount: 300},
+	}
+	if len(transfers) != len(expected) {
+		t.Fatalf("found transfers %v, expected %v", transfers, expected)
+	}
+	for k := range expected {
+		if transfers[k].Payment != expected[k] || transfers[k].Level != 42 || transfers[k].OperationHash != operations[0].Hash {
+			t.Errorf("found transfer %v, expected %v", transfers[k], expected[k])
+		}
+	}
+}
+
+func TestEstimatesFromSimulation(t *testing.T) {
+	gt := &GoTezos{Constants: NetworkConstants{HardGasLimitPerOperation: "800000", OriginationSize: 257, CostPerByte: 1000}}
+	o := &OperationService{gt: gt}
+
+	simulated := `{"contents":[
+		{"kind":"transaction","source":"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc","fee":"0","counter":"7","gas_limit":"800000","storage_limit":"60000","amount":"1","destination":"KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn",
+			"metadata":{"operation_result":{"status":"applied","consumed_gas":"20000","paid_storage_size_diff":"10"},"internal_operation_results":[
+				{"kind":"transaction","source":"KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn","nonce":0,"amount":"1","destination":"tz3gN8NTLNLJg5KRsUU47NHNVHbdhcFXjjaB",
+					"result":{"status":"applied","consumed_gas":"10207","allocated_destination_contract":true}}]}},
+		{"kind":"transaction","source":"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc","fee":"0","counter":"8","gas_limit":"800000","storage_limit":"60000","amount":"1","destination":"tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1",
+			"metadata":{"operation_result":{"status":"backtracked","errors":[{"kind":"temporary","id":"proto.005-PsBabyM1.gas_exhausted.operation"}]}}}]}`
+
+	var operation Operation
+	if err := json.Unmarshal([]byte(simulated), &operation); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	failures := contentsFailures(operation.Contents)
+	if len(failures) != 1 || !strings.Contains(failures[0], "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1") || !strings.Contains(failures[0], "gas_exhausted.operation") {
+		t.Errorf("unexpected failures %v", failures)
+	}
+
+	if _, err := o.estimatesFromSimulation(operation); err == nil {
+		t.Errorf("estimated a simulation with a backtracked content")
+	}
+
+	renumberReceipts(operation.Contents, 12)
+	for k, contents := range operation.Contents {
+		if manager, _ := managerOperation(contents); manager.Counter != 12+k {
+			t.Errorf("receipt %d has counter %d, expected %d", k, manager.Counter, 12+k)
+		}
+	}
+
+	operation.Contents = operation.Contents[:1]
+	estimates, err := o.estimatesFromSimulation(operation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	estimate := estimates[0]
+	if estimate.ConsumedGas != 30207 || estimate.GasLimit != 30207+gasSafetyMargin || !estimate.AllocatedDestination ||
+		estimate.StorageLimit != 10+257 || estimate.Burn != 267000 {
+		t.Errorf("unexpected estimate %+v", estimate)
+	}
+}
+
+func TestForgeMicheline(t *testing.T) {
+	cases := []struct {
+		expression string
+		forged     string
+	}{
+		{`{"int":"1"}`, "0001"},
+		{`{"int":"-1"}`, "0041"},
+		{`{"int":"64"}`, "008001"},
+		{`{"string":"hello"}`, "010000000568656c6c6f"},
+		{`{"bytes":"cafe"}`, "0a00000002cafe"},
+		{`{"prim":"Unit"}`, "030b"},
+		{`{"prim":"Pair","args":[{"int":"1"},{"int":"2"}]}`, "070700010002"},
+		{`[{"prim":"DROP"}]`, "02000000020320"},
+		{`{"prim":"nat","annots":["%amount"]}`, "04620000000725616d6f756e74"},
+	}
+
+	for _, c := range cases {
+		forged, err := forgeMicheline(json.RawMessage(c.expression))
+		if err != nil {
+			t.Errorf("%s", err)
+		}
+		if hex.EncodeToString(forged) != c.forged {
+			t.Errorf("forged %s as %x, expected %s", c.expression, forged, c.forged)
+		}
+	}
+
+	if forged, _ := forgeNat("10000"); hex.EncodeToString(forged) != "904e" {
+		t.Errorf("forged 10000 as %x, expected 904e", forged)
+	}
+}
+
+func TestSignOperation(t *testing.T) {
+	var accounts AccountService
+	wallet, err := accounts.ImportWallet("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ", "edpkunwa7a3Y5vDr9eoKy4E21pzonuhqvNjscT9XG27aQV4gXq4dNm", "edsk362Ypv3qLgbnGvZK7JwqNbwiLGe18XhTMFQY4gUonqnaCPiT6X")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	operation := UnsignedOperation{
+		Branch: b58cencode(make([]byte, 32), blockHashPrefix),
+		Contents: []StructContents{
+			{Kind: "transaction", Source: wallet.Address, Fee: 1420, Counter: "10", GasLimit: "10307", StorageLimit: "0", Amount: 1000000, Destination: "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1"},
+		},
+	}
+	operation.OperationBytes, err = forgeOperationLocally(Conts{Branch: operation.Branch, Contents: operation.Contents})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	signed, err := SignOperation(operation, wallet)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	opBytes, _ := hex.DecodeString(operation.OperationBytes)
+	signature, _ := signatureBytes(signed.Signature)
+	hash := blake2b.Sum256(append([]byte{3}, opBytes...))
+	if !ed25519.Verify(wallet.Kp.PubKey, hash[:], signature) {
+		t.Errorf("invalid signature %s", signed.Signature)
+	}
+	if signed.SignedBytes != operation.OperationBytes+hex.EncodeToString(signature) {
+		t.Errorf("signed bytes do not end with the signature")
+	}
+
+	// The signer must refuse contents that differ from the forged bytes
+	operation.Contents[0].Amount = 2000000
+	if _, err := SignOperation(operation, wallet); err == nil {
+		t.Errorf("operation with tampered contents was signed")
+	}
+}
+
+func TestOriginatedContractAddress(t *testing.T) {
+	var cases = []struct {
+		index    int
+		contract string
+	}{
+		{0, "KT1Pve1p1NBQWAk46QJKyTf2VdcX2kcfFLtB"},
+		{1, "KT1QXzTkt8faL2HicoBcNWZbhAWCcwQnh6Dn"},
+		{2, "KT1Q6vxpbwNjyyxawYxQwrPj3uiwRwBXi9hu"},
+	}
+
+	for _, c := range cases {
+		contract, err := originatedContractAddress("oneDNXrq8HVRVCJXkqofS9e41G8ZkttpBZFMaQ9MvKyP3nYiP97", c.index)
+		if err != nil || contract != c.contract {
+			t.Errorf("originatedContractAddress at %d = %s, %v, want %s", c.index, contract, err, c.contract)
+		}
+	}
+
+	if _, err := originatedContractAddress("invalid", 0); err == nil {
+		t.Errorf("computed an address for an invalid operation hash")
+	}
+}
+
+func TestForgeVotingOperations(t *testing.T) {
+	period := 17
+	proposal := "PsBabyM1eUXZseaJdmXFApDSBqj8YBfwELoxZHHW77EMcAbbwAS"
+	source := "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ"
+
+	ballot, err := forgeContent(StructContents{Kind: "ballot", Source: source, Period: &period, Proposal: proposal, Ballot: BallotNay})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(ballot) != 59 || ballot[0] != 6 || hex.EncodeToString(ballot[22:26]) != "00000011" || ballot[58] != 1 {
+		t.Errorf("unexpected ballot bytes %x", ballot)
+	}
+
+	proposals, err := forgeContent(StructContents{Kind: "proposals", Source: source, Period: &period, Proposals: []string{proposal}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(proposals) != 62 || proposals[0] != 5 || hex.EncodeToString(proposals[26:30]) != "00000020" {
+		t.Errorf("unexpected proposals bytes %x", proposals)
+	}
+
+	if _, err := forgeContent(StructContents{Kind: "ballot", Source: source, Period: &period, Proposal: proposal, Ballot: "maybe"}); err == nil {
+		t.Errorf("invalid ballot was forged")
+	}
+}
+
+func TestParticipation(t *testing.T) {
+	cases := []struct {
+		rolls         int
+		quorum        int
+		ballots       Ballots
+		participation int
+		quorumReached bool
+		approval      int
+		supermajority bool
+	}{
+		{rolls: 1000, quorum: 7500, ballots: Ballots{Yay: 700, Nay: 100, Pass: 50}, participation: 8500, quorumReached: true, approval: 8750, supermajority: true},
+		{rolls: 1000, quorum: 7500, ballots: Ballots{Yay: 400, Nay: 100, Pass: 100}, participation: 6000, quorumReached: false, approval: 8000, supermajority: true},
+		{rolls: 1000, quorum: 5000, ballots: Ballots{Yay: 300, Nay: 100, Pass: 200}, participation: 6000, quorumReached: true, approval: 7500, supermajority: false},
+		{rolls: 1000, quorum: 5000, ballots: Ballots{Pass: 600}, participation: 6000, quorumReached: true},
+		{quorum: 5000},
+	}
+
+	for _, c := range cases {
+		p := Participation{Rolls: c.rolls, Quorum: c.quorum, Ballots: c.ballots}
+		p.compute()
+		if p.Participation != c.participation || p.QuorumReached != c.quorumReached || p.Approval != c.approval || p.Supermajority != c.supermajority {
+			t.Errorf("unexpected participation %+v for ballots %+v", p, c.ballots)
+		}
+	}
+}
+
+func TestVotingService(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	period, err := gt.Voting.GetCurrentPeriod("head")
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	kind, err := gt.Voting.GetCurrentPeriodKind("head")
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	if kind != period.Kind {
+		t.Errorf("period kind %s does not match block period kind %s", kind, period.Kind)
+	}
+
+	listings, err := gt.Voting.GetListings("head")
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(listings) == 0 {
+		t.Errorf("no listings")
+	}
+
+	participation, err := gt.Voting.GetParticipation("head")
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	if participation.Rolls == 0 || participation.Quorum == 0 {
+		t.Errorf("invalid participation %+v", participation)
+	}
+}
+
+func TestPendingOperations(t *testing.T) {
+	resp := `{
+		"applied": [{"hash": "opA", "branch": "BLa", "contents": [{"kind": "transaction", "source": "tz1a", "destination": "tz1b", "amount": "1"}], "signature": "sigA"}],
+		"refused": [["opR", {"protocol": "PsBabyM1eUXZseaJdmXFApDSBqj8YBfwELoxZHHW77EMcAbbwAS", "branch": "BLr", "contents": [{"kind": "transaction", "source": "tz1a", "destination": "tz1c", "amount": "2"}], "signature": "sigR", "error": [{"kind": "temporary", "id": "proto.005-PsBabyM1.contract.counter_in_the_past"}]}]],
+		"branch_refused": [],
+		"branch_delayed": [["opD", {"protocol": "PsBabyM1eUXZseaJdmXFApDSBqj8YBfwELoxZHHW77EMcAbbwAS", "branch": "BLd", "contents": [{"kind": "transaction", "source": "tz1d", "destination": "tz1b", "amount": "3"}], "signature": "sigD", "error": []}]],
+		"unprocessed": [["opU", {"branch": "BLu", "data": "0800"}]]
+	}`
+
+	var pending PendingOperations
+	if err := json.Unmarshal([]byte(resp), &pending); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(pending.All()) != 4 {
+		t.Errorf("expected 4 pending operations, got %d", len(pending.All()))
+	}
+
+	refused, ok := pending.Find("opR")
+	if !ok || refused.Status != OperationStatusRefused || len(refused.Errors) != 1 || refused.Errors[0].ID != "proto.005-PsBabyM1.contract.counter_in_the_past" {
+		t.Errorf("unexpected refused operation %+v", refused)
+	}
+
+	unprocessed, ok := pending.Find("opU")
+	if !ok || unprocessed.Status != OperationStatusUnprocessed || unprocessed.Data != "0800" || unprocessed.Branch != "BLu" {
+		t.Errorf("unexpected unprocessed operation %+v", unprocessed)
+	}
+
+	bySource := pending.BySource("tz1a")
+	if len(bySource.Applied) != 1 || len(bySource.Refused) != 1 || len(bySource.BranchDelayed) != 0 {
+		t.Errorf("unexpected operations from tz1a %+v", bySource)
+	}
+
+	byDestination := pending.ByDestination("tz1b")
+	if len(byDestination.All()) != 2 || byDestination.Applied[0].Hash != "opA" || byDestination.BranchDelayed[0].Hash != "opD" {
+		t.Errorf("unexpected operations to tz1b %+v", byDestination)
+	}
+}
+
+func TestMempoolService(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	_, err = gt.Mempool.GetPendingOperations()
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	filter, err := gt.Mempool.GetFilter()
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	if filter.MinimalNanotezPerGasUnit == "" {
+		t.Errorf("invalid mempool filter %+v", filter)
+	}
+}
+
+func TestDecodeOperation(t *testing.T) {
+	resp := `{
+		"protocol": "PsBabyM1eUXZseaJdmXFApDSBqj8YBfwELoxZHHW77EMcAbbwAS",
+		"chain_id": "NetXdQprcVkpaWU",
+		"hash": "opA",
+		"branch": "BLa",
+		"contents": [
+			{"kind": "endorsement", "level": 100, "metadata": {"balance_updates": [], "delegate": "tz1d", "slots": [1, 4]}},
+			{"kind": "transaction", "source": "tz1a", "fee": "1420", "counter": "42", "gas_limit": "40000", "storage_limit": "300", "amount": "1000000", "destination": "KT1c",
+				"parameters": {"entrypoint": "do", "value": {"prim": "Unit"}},
+				"metadata": {
+					"balance_updates": [{"kind": "contract", "contract": "tz1a", "change": "-1420"}],
+					"operation_result": {"status": "applied", "storage": {"int": "1"}, "consumed_gas": "25000", "storage_size": "120"},
+					"internal_operation_results": [
+						{"kind": "transaction", "source": "KT1c", "nonce": 0, "amount": "500", "destination": "tz1b", "result": {"status": "applied", "consumed_gas": "10207"}},
+						{"kind": "delegation", "source": "KT1c", "nonce": 1, "result": {"status": "failed", "errors": [{"kind": "temporary", "id": "proto.005-PsBabyM1.delegate.unchanged"}]}}
+					]
+				}},
+			{"kind": "endorsement_with_slot", "endorsement": {"branch": "BLe"}, "slot": 3}
+		],
+		"signature": "sigA"
+	}`
+
+	var operation Operation
+	if err := json.Unmarshal([]byte(resp), &operation); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(operation.Contents) != 3 {
+		t.Fatalf("expected 3 contents, got %d", len(operation.Contents))
+	}
+
+	endorsement, ok := operation.Contents[0].(*EndorsementContents)
+	if !ok || endorsement.Level != 100 || endorsement.Metadata.Delegate != "tz1d" || len(endorsement.Metadata.Slots) != 2 {
+		t.Errorf("unexpected endorsement %+v", operation.Contents[0])
+	}
+
+	transaction, ok := operation.Contents[1].(*TransactionContents)
+	if !ok {
+		t.Fatalf("unexpected contents %+v", operation.Contents[1])
+	}
+	if transaction.Fee != 1420 || transaction.Counter != 42 || transaction.Amount != 1000000 || transaction.Parameters.Entrypoint != "do" {
+		t.Errorf("unexpected transaction %+v", transaction)
+	}
+	result := transaction.Metadata.OperationResult
+	if result.Status != OperationStatusApplied || result.ConsumedGas != 25000 || result.StorageSize != 120 || string(result.Storage) != `{"int": "1"}` {
+		t.Errorf("unexpected transaction result %+v", result)
+	}
+
+	internal := transaction.Metadata.InternalOperationResults
+	if len(internal) != 2 {
+		t.Fatalf("expected 2 internal operations, got %d", len(internal))
+	}
+	if transfer, ok := internal[0].(*InternalTransaction); !ok || transfer.Amount != 500 || transfer.Result.ConsumedGas != 10207 {
+		t.Errorf("unexpected internal transaction %+v", internal[0])
+	}
+	if delegation, ok := internal[1].(*InternalDelegation); !ok || delegation.Result.Status != OperationStatusFailed || delegation.Result.Errors[0].ID != "proto.005-PsBabyM1.delegate.unchanged" {
+		t.Errorf("unexpected internal delegation %+v", internal[1])
+	}
+
+	unknown, ok := operation.Contents[2].(*UnknownOperation)
+	if !ok || unknown.OperationKind() != "endorsement_with_slot" {
+		t.Fatalf("unexpected contents %+v", operation.Contents[2])
+	}
+	encoded, err := json.Marshal(operation.Contents[2])
+	if err != nil || string(encoded) != `{"kind":"endorsement_with_slot","endorsement":{"branch":"BLe"},"slot":3}` {
+		t.Errorf("unknown contents were not kept as raw JSON: %s %v", encoded, err)
+	}
+
+	if _, err := DecodeContents([]byte(`{"level": 1}`)); err == nil {
+		t.Errorf("contents without a kind were decoded")
+	}
+}
+
+func TestBlockOperations(t *testing.T) {
+	resp := `{
+		"hash": "BLa",
+		"header": {"level": 10},
+		"operations": [
+			[{"hash": "opE", "branch": "BLb", "contents": [{"kind": "endorsement", "level": 9, "metadata": {"balance_updates": [], "delegate": "tz1d", "slots": [0]}}]}],
+			[],
+			[],
+			[
+				{"hash": "opT", "branch": "BLb", "contents": [
+					{"kind": "reveal", "source": "tz1a", "fee": "1269", "counter": "1", "gas_limit": "10000", "storage_limit": "0", "public_key": "edpk",
+						"metadata": {"balance_updates": [], "operation_result": {"status": "applied", "consumed_gas": "10000"}}},
+					{"kind": "transaction", "source": "tz1a", "fee": "1420", "counter": "2", "gas_limit": "10307", "storage_limit": "0", "amount": "1", "destination": "tz1b",
+						"metadata": {"balance_updates": [], "operation_result": {"status": "backtracked", "consumed_gas": "10207"}}}
+				]}
+			]
+		]
+	}`
+
+	var block Block
+	block, err := block.unmarshalJSON([]byte(resp))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(block.Operations) != 4 || len(block.Operations[3]) != 1 {
+		t.Fatalf("unexpected operations %v", block.Operations)
+	}
+
+	operation, ok := findOperation(block, "opT")
+	if !ok {
+		t.Fatalf("operation opT not found")
+	}
+	if transaction, ok := operation.Contents[1].(*TransactionContents); !ok || transaction.Counter != 2 {
+		t.Errorf("unexpected contents %+v", operation.Contents[1])
+	}
+	if status := operationStatus(operation); status != OperationStatusBacktracked {
+		t.Errorf("expected status %s, got %s", OperationStatusBacktracked, status)
+	}
+
+	operation, _ = findOperation(block, "opE")
+	if status := operationStatus(operation); status != OperationStatusIncluded {
+		t.Errorf("expected status %s, got %s", OperationStatusIncluded, status)
+	}
+}
+
+func TestGetOperations(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	operations, err := gt.Block.GetOperations("head")
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(operations) != 4 {
+		t.Errorf("expected 4 validation passes, got %d", len(operations))
+	}
+}
+
+func TestMicheline(t *testing.T) {
+	script := `parameter (or (pair %transfer address nat) (unit %default)) ;
+storage (pair (big_map address nat) (string %name)) ;
+code { CAR ; # comment
+       IF_LEFT { DROP ; PUSH int -42 ; DROP } /* block */ { DROP } ;
+       PUSH string "a\"b\\c\n" ; DROP ; PUSH bytes 0x00ff ; DROP ; NIL operation ; PAIR }`
+
+	expected := `[{"prim":"parameter","args":[{"prim":"or","args":[{"prim":"pair","args":[{"prim":"address"},{"prim":"nat"}],"annots":["%transfer"]},{"prim":"unit","annots":["%default"]}]}]},` +
+		`{"prim":"storage","args":[{"prim":"pair","args":[{"prim":"big_map","args":[{"prim":"address"},{"prim":"nat"}]},{"prim":"string","annots":["%name"]}]}]},` +
+		`{"prim":"code","args":[[{"prim":"CAR"},{"prim":"IF_LEFT","args":[[{"prim":"DROP"},{"prim":"PUSH","args":[{"prim":"int"},{"int":"-42"}]},{"prim":"DROP"}],[{"prim":"DROP"}]]},` +
+		`{"prim":"PUSH","args":[{"prim":"string"},{"string":"a\"b\\c\n"}]},{"prim":"DROP"},{"prim":"PUSH","args":[{"prim":"bytes"},{"bytes":"00ff"}]},{"prim":"DROP"},` +
+		`{"prim":"NIL","args":[{"prim":"operation"}]},{"prim":"PAIR"}]]}]`
+
+	parsed, err := ParseMichelson(script)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if string(encoded) != expected {
+		t.Errorf("unexpected micheline JSON %s", encoded)
+	}
+
+	var decoded Micheline
+	if err := json.Unmarshal([]byte(expected), &decoded); err != nil {
+		t.Fatalf("%s", err)
+	}
+	reparsed, err := ParseMichelson(decoded.Michelson())
+	if err != nil {
+		t.Fatalf("could not parse printed michelson %s: %s", decoded.Michelson(), err)
+	}
+	if encoded, _ := json.Marshal(reparsed); string(encoded) != expected {
+		t.Errorf("printed michelson does not parse back to the same expression:\n%s", decoded.Michelson())
+	}
+
+	pair := NewMichelinePrim("Pair", []Micheline{NewMichelineInt(big.NewInt(1)), NewMichelinePrim("Some", []Micheline{NewMichelineString("tz1")})})
+	if pair.Michelson() != `Pair 1 (Some "tz1")` {
+		t.Errorf("unexpected michelson %s", pair.Michelson())
+	}
+
+	for _, invalid := range []string{"", "{ DUP", "Pair (1", `"abc`, "DUP ; ; DROP", "Pair %a 1 %b"} {
+		if _, err := ParseMichelson(invalid); err == nil {
+			t.Errorf("invalid michelson '%s' was parsed", invalid)
+		}
+	}
+
+	// An unset Micheline field is encoded as null and decoded back as the zero Micheline
+	var unset struct {
+		Value Micheline `json:"value"`
+	}
+	encoded, err = json.Marshal(unset)
+	if err != nil || string(encoded) != `{"value":null}` {
+		t.Errorf("could not encode unset micheline: %s %v", encoded, err)
+	}
+	unset.Value = pair
+	if err := json.Unmarshal(encoded, &unset); err != nil || unset.Value.Kind != MichelineEmpty {
+		t.Errorf("could not decode null micheline: %v %v", unset.Value, err)
+	}
+}
+
+func TestPack(t *testing.T) {
+	parse := func(text string) Micheline {
+		m, err := ParseMichelson(text)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		return m
+	}
+
+	cases := []struct {
+		value  string
+		typ    string
+		packed string
+	}{
+		{value: `1`, typ: `int`, packed: "050001"},
+		{value: `-64`, typ: `int`, packed: "0500c001"},
+		{value: `"foo"`, typ: `string`, packed: "050100000003666f6f"},
+		{value: `Pair 1 "a"`, typ: `pair nat string`, packed: "0507070001010000000161"},
+		{value: `{ Elt "a" (Some 0x00) }`, typ: `map string (option bytes)`, packed: "050200000010070401000000016105090a0000000100"},
+		{value: `"tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ"`, typ: `address`, packed: ""},
+		{value: `"KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn%transfer"`, typ: `contract unit`, packed: ""},
+		{value: `"2019-09-26T10:59:51Z"`, typ: `timestamp`, packed: ""},
+		{value: `{ DUP ; DROP ; PUSH (pair int (or unit nat)) (Pair 3 (Left Unit)) }`, typ: `lambda unit unit`, packed: ""},
+		{value: `{ DROP ; LAMBDA unit key_hash { DROP ; PUSH key_hash "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" } ; DROP ; UNIT }`, typ: `lambda unit unit`, packed: ""},
+	}
+
+	for _, c := range cases {
+		value, typ := parse(c.value), parse(c.typ)
+		packed, err := Pack(value, typ)
+		if err != nil {
+			t.Errorf("could not pack %s: %s", c.value, err)
+			continue
+		}
+		if c.packed != "" && hex.EncodeToString(packed) != c.packed {
+			t.Errorf("unexpected packed %s: %x", c.value, packed)
+		}
+
+		unpacked, err := UnpackTyped(packed, typ)
+		if err != nil {
+			t.Errorf("could not unpack %s: %s", c.value, err)
+			continue
+		}
+		if unpacked.Michelson() != value.Michelson() {
+			t.Errorf("unpacked %s as %s", c.value, unpacked.Michelson())
+		}
+	}
+
+	address, _ := Pack(parse(`"tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ"`), parse(`address`))
+	contractID, _ := forgeContractID("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ")
+	if hex.EncodeToString(address) != "050a00000016"+hex.EncodeToString(contractID) {
+		t.Errorf("address was not packed in its optimized form: %x", address)
+	}
+
+	// Values pushed by the code of lambdas are packed in their optimized form too
+	lambda, _ := Pack(parse(`{ DROP ; PUSH key_hash "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" ; UNIT }`), parse(`lambda unit unit`))
+	keyHash, _ := forgePublicKeyHash("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ")
+	if !strings.Contains(hex.EncodeToString(lambda), "0a00000015"+hex.EncodeToString(keyHash)) {
+		t.Errorf("key hash pushed by a lambda was not packed in its optimized form: %x", lambda)
+	}
+
+	hash, err := ScriptExpressionHash(parse(`"tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ"`), parse(`address`))
+	if err != nil || !strings.HasPrefix(hash, "expr") || len(hash) != 54 {
+		t.Errorf("invalid script expression hash %s %v", hash, err)
+	}
+
+	if _, err := Pack(parse(`Pair 1 2`), parse(`option int`)); err == nil {
+		t.Errorf("value of the wrong type was packed")
+	}
+	if _, err := Unpack([]byte{5, 7, 7, 0}); err == nil {
+		t.Errorf("truncated data was unpacked")
+	}
+}
+
+func TestPackData(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	value, _ := ParseMichelson(`Pair "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" (Pair 12 "2019-09-26T10:59:51Z")`)
+	typ, _ := ParseMichelson(`pair address (pair nat timestamp)`)
+
+	expected, err := gt.Contract.PackData(value, typ)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	packed, err := Pack(value, typ)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if hex.EncodeToString(packed) != hex.EncodeToString(expected) {
+		t.Errorf("packed %x, node packed %x", packed, expected)
+	}
+}
+
+func TestBigMapDiffs(t *testing.T) {
+	storageType, _ := ParseMichelson(`pair (big_map %ledger address nat) (pair (option (big_map %approvals nat unit)) string)`)
+	storage, _ := ParseMichelson(`Pair 17 (Pair (Some 18) "token")`)
+
+	bigMaps, err := findBigMaps(storage, storageType)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(bigMaps) != 2 || bigMaps[0].ID != 17 || bigMaps[0].Annots[0] != "%ledger" || bigMaps[1].ID != 18 || bigMaps[1].KeyType.Prim != "nat" {
+		t.Errorf("unexpected big maps %+v", bigMaps)
+	}
+
+	resp := `[[], [], [], [{"hash": "opA", "branch": "BLa", "contents": [{"kind": "transaction", "source": "tz1a", "fee": "0", "counter": "1", "gas_limit": "0", "storage_limit": "0", "amount": "0", "destination": "KT1c",
+		"metadata": {"balance_updates": [], "operation_result": {"status": "applied", "big_map_diff": [
+			{"action": "update", "big_map": "17", "key_hash": "exprA", "key": {"bytes": "00005d356c1724faaf490056f2c2178c2df01434ab87"}, "value": {"int": "10"}},
+			{"action": "update", "big_map": "17", "key_hash": "exprB", "key": {"string": "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1"}, "value": {"int": "5"}},
+			{"action": "update", "big_map": "17", "key_hash": "exprA", "key": {"bytes": "00005d356c1724faaf490056f2c2178c2df01434ab87"}, "value": {"int": "7"}},
+			{"action": "copy", "source_big_map": "17", "destination_big_map": "-1"},
+			{"action": "update", "big_map": "17", "key_hash": "exprB", "key": {"string": "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1"}}
+		]},
+		"internal_operation_results": [{"kind": "transaction", "source": "KT1c", "nonce": 0, "amount": "0", "destination": "KT1d", "result": {"status": "applied", "big_map_diff": [
+			{"action": "alloc", "big_map": "19", "key_type": {"prim": "nat"}, "value_type": {"prim": "nat"}}
+		]}}]}}]}]]`
+
+	var operations [][]Operation
+	if err := json.Unmarshal([]byte(resp), &operations); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	diffs := bigMapDiffs(operations)
+	if len(diffs) != 6 {
+		t.Fatalf("expected 6 big map diffs, got %d", len(diffs))
+	}
+
+	keys := make(map[int]map[string]BigMapKey)
+	for _, diff := range diffs {
+		if err := applyBigMapDiff(keys, diff, 100); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	if value := keys[17]["exprA"].Value; value == nil || value.Int.Int64() != 7 {
+		t.Errorf("unexpected value of exprA %+v", keys[17]["exprA"])
+	}
+	if keys[17]["exprB"].Value != nil {
+		t.Errorf("removed key exprB has a value")
+	}
+	if value := keys[-1]["exprB"].Value; value == nil || value.Int.Int64() != 5 {
+		t.Errorf("copied big map does not have the value of exprB before its removal")
+	}
+	if keys[19] == nil || len(keys[19]) != 0 {
+		t.Errorf("allocated big map is not empty")
+	}
+
+	key, err := convertData(keys[17]["exprA"].Key, bigMaps[0].KeyType, readableData)
+	if err != nil || key.String != "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" {
+		t.Errorf("unexpected readable key %s %v", key.Michelson(), err)
+	}
+}
+
+func TestGetBigMaps(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	bigMaps, err := gt.Contract.GetBigMaps("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "head")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(bigMaps) == 0 {
+		t.Fatalf("no big maps")
+	}
+
+	key, _ := ParseMichelson(`"tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ"`)
+	if _, _, err := gt.Contract.GetBigMapValue(bigMaps[0], key, "head"); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestUnmarshalMicheline(t *testing.T) {
+	type Entry struct {
+		Amount int
+		Note   *string
+	}
+	type Storage struct {
+		Ledger  BigMap
+		Admin   string `michelson:"admin"`
+		Paused  bool
+		Limits  map[string]int
+		Holders []string
+		Entry   Entry
+		Since   time.Time
+		Action  *int
+		Other   *string
+	}
+
+	typ, err := ParseMichelson(`pair (big_map %ledger address nat)
+		(pair (pair (address %admin) (bool %paused))
+		      (pair (map %limits string int)
+		            (pair (list %holders address)
+		                  (pair (pair %entry int (option string))
+		                        (pair (timestamp %since) (or (int %action) (string %other)))))))`)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	value, err := ParseMichelson(`Pair 17
+		(Pair (Pair 0x00005d356c1724faaf490056f2c2178c2df01434ab87 True)
+		      (Pair { Elt "a" 1 ; Elt "b" -2 }
+		            (Pair { "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" }
+		                  (Pair (Pair 5 (Some "memo"))
+		                        (Pair 1571000000 (Left 3))))))`)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var storage Storage
+	if err := UnmarshalMicheline(value, typ, &storage); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if storage.Ledger.ID != 17 || storage.Ledger.KeyType.Prim != "address" {
+		t.Errorf("wrong big map %+v", storage.Ledger)
+	}
+	if storage.Admin != "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" || !storage.Paused {
+		t.Errorf("wrong admin %s or paused %t", storage.Admin, storage.Paused)
+	}
+	if len(storage.Limits) != 2 || storage.Limits["b"] != -2 {
+		t.Errorf("wrong limits %v", storage.Limits)
+	}
+	if len(storage.Holders) != 1 || storage.Holders[0] != "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" {
+		t.Errorf("wrong holders %v", storage.Holders)
+	}
+	if storage.Entry.Amount != 5 || storage.Entry.Note == nil || *storage.Entry.Note != "memo" {
+		t.Errorf("wrong entry %+v", storage.Entry)
+	}
+	if storage.Since.Unix() != 1571000000 {
+		t.Errorf("wrong since %s", storage.Since)
+	}
+	if storage.Action == nil || *storage.Action != 3 || storage.Other != nil {
+		t.Errorf("wrong or branch %v %v", storage.Action, storage.Other)
+	}
+
+	var wrong struct{ Admin int }
+	if err := UnmarshalMicheline(value, typ, &wrong); err == nil {
+		t.Errorf("expected an error decoding an address into an int")
+	}
+
+	// The branches of an or without annotations go to the fields by their position in the type
+	var branch struct {
+		Count *int
+		Name  *string
+	}
+	orType, _ := ParseMichelson(`or nat string`)
+	if err := UnmarshalMicheline(NewMichelinePrim("Right", []Micheline{NewMichelineString("abc")}), orType, &branch); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if branch.Count != nil || branch.Name == nil || *branch.Name != "abc" {
+		t.Errorf("wrong or branch %v %v", branch.Count, branch.Name)
+	}
+
+	code, err := ParseMichelson(`parameter (or (pair %transfer address nat) (or (nat %mint) (unit %burn))) ;
+		storage unit ;
+		code { CDR ; NIL operation ; PAIR }`)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	entrypoints, err := Script{Code: code}.Entrypoints()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(entrypoints) != 3 || entrypoints["mint"].Prim != "nat" || entrypoints["transfer"].Prim != "pair" {
+		t.Errorf("wrong entrypoints %v", entrypoints)
+	}
+}
+
+func TestGetContract(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	contract, err := gt.Contract.GetContract("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "head")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if contract.Script == nil {
+		t.Fatalf("no script")
+	}
+
+	if _, err := gt.Contract.GetBalance("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "head"); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	var storage interface{}
+	if err := gt.Contract.GetTypedStorage("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "head", &storage); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestScriptResults(t *testing.T) {
+	resp := `{
+		"storage": {"int": "2"},
+		"operations": [{"kind": "transaction", "source": "KT1c", "nonce": 0, "amount": "10", "destination": "tz1b"}],
+		"big_map_diff": [{"action": "alloc", "big_map": "-1", "key_type": {"prim": "nat"}, "value_type": {"prim": "unit"}}],
+		"trace": [
+			{"location": 7, "gas": "799990", "stack": [{"item": {"prim": "Pair", "args": [{"int": "1"}, {"int": "1"}]}, "annot": "@parameter"}]},
+			{"location": 8, "gas": "unaccounted", "stack": []}
+		]
+	}`
+
+	var result TraceCodeResult
+	if err := json.Unmarshal([]byte(resp), &result); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if result.Storage.Int.Int64() != 2 || len(result.BigMapDiff) != 1 {
+		t.Errorf("wrong storage %s or big map diff %v", result.Storage.Michelson(), result.BigMapDiff)
+	}
+	if len(result.Operations) != 1 || result.Operations[0].OperationKind() != KindTransaction {
+		t.Errorf("wrong operations %v", result.Operations)
+	}
+	if len(result.Trace) != 2 || result.Trace[0].Stack[0].Annot != "@parameter" || result.Trace[1].Gas != "unaccounted" {
+		t.Errorf("wrong trace %+v", result.Trace)
+	}
+
+	unit := NewMichelinePrim("Unit", nil)
+	input, err := json.Marshal(RunCodeInput{Script: NewMichelineSeq(), Storage: unit, Input: unit, Amount: 10, ChainID: "NetXdQprcVkpaWU"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !strings.Contains(string(input), `"amount":"10"`) || strings.Contains(string(input), `"gas"`) {
+		t.Errorf("wrong input %s", input)
+	}
+}
+
+func TestRunCode(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	code, _ := ParseMichelson(`parameter nat ; storage nat ; code { DUP ; CAR ; DIP { CDR } ; ADD ; NIL operation ; PAIR }`)
+	if _, err := gt.Contract.TypecheckCode(code); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	result, err := gt.Contract.RunCode(RunCodeInput{
+		Script:  code,
+		Storage: NewMichelineInt(big.NewInt(1)),
+		Input:   NewMichelineInt(big.NewInt(2)),
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if result.Storage.Int == nil || result.Storage.Int.Int64() != 3 {
+		t.Errorf("wrong storage %s", result.Storage.Michelson())
+	}
+}
+
+func TestFA12(t *testing.T) {
+	parameters, err := fa12TransferParameters("tz1a", "tz1b", big.NewInt(10))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	transfer, err := json.Marshal(parameters)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected := `{"prim":"Pair","args":[{"string":"tz1a"},{"prim":"Pair","args":[{"string":"tz1b"},{"int":"10"}]}]}`
+	if string(transfer) != expected {
+		t.Errorf("expected transfer %s, got %s", expected, transfer)
+	}
+	if _, err := fa12TransferParameters("tz1a", "tz1b", nil); err == nil {
+		t.Errorf("expected an error for a transfer without an amount")
+	}
+	if _, err := fa12ApproveParameters("tz1b", big.NewInt(-1)); err == nil {
+		t.Errorf("expected an error for a negative allowance")
+	}
+
+	typ, _ := ParseMichelson(`pair (nat :balance) (map :approvals address nat)`)
+	value, _ := ParseMichelson(`Pair 42 { Elt "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" 7 }`)
+	balance, approvals, err := fa12Account(value, typ)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if balance.Int64() != 42 || approvals["tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ"].Int64() != 7 {
+		t.Errorf("wrong balance %s or approvals %v", balance, approvals)
+	}
+
+	nat, _ := ParseMichelson(`nat`)
+	balance, approvals, err = fa12Account(NewMichelineInt(big.NewInt(5)), nat)
+	if err != nil || balance.Int64() != 5 || approvals != nil {
+		t.Errorf("wrong balance %s or approvals %v, err %v", balance, approvals, err)
+	}
+
+	address, _ := ParseMichelson(`address`)
+	ledger, err := findLedger([]BigMap{
+		{ID: 1, Annots: []string{"%metadata"}, KeyType: nat},
+		{ID: 2, KeyType: address},
+	})
+	if err != nil || ledger.ID != 2 {
+		t.Errorf("wrong ledger %d, err %v", ledger.ID, err)
+	}
+	if _, err := findLedger([]BigMap{{ID: 1, KeyType: address}, {ID: 2, KeyType: address}}); err == nil {
+		t.Errorf("expected an error for an ambiguous ledger")
+	}
+}
+
+func TestFA12Service(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	// tzBTC is an FA1.2 token on mainnet
+	if _, err := gt.FA12.GetBalance("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ", "head"); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestFA2(t *testing.T) {
+	types := make(map[string]Micheline)
+	for entrypoint, text := range fa2EntrypointTypes {
+		typ, err := ParseMichelson(text)
+		if err != nil {
+			t.Fatalf("%s: %s", entrypoint, err)
+		}
+		types[entrypoint] = typ
+	}
+
+	transfer, err := fa2TransferParameters([]FA2Transfer{{
+		From: "tz1a",
+		Txs:  []FA2Destination{{To: "tz1b", TokenID: 0, Amount: big.NewInt(5)}, {To: "tz1c", TokenID: 1, Amount: big.NewInt(6)}},
+	}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected := `{ Pair "tz1a" { Pair "tz1b" (Pair 0 5) ; Pair "tz1c" (Pair 1 6) } }`
+	if transfer.Michelson() != expected {
+		t.Errorf("expected transfer %s, got %s", expected, transfer.Michelson())
+	}
+
+	invalid := []FA2Destination{{To: "tz1b", Amount: nil}, {To: "tz1b", Amount: big.NewInt(-5)}, {To: "tz1b", TokenID: -1, Amount: big.NewInt(5)}}
+	for _, tx := range invalid {
+		if _, err := fa2TransferParameters([]FA2Transfer{{From: "tz1a", Txs: []FA2Destination{tx}}}); err == nil {
+			t.Errorf("expected an error for transfer %+v", tx)
+		}
+	}
+
+	updates, err := fa2UpdateOperatorsParameters([]FA2OperatorUpdate{{Owner: "tz1a", Operator: "KT1o", TokenID: 2, Remove: true}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected = `{ Right (Pair "tz1a" (Pair "KT1o" 2)) }`
+	if updates.Michelson() != expected {
+		t.Errorf("expected update operators %s, got %s", expected, updates.Michelson())
+	}
+	if _, err := fa2BalanceOfParameters([]FA2BalanceRequest{{Owner: "tz1a", TokenID: -1}}, "KT1c"); err == nil {
+		t.Errorf("expected an error for a negative token id")
+	}
+
+	plain, _ := ParseMichelson(`list (or (pair (address :owner) (pair address nat)) (pair address (pair address nat)))`)
+	if !sameMichelineType(plain, types["update_operators"]) {
+		t.Errorf("expected types to match ignoring annotations")
+	}
+	if sameMichelineType(types["transfer"], types["update_operators"]) {
+		t.Errorf("expected types to differ")
+	}
+
+	response, _ := ParseMichelson(`{ Pair (Pair "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" 3) 100 }`)
+	var balances []FA2Balance
+	if err := UnmarshalMicheline(response, types["balance_of"].Args[1].Args[0], &balances); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(balances) != 1 || balances[0].Request.Owner != "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" || balances[0].Request.TokenID != 3 || balances[0].Balance.Int64() != 100 {
+		t.Errorf("wrong balances %+v", balances)
+	}
+}
+
+func TestFA2Service(t *testing.T) {
+	code, _ := ParseMichelson(`{ parameter unit ;
+		storage (pair (big_map %ledger (pair address nat) nat) (big_map %token_metadata nat (pair (nat %token_id) (map %token_info string bytes)))) ;
+		code { FAILWITH } }`)
+	storage, _ := ParseMichelson(`Pair 12 13`)
+	nat, _ := ParseMichelson(`nat`)
+	hash, err := ScriptExpressionHash(NewMichelineInt(big.NewInt(0)), nat)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	metadata, _ := ParseMichelson(`Pair 0 { Elt "name" 0x546f6b656e }`)
+
+	gt, closeFixture := newFixtureGoTezos(t, map[string]interface{}{
+		"/chains/main/blocks/head/context/contracts/KT1Fa2/script": map[string]Micheline{"code": code, "storage": storage},
+		"/chains/main/blocks/head/context/big_maps/13/" + hash:     metadata,
+	})
+	defer closeFixture()
+
+	token, err := gt.FA2.GetTokenMetadata("KT1Fa2", 0, "head")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if token.TokenID != 0 || string(token.Info["name"]) != "Token" {
+		t.Errorf("wrong metadata %+v", token)
+	}
+
+	if _, err := gt.FA2.GetTokenMetadata("KT1Fa2", 1, "head"); err == nil {
+		t.Errorf("expected an error for an unknown token")
+	}
+}
+
+func TestMultisig(t *testing.T) {
+	var accounts AccountService
+	wallet, err := accounts.ImportWallet("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ", "edpkunwa7a3Y5vDr9eoKy4E21pzonuhqvNjscT9XG27aQV4gXq4dNm", "edsk362Ypv3qLgbnGvZK7JwqNbwiLGe18XhTMFQY4gUonqnaCPiT6X")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if hash, err := publicKeyHash(wallet.Pk); err != nil || hash != wallet.Address {
+		t.Errorf("expected public key hash %s, got %s, err %v", wallet.Address, hash, err)
+	}
+
+	action, err := MultisigTransfer("tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1", 1000000)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected := `Left { DROP ; NIL operation ; PUSH key_hash "tz1Qny7jVMGiwRrP9FikRK95jTNbJcffTpx1" ; IMPLICIT_ACCOUNT ; PUSH mutez 1000000 ; UNIT ; TRANSFER_TOKENS ; CONS }`
+	if parsed, _ := ParseMichelson(expected); action.Michelson() != parsed.Michelson() {
+		t.Errorf("expected action %s, got %s", expected, action.Michelson())
+	}
+
+	payload, err := newMultisigPayload("NetXdQprcVkpaWU", "KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", 3, action)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	unpacked, err := Unpack(payload.Bytes)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if unpacked.Args[1].Args[0].Int.Int64() != 3 || len(unpacked.Args[0].Args[0].Bytes) != 4 {
+		t.Errorf("wrong packed payload %s", unpacked.Michelson())
+	}
+
+	keys := []string{"edpkuBknW28nW72KG6RoHtYW7p12T6GKc7nAbwYX5m8Wd9sDVC9yav", wallet.Pk}
+	signatures, err := CollectSignatures(payload, keys, wallet)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if signatures[0] != "" || signatures[1] == "" {
+		t.Fatalf("wrong signatures %v", signatures)
+	}
+	signature, _ := signatureBytes(signatures[1])
+	hash := blake2b.Sum256(payload.Bytes)
+	if !ed25519.Verify(wallet.Kp.PubKey, hash[:], signature) {
+		t.Errorf("invalid signature of payload")
+	}
+
+	if _, err := multisigMainParameters(payload, signatures, MultisigStorage{Counter: 3, Threshold: 2, Keys: keys}); err == nil {
+		t.Errorf("expected an error below the threshold")
+	}
+	if _, err := multisigMainParameters(payload, signatures, MultisigStorage{Counter: 4, Threshold: 1, Keys: keys}); err == nil {
+		t.Errorf("expected an error for a stale counter")
+	}
+	parameters, err := multisigMainParameters(payload, signatures, MultisigStorage{Counter: 3, Threshold: 1, Keys: keys})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if sigs := parameters.Args[1].Seq; len(sigs) != 2 || sigs[0].Prim != "None" || sigs[1].Prim != "Some" {
+		t.Errorf("wrong signatures parameter %s", parameters.Args[1].Michelson())
+	}
+}
+
+func TestMultisigStorage(t *testing.T) {
+	code, _ := ParseMichelson(`{ parameter unit ; storage (pair (nat %stored_counter) (pair (nat %threshold) (list %keys key))) ; code { FAILWITH } }`)
+	storage, _ := ParseMichelson(`Pair 3 (Pair 1 { "edpkunwa7a3Y5vDr9eoKy4E21pzonuhqvNjscT9XG27aQV4gXq4dNm" })`)
+
+	gt, closeFixture := newFixtureGoTezos(t, map[string]interface{}{
+		"/chains/main/blocks/head/context/contracts/KT1Multisig/script": map[string]Micheline{"code": code, "storage": storage},
+	})
+	defer closeFixture()
+
+	multisig, err := gt.Multisig.GetStorage("KT1Multisig", "head")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if multisig.Counter != 3 || multisig.Threshold != 1 || len(multisig.Keys) != 1 || multisig.Keys[0] != "edpkunwa7a3Y5vDr9eoKy4E21pzonuhqvNjscT9XG27aQV4gXq4dNm" {
+		t.Errorf("wrong storage %+v", multisig)
+	}
+}
+
+func TestMultisigService(t *testing.T) {
+	gt, err := NewGoTezos("http://127.0.0.1:8732")
+	if err != nil {
+		t.Errorf("could not connect to network")
+	}
+
+	// The payloads packed locally must be the bytes the contract packs and checks the signatures against
+	typ, _ := ParseMichelson(multisigPayloadType)
+	transfer, _ := MultisigTransfer("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ", 1000000)
+	delegate, _ := MultisigSetDelegate("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ")
+	for _, action := range []Micheline{transfer, delegate} {
+		payload, err := newMultisigPayload("NetXdQprcVkpaWU", "KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", 3, action)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		value := NewMichelinePrim("Pair", []Micheline{
+			NewMichelinePrim("Pair", []Micheline{NewMichelineString("NetXdQprcVkpaWU"), NewMichelineString("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn")}),
+			NewMichelinePrim("Pair", []Micheline{NewMichelineInt(big.NewInt(3)), action}),
+		})
+		expected, err := gt.Contract.PackData(value, typ)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if hex.EncodeToString(payload.Bytes) != hex.EncodeToString(expected) {
+			t.Errorf("packed payload %x, node packed %x", payload.Bytes, expected)
+		}
+	}
+}
+
+func TestManagerLambdas(t *testing.T) {
+	cases := []struct {
+		lambda   func() (Micheline, error)
+		expected string
+	}{
+		{
+			func() (Micheline, error) { return TransferLambda("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", 5) },
+			`{ DROP ; NIL operation ; PUSH address "KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn" ; CONTRACT unit ;
+				IF_NONE { { UNIT ; FAILWITH } } {} ; PUSH mutez 5 ; PUSH unit Unit ; TRANSFER_TOKENS ; CONS }`,
+		},
+		{
+			func() (Micheline, error) {
+				return ContractCallLambda("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "mint", NewMichelinePrim("nat", nil), NewMichelineInt(big.NewInt(7)), 0)
+			},
+			`{ DROP ; NIL operation ; PUSH address "KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn" ; CONTRACT %mint nat ;
+				IF_NONE { { UNIT ; FAILWITH } } {} ; PUSH mutez 0 ; PUSH nat 7 ; TRANSFER_TOKENS ; CONS }`,
+		},
+		{
+			func() (Micheline, error) { return SetDelegateLambda("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ") },
+			`{ DROP ; NIL operation ; PUSH key_hash "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" ; SOME ; SET_DELEGATE ; CONS }`,
+		},
+		{
+			func() (Micheline, error) { return RemoveDelegateLambda(), nil },
+			`{ DROP ; NIL operation ; NONE key_hash ; SET_DELEGATE ; CONS }`,
+		},
+	}
+
+	for _, c := range cases {
+		lambda, err := c.lambda()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		expected, err := ParseMichelson(c.expected)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if lambda.Michelson() != expected.Michelson() {
+			t.Errorf("expected lambda %s, got %s", expected.Michelson(), lambda.Michelson())
+		}
+	}
+
+	if _, err := SetDelegateLambda("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn"); err == nil {
+		t.Errorf("expected an error delegating to a contract")
+	}
+
+	// manager.tz stores its manager as an optimized key hash
+	keyHash, _ := ParseMichelson(`key_hash`)
+	stored := NewMichelineBytes([]byte{0, 0x5d, 0x35, 0x6c, 0x17, 0x24, 0xfa, 0xaf, 0x49, 0x00, 0x56, 0xf2, 0xc2, 0x17, 0x8c, 0x2d, 0xf0, 0x14, 0x34, 0xab, 0x87})
+	var manager string
+	if err := UnmarshalMicheline(stored, keyHash, &manager); err != nil || manager != "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ" {
+		t.Errorf("wrong manager %s, err %v", manager, err)
+	}
+}
+
+func TestTypecheck(t *testing.T) {
+	manager, err := ParseMichelson(`parameter (or (lambda %do unit (list operation)) (unit %default)) ;
+		storage key_hash ;
+		code { { { DUP ; CAR ; DIP { CDR } } } ;
+		       IF_LEFT
+		         { PUSH mutez 0 ; AMOUNT ;
+		           { { COMPARE ; EQ } ; IF {} { { UNIT ; FAILWITH } } } ;
+		           { DIP { DUP } ; SWAP } ;
+		           IMPLICIT_ACCOUNT ; ADDRESS ; SENDER ;
+		           { { COMPARE ; EQ } ; IF {} { { UNIT ; FAILWITH } } } ;
+		           UNIT ; EXEC ; PAIR }
+		         { DROP ; NIL operation ; PAIR } }`)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	script := Script{Code: manager, Storage: NewMichelineString("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ")}
+	if err := script.Typecheck(); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	lambda, _ := SetDelegateLambda("tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ")
+	if err := script.TypecheckParameter("do", lambda); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := script.TypecheckParameter("", NewMichelinePrim("Unit", nil)); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := script.TypecheckParameter("default", NewMichelinePrim("Left", []Micheline{lambda})); err == nil {
+		t.Errorf("expected an error for a parameter of the wrong entrypoint")
+	}
+
+	sections := []struct {
+		parameter, storage string
+		valid              bool
+	}{
+		{"unit", "big_map nat (lambda unit (list operation))", true},
+		{"contract unit", "unit", true},
+		{"operation", "unit", false},
+		{"unit", "list operation", false},
+		{"big_map nat nat", "unit", false},
+		{"unit", "option (contract unit)", false},
+	}
+	for _, c := range sections {
+		code, err := ParseMichelson(`parameter (` + c.parameter + `) ; storage (` + c.storage + `) ; code { FAILWITH }`)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		err = TypecheckScript(code)
+		if c.valid && err != nil {
+			t.Errorf("parameter %s and storage %s: %s", c.parameter, c.storage, err)
+		}
+		if typeErr, ok := err.(*TypeError); !c.valid && (!ok || typeErr.Kind != TypeErrorInvalidType) {
+			t.Errorf("parameter %s and storage %s: expected an invalid type error, got %v", c.parameter, c.storage, err)
+		}
+	}
+
+	cases := []struct {
+		code     string
+		arg, ret string
+		kind     string
+		location int
+	}{
+		{`{ DROP ; PUSH nat 1 }`, "unit", "nat", "", 0},
+		{`{ DROP ; FOO }`, "unit", "nat", TypeErrorUnknownPrimitive, 2},
+		{`{ PUSH nat -1 ; DROP }`, "unit", "unit", TypeErrorInvalidData, 3},
+		{`{ DROP ; PUSH int 1 }`, "unit", "nat", TypeErrorBadStack, 0},
+		{`{ DUP ; IF { PUSH nat 1 } { PUSH int 1 } ; DROP }`, "bool", "bool", TypeErrorUnmatchedBranches, 7},
+		{`{ FAILWITH ; DROP }`, "unit", "unit", TypeErrorFailNotInTail, 2},
+		{`{ DROP ; UNIT ; ADD }`, "nat", "nat", TypeErrorBadStack, 3},
+		{`{ DROP ; PUSH (set nat) { 2 ; 1 } ; SIZE }`, "unit", "nat", TypeErrorInvalidData, 7},
+		{`{ DROP ; SELF ; ADDRESS }`, "unit", "address", TypeErrorSelfInLambda, 2},
+		{`{ DIP 2 { DROP } }`, "unit", "unit", TypeErrorBadStack, 1},
+		{`{ DUP ; DIP { FAILWITH } }`, "unit", "unit", TypeErrorFailNotInTail, 2},
+		{`{ LAMBDA nat nat { PUSH nat 1 ; ADD } ; SWAP ; DROP ; PUSH nat 2 ; EXEC }`, "unit", "nat", "", 0},
+		{`{ DROP ; EMPTY_MAP (pair nat nat) unit ; PUSH (pair nat nat) (Pair 1 2) ; GET ; IF_NONE { UNIT } {} }`, "unit", "unit", "", 0},
+		{`{ DROP ; EMPTY_SET (lambda unit unit) ; SIZE }`, "unit", "nat", TypeErrorComparable, 3},
+	}
+
+	for _, c := range cases {
+		code, err := ParseMichelson(c.code)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		arg, _ := ParseMichelson(c.arg)
+		ret, _ := ParseMichelson(c.ret)
+
+		err = TypecheckLambda(code, arg, ret)
+		if c.kind == "" {
+			if err != nil {
+				t.Errorf("%s: %s", c.code, err)
+			}
+			continue
+		}
+		typeErr, ok := err.(*TypeError)
+		if !ok {
+			t.Errorf("%s: expected a type error, got %v", c.code, err)
+			continue
+		}
+		if typeErr.Kind != c.kind || typeErr.Location != c.location {
+			t.Errorf("%s: expected %s at location %d, got %s", c.code, c.kind, c.location, typeErr)
+		}
+	}
+
+	lambdaType, _ := ParseMichelson(LambdaType)
+	transfer, _ := TransferLambda("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", 5)
+	call, _ := ContractCallLambda("KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn", "mint", NewMichelinePrim("nat", nil), NewMichelineInt(big.NewInt(7)), 0)
+	for _, lambda := range []Micheline{lambda, transfer, call, RemoveDelegateLambda()} {
+		if err := TypecheckData(lambda, lambdaType); err != nil {
+			t.Errorf("%s", err)
+		}
+	}
+
+	typ, _ := ParseMichelson(`pair (map string nat) (option address)`)
+	value, _ := ParseMichelson(`Pair { Elt "a" 1 ; Elt "b" 2 } (Some "tz1U8sXoQWGUMQrfZeAYwAzMZUvWwy7mfpPQ")`)
+	if err := TypecheckData(value, typ); err != nil {
+		t.Errorf("%s", err)
+	}
+	value, _ = ParseMichelson(`Pair { Elt "a" 1 ; Elt "b" 2 } (Some "tz1invalid")`)
+	if err, ok := TypecheckData(value, typ).(*TypeError); !ok || err.Location != 9 {
+		t.Errorf("expected an invalid address at location 9, got %v", err)
+	}
+}
+
+func TestParseTez(t *testing.T) {
+	var cases = []struct {
+		in    string
+		out   Mutez
+		tez   string
+		valid bool
+	}{
+		{"1.5", 1500000, "1.5", true},
+		{"0.000001", 1, "0.000001", true},
+		{"-2", -2000000, "-2", true},
+		{"1000", 1000000000, "1000", true},
+		{"1.0000001", 0, "", false},
+		{".5", 0, "", false},
+		{"1.-5", 0, "", false},
+		{"abc", 0, "", false},
+	}
+
+	for _, c := range cases {
+		m, err := ParseTez(c.in)
+		if (err == nil) != c.valid {
+			t.Errorf("ParseTez(%q) error = %v, want valid %v", c.in, err, c.valid)
+			continue
+		}
+		if m != c.out {
+			t.Errorf("ParseTez(%q) = %d, want %d", c.in, m, c.out)
+		}
+		if c.valid && m.Tez() != c.tez {
+			t.Errorf("Tez() of %d = %q, want %q", m, m.Tez(), c.tez)
+		}
+	}
+}
+
+func TestMutezJSON(t *testing.T) {
+	var v struct {
+		Amount Mutez `json:"amount"`
+	}
+
+	err := json.Unmarshal([]byte(`{"amount":"9223372036854775807"}`), &v)
+	if err != nil || v.Amount != Mutez(9223372036854775807) {
+		t.Errorf("could not unmarshal mutez: %v %d", err, v.Amount)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil || string(b) != `{"amount":"9223372036854775807"}` {
+		t.Errorf("could not marshal mutez: %v %s", err, string(b))
+	}
+
+	if _, err = v.Amount.Add(1); err == nil {
+		t.Errorf("expected overflow adding to %d", v.Amount)
+	}
+
+	share, err := v.Amount.MulDiv(3, 4)
+	if err != nil || share != Mutez(6917529027641081855) {
+		t.Errorf("MulDiv = %d, %v", share, err)
+	}
+
+	rate, err := ParseRate("0.3")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	fee, err := Mutez(1000000).MulRate(rate)
+	if err != nil || fee != Mutez(300000) {
+		t.Errorf("MulRate = %d, %v", fee, err)
+	}
+
+	var m Mutez
+	if err := json.Unmarshal([]byte(`"5"`), &m); err != nil || m != 5 {
+		t.Errorf("could not unmarshal mutez: %v %d", err, m)
+	}
+	for _, in := range []string{`5`, `"5`, `5"`, `""`, `"-"`} {
+		var m Mutez
+		if err := m.UnmarshalJSON([]byte(in)); err == nil {
+			t.Errorf("unmarshaled malformed mutez %s", in)
+		}
+	}
+
+	// Amounts of zero are sent for the kinds that have them
+	contents, err := json.Marshal([]StructContents{
+		{Kind: KindTransaction, Destination: "tz1b"},
+		{Kind: KindBallot, Ballot: BallotYay},
+	})
+	if err != nil || string(contents) != `[{"kind":"transaction","destination":"tz1b","fee":"0","amount":"0"},{"kind":"ballot","ballot":"yay"}]` {
+		t.Errorf("unexpected contents %s: %v", contents, err)
+	}
+
+	var rewards FrozenBalanceRewards
+	if _, err := rewards.unmarshalJSON([]byte(`{"deposits":"1","fees":"2"}`)); err == nil {
+		t.Errorf("unmarshaled frozen balance without rewards")
+	}
+	rewards, err = rewards.unmarshalJSON([]byte(`{"deposits":"1","fees":"2","rewards":"3"}`))
+	if err != nil || rewards.Rewards != 3 || rewards.Fees != 2 {
+		t.Errorf("could not unmarshal frozen balance: %v %v", err, rewards)
+	}
+}
+
+//Takes an interface v and returns a pretty json string.
+func PrettyReport(v interface{}) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		return string(b)
+	}
+	return ""
+}
+
+// newFixtureGoTezos returns a GoTezos using a local RPC server, which answers the paths of responses with their
+// JSON encoding and empty network constants
+func newFixtureGoTezos(t *testing.T, responses map[string]interface{}) (*GoTezos, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chains/main/blocks/head/context/constants" {
+			w.Write([]byte("{}"))
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	gt, err := NewGoTezos(server.URL)
+	if err != nil {
+		server.Close()
+		t.Fatalf("%s", err)
+	}
+	return gt, server.Close
 }
@@ -0,0 +1,168 @@
+package gotezos
+
+import "github.com/pkg/errors"
+
+// Ballots that can be cast with SubmitBallot
+const (
+	BallotYay  = "yay"
+	BallotNay  = "nay"
+	BallotPass = "pass"
+)
+
+// Kinds of voting periods of the amendment process, as found in StructMetadata.VotingPeriodKind
+const (
+	VotingPeriodProposal      = "proposal"
+	VotingPeriodTestingVote   = "testing_vote"
+	VotingPeriodTesting       = "testing"
+	VotingPeriodPromotionVote = "promotion_vote"
+)
+
+// SubmitProposals forges, signs and injects a proposals operation from the delegate of wallet, proposing or upvoting
+// protocol hashes. It returns an error unless the current voting period is a proposal period with blocks left, and
+// the proposals are distinct and at most max_proposals_per_delegate. Proposals already made by the delegate in the
+// period also count toward that limit, which only the node checks.
+func (o *OperationService) SubmitProposals(proposals []string, wallet Wallet) (string, error) {
+	if len(proposals) == 0 {
+		return "", errors.New("could not submit proposals, no proposals given")
+	}
+	if len(proposals) > o.gt.Constants.MaxProposalsPerDelegate {
+		return "", errors.Errorf("could not submit proposals, %d proposals exceed the maximum of %d per delegate", len(proposals), o.gt.Constants.MaxProposalsPerDelegate)
+	}
+
+	seen := make(map[string]bool)
+	for _, proposal := range proposals {
+		if _, err := decodeBase58(proposal, protocolPrefix, 32); err != nil {
+			return "", errors.Errorf("could not submit proposals, invalid protocol hash '%s'", proposal)
+		}
+		if seen[proposal] {
+			return "", errors.Errorf("could not submit proposals, protocol %s is proposed twice", proposal)
+		}
+		seen[proposal] = true
+	}
+
+	blockHead, period, err := o.votingPeriod(VotingPeriodProposal)
+	if err != nil {
+		return "", errors.Wrap(err, "could not submit proposals")
+	}
+
+	operation := StructContents{
+		Kind:      "proposals",
+		Source:    wallet.Address,
+		Period:    &period,
+		Proposals: proposals,
+	}
+
+	opHash, err := o.injectVotingOperation(blockHead, operation, wallet)
+	if err != nil {
+		return "", errors.Wrap(err, "could not submit proposals")
+	}
+
+	return opHash, nil
+}
+
+// SubmitBallot forges, signs and injects a ballot operation from the delegate of wallet, voting yay, nay or pass on
+// proposal. It returns an error unless the current voting period is a testing vote or promotion vote period with
+// blocks left, and proposal is the proposal being voted on.
+func (o *OperationService) SubmitBallot(proposal string, ballot string, wallet Wallet) (string, error) {
+	if _, ok := ballotTags[ballot]; !ok {
+		return "", errors.Errorf("could not submit ballot, invalid ballot '%s'", ballot)
+	}
+
+	blockHead, period, err := o.votingPeriod(VotingPeriodTestingVote, VotingPeriodPromotionVote)
+	if err != nil {
+		return "", errors.Wrap(err, "could not submit ballot")
+	}
+
+	currentProposal, err := o.gt.Voting.GetCurrentProposal(blockHead.Hash)
+	if err != nil {
+		return "", errors.Wrap(err, "could not submit ballot")
+	}
+	if proposal != currentProposal {
+		return "", errors.Errorf("could not submit ballot, proposal %s is not the current proposal %s", proposal, currentProposal)
+	}
+
+	operation := StructContents{
+		Kind:     "ballot",
+		Source:   wallet.Address,
+		Period:   &period,
+		Proposal: proposal,
+		Ballot:   ballot,
+	}
+
+	opHash, err := o.injectVotingOperation(blockHead, operation, wallet)
+	if err != nil {
+		return "", errors.Wrap(err, "could not submit ballot")
+	}
+
+	return opHash, nil
+}
+
+// votingPeriod returns the head block and the index of the current voting period, or an error if the period is not
+// of one of kinds. The operation is included in the block after the head, so the last block of a period is refused.
+func (o *OperationService) votingPeriod(kinds ...string) (Block, int, error) {
+	blockHead, err := o.gt.Block.GetHead()
+	if err != nil {
+		return blockHead, 0, err
+	}
+
+	metadata := blockHead.Metadata
+	allowed := false
+	for _, kind := range kinds {
+		allowed = allowed || metadata.VotingPeriodKind == kind
+	}
+	if !allowed {
+		return blockHead, 0, errors.Errorf("voting period %d is a %s period", metadata.Level.VotingPeriod, metadata.VotingPeriodKind)
+	}
+
+	if metadata.Level.VotingPeriodPosition >= o.gt.Constants.BlocksPerVotingPeriod-1 {
+		return blockHead, 0, errors.Errorf("voting period %d ends with the head block", metadata.Level.VotingPeriod)
+	}
+
+	return blockHead, metadata.Level.VotingPeriod, nil
+}
+
+// injectVotingOperation forges, signs, preapplies and injects a proposals or ballot operation. Voting operations have
+// no fee, counter or limits, and are forged locally as well to check the bytes forged by the node.
+func (o *OperationService) injectVotingOperation(blockHead Block, operation StructContents, wallet Wallet) (string, error) {
+	contents := Conts{Contents: []StructContents{operation}, Branch: blockHead.Hash}
+
+	operationBytes, err := o.forgeOperation(contents)
+	if err != nil {
+		return "", err
+	}
+
+	err = verifyForgedOperation(UnsignedOperation{Branch: contents.Branch, Contents: contents.Contents, OperationBytes: operationBytes})
+	if err != nil {
+		return "", err
+	}
+
+	signature, fullOperation, err := signOperation(operationBytes, wallet)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = o.preApplyOperations(contents, signature, blockHead)
+	if err != nil {
+		return "", err
+	}
+
+	opHash, err := operationHash(fullOperation)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := o.InjectOperation(fullOperation)
+	if err != nil {
+		return "", err
+	}
+
+	injectedHash, err := unmarshalString(resp)
+	if err != nil {
+		return "", err
+	}
+	if injectedHash != opHash {
+		return "", errors.Errorf("injected operation hash '%s' does not match computed hash '%s'", injectedHash, opHash)
+	}
+
+	return opHash, nil
+}
@@ -0,0 +1,168 @@
+package gotezos
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Journal records the progress of the batches of payout runs, so a run can be resumed without paying anyone
+// twice. Record must persist the batch before returning, since batches are recorded before they are injected.
+type Journal interface {
+	Record(run string, batch PayoutBatch) error
+	Batches(run string) ([]PayoutBatch, error)
+}
+
+// FileJournal is a Journal appending the batches of each payout run as JSON lines to a file named after the run
+type FileJournal struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileJournal returns a FileJournal keeping its files in dir, which is created if needed
+func NewFileJournal(dir string) (*FileJournal, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create journal directory '%s'", dir)
+	}
+	return &FileJournal{dir: dir}, nil
+}
+
+// Record appends the state of a batch to the file of run and syncs it to disk. A partially written last line is
+// removed first.
+func (j *FileJournal) Record(run string, batch PayoutBatch) error {
+	path, err := j.path(run)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Wrapf(err, "could not record batch %d of payout run '%s'", batch.Index, run)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not record batch %d of payout run '%s'", batch.Index, run)
+	}
+	defer f.Close()
+
+	err = truncatePartialLine(f)
+	if err != nil {
+		return errors.Wrapf(err, "could not record batch %d of payout run '%s'", batch.Index, run)
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "could not record batch %d of payout run '%s'", batch.Index, run)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "could not record batch %d of payout run '%s'", batch.Index, run)
+	}
+
+	return nil
+}
+
+// Batches returns the latest state of every batch recorded for run, ordered by index. A run that was never
+// recorded has no batches. A partially written last line is ignored, and any other invalid line is an error.
+func (j *FileJournal) Batches(run string) ([]PayoutBatch, error) {
+	path, err := j.path(run)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []PayoutBatch{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read journal of payout run '%s'", run)
+	}
+	defer f.Close()
+
+	// A crash may leave a partially written last line, which was never acknowledged as recorded. Any other line
+	// that cannot be read means the journal is corrupt.
+	var partial error
+	latest := make(map[int]PayoutBatch)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var batch PayoutBatch
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		if partial != nil {
+			return nil, errors.Wrapf(partial, "could not read journal of payout run '%s'", run)
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+			partial = errors.Wrapf(err, "invalid line %d", line)
+			continue
+		}
+		latest[batch.Index] = batch
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "could not read journal of payout run '%s'", run)
+	}
+
+	batches := make([]PayoutBatch, 0, len(latest))
+	for _, batch := range latest {
+		batches = append(batches, batch)
+	}
+	sort.Slice(batches, func(i, k int) bool { return batches[i].Index < batches[k].Index })
+
+	return batches, nil
+}
+
+// truncatePartialLine removes a partially written last line from a journal file, left by a crash while recording
+// a batch that was never acknowledged as recorded
+func truncatePartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return f.Truncate(int64(bytes.LastIndexByte(contents, '\n') + 1))
+}
+
+// path returns the file of run, refusing run names that would escape the journal directory
+func (j *FileJournal) path(run string) (string, error) {
+	if run == "" || run != filepath.Base(run) || run == "." || run == ".." {
+		return "", errors.Errorf("invalid payout run name '%s'", run)
+	}
+	return filepath.Join(j.dir, run+".jsonl"), nil
+}
@@ -0,0 +1,85 @@
+package gotezos
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// LambdaType is the type of the lambdas run by manager.tz and the generic multisig contract to emit operations
+const LambdaType = "lambda unit (list operation)"
+
+// TransferLambda returns a lambda transferring an amount to an implicit account, or to the default entrypoint of
+// an originated contract taking unit
+func TransferLambda(destination string, amount Mutez) (Micheline, error) {
+	if _, err := forgePublicKeyHash(destination); err == nil {
+		return NewMichelineSeq(
+			NewMichelinePrim("DROP", nil),
+			NewMichelinePrim("NIL", []Micheline{NewMichelinePrim("operation", nil)}),
+			NewMichelinePrim("PUSH", []Micheline{NewMichelinePrim("key_hash", nil), NewMichelineString(destination)}),
+			NewMichelinePrim("IMPLICIT_ACCOUNT", nil),
+			NewMichelinePrim("PUSH", []Micheline{NewMichelinePrim("mutez", nil), NewMichelineInt(big.NewInt(int64(amount)))}),
+			NewMichelinePrim("UNIT", nil),
+			NewMichelinePrim("TRANSFER_TOKENS", nil),
+			NewMichelinePrim("CONS", nil),
+		), nil
+	}
+
+	return ContractCallLambda(destination, "default", NewMichelinePrim("unit", nil), NewMichelinePrim("Unit", nil), amount)
+}
+
+// ContractCallLambda returns a lambda calling an entrypoint of an originated contract with a parameter of
+// parameterType and an amount. The lambda fails if the contract has no such entrypoint.
+func ContractCallLambda(destination, entrypoint string, parameterType, parameter Micheline, amount Mutez) (Micheline, error) {
+	if _, err := forgeContractID(destination); err != nil {
+		return Micheline{}, errors.Wrapf(err, "could not build contract call lambda to %s", destination)
+	}
+
+	contract := NewMichelinePrim("CONTRACT", []Micheline{parameterType})
+	if entrypoint != "" && entrypoint != "default" {
+		contract.Annots = []string{"%" + entrypoint}
+	}
+
+	return NewMichelineSeq(
+		NewMichelinePrim("DROP", nil),
+		NewMichelinePrim("NIL", []Micheline{NewMichelinePrim("operation", nil)}),
+		NewMichelinePrim("PUSH", []Micheline{NewMichelinePrim("address", nil), NewMichelineString(destination)}),
+		contract,
+		// ASSERT_SOME expanded
+		NewMichelinePrim("IF_NONE", []Micheline{
+			NewMichelineSeq(NewMichelineSeq(NewMichelinePrim("UNIT", nil), NewMichelinePrim("FAILWITH", nil))),
+			NewMichelineSeq(),
+		}),
+		NewMichelinePrim("PUSH", []Micheline{NewMichelinePrim("mutez", nil), NewMichelineInt(big.NewInt(int64(amount)))}),
+		NewMichelinePrim("PUSH", []Micheline{parameterType, parameter}),
+		NewMichelinePrim("TRANSFER_TOKENS", nil),
+		NewMichelinePrim("CONS", nil),
+	), nil
+}
+
+// SetDelegateLambda returns a lambda setting the delegate of the contract running it
+func SetDelegateLambda(delegate string) (Micheline, error) {
+	if _, err := forgePublicKeyHash(delegate); err != nil {
+		return Micheline{}, errors.Wrapf(err, "could not build set delegate lambda to %s", delegate)
+	}
+
+	return NewMichelineSeq(
+		NewMichelinePrim("DROP", nil),
+		NewMichelinePrim("NIL", []Micheline{NewMichelinePrim("operation", nil)}),
+		NewMichelinePrim("PUSH", []Micheline{NewMichelinePrim("key_hash", nil), NewMichelineString(delegate)}),
+		NewMichelinePrim("SOME", nil),
+		NewMichelinePrim("SET_DELEGATE", nil),
+		NewMichelinePrim("CONS", nil),
+	), nil
+}
+
+// RemoveDelegateLambda returns a lambda removing the delegate of the contract running it
+func RemoveDelegateLambda() Micheline {
+	return NewMichelineSeq(
+		NewMichelinePrim("DROP", nil),
+		NewMichelinePrim("NIL", []Micheline{NewMichelinePrim("operation", nil)}),
+		NewMichelinePrim("NONE", []Micheline{NewMichelinePrim("key_hash", nil)}),
+		NewMichelinePrim("SET_DELEGATE", nil),
+		NewMichelinePrim("CONS", nil),
+	)
+}
@@ -0,0 +1,65 @@
+package gotezos
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// ManagerDo calls the do entrypoint of a manager.tz contract, such as an originated account migrated by Babylon,
+// with a lambda emitting the operations of the contract. The wallet must be the manager stored by the contract.
+func (o *OperationService) ManagerDo(contract string, lambda Micheline, wallet Wallet) (ContractCallResult, error) {
+	var manager string
+	err := o.gt.Contract.GetTypedStorage(contract, "head", &manager)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not call manager contract %s", contract)
+	}
+	if manager != wallet.Address {
+		return ContractCallResult{}, errors.Errorf("could not call manager contract %s, it is managed by %s and not %s", contract, manager, wallet.Address)
+	}
+
+	parameters, err := json.Marshal(lambda)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not call manager contract %s", contract)
+	}
+
+	result, err := o.Call(ContractCall{Destination: contract, Entrypoint: "do", Parameters: parameters}, wallet)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not call manager contract %s", contract)
+	}
+	return result, nil
+}
+
+// ManagerTransfer transfers an amount from a manager.tz contract to an implicit account, or to an originated
+// contract taking unit
+func (o *OperationService) ManagerTransfer(contract, destination string, amount Mutez, wallet Wallet) (ContractCallResult, error) {
+	lambda, err := TransferLambda(destination, amount)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not transfer from manager contract %s", contract)
+	}
+	return o.ManagerDo(contract, lambda, wallet)
+}
+
+// ManagerCall calls an entrypoint of an originated contract from a manager.tz contract with a parameter of
+// parameterType and an amount
+func (o *OperationService) ManagerCall(contract, destination, entrypoint string, parameterType, parameter Micheline, amount Mutez, wallet Wallet) (ContractCallResult, error) {
+	lambda, err := ContractCallLambda(destination, entrypoint, parameterType, parameter, amount)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not call %s from manager contract %s", destination, contract)
+	}
+	return o.ManagerDo(contract, lambda, wallet)
+}
+
+// ManagerSetDelegate sets the delegate of a manager.tz contract
+func (o *OperationService) ManagerSetDelegate(contract, delegate string, wallet Wallet) (ContractCallResult, error) {
+	lambda, err := SetDelegateLambda(delegate)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not set delegate of manager contract %s", contract)
+	}
+	return o.ManagerDo(contract, lambda, wallet)
+}
+
+// ManagerRemoveDelegate removes the delegate of a manager.tz contract
+func (o *OperationService) ManagerRemoveDelegate(contract string, wallet Wallet) (ContractCallResult, error) {
+	return o.ManagerDo(contract, RemoveDelegateLambda(), wallet)
+}
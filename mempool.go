@@ -0,0 +1,189 @@
+package gotezos
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// MempoolService is a struct wrapper for the mempool of the node
+type MempoolService struct {
+	gt *GoTezos
+}
+
+// MempoolFilter is the fee policy a node applies to operations entering its mempool
+type MempoolFilter struct {
+	MinimalFees              Mutez  `json:"minimal_fees"`
+	MinimalNanotezPerGasUnit string `json:"minimal_nanotez_per_gas_unit"`
+	MinimalNanotezPerByte    string `json:"minimal_nanotez_per_byte"`
+	AllowScriptFailure       bool   `json:"allow_script_failure"`
+}
+
+// MempoolOperation is an operation pending in the mempool of the node. Status is the list the operation is in,
+// and Errors explain why the mempool did not apply it. Unprocessed operations are not decoded by the node yet, so
+// they only have their hash, branch and forged Data.
+type MempoolOperation struct {
+	Operation
+	Status string              `json:"status"`
+	Errors []StructResultError `json:"error,omitempty"`
+	Data   string              `json:"data,omitempty"`
+}
+
+// PendingOperations are the operations pending in the mempool of the node, by status
+type PendingOperations struct {
+	Applied       []MempoolOperation
+	Refused       []MempoolOperation
+	BranchRefused []MempoolOperation
+	BranchDelayed []MempoolOperation
+	Unprocessed   []MempoolOperation
+}
+
+// newMempoolService returns a new MempoolService
+func (gt *GoTezos) newMempoolService() *MempoolService {
+	return &MempoolService{gt: gt}
+}
+
+// GetPendingOperations gets the operations pending in the mempool of the node
+func (m *MempoolService) GetPendingOperations() (PendingOperations, error) {
+	var pending PendingOperations
+	query := "/chains/main/mempool/pending_operations"
+	resp, err := m.gt.Get(query, nil)
+	if err != nil {
+		return pending, errors.Wrapf(err, "could not get pending operations '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &pending)
+	if err != nil {
+		return pending, errors.Wrapf(err, "could not get pending operations '%s'", query)
+	}
+
+	return pending, nil
+}
+
+// GetFilter gets the fee policy of the mempool of the node
+func (m *MempoolService) GetFilter() (MempoolFilter, error) {
+	var filter MempoolFilter
+	query := "/chains/main/mempool/filter"
+	resp, err := m.gt.Get(query, nil)
+	if err != nil {
+		return filter, errors.Wrapf(err, "could not get mempool filter '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &filter)
+	if err != nil {
+		return filter, errors.Wrapf(err, "could not get mempool filter '%s'", query)
+	}
+
+	return filter, nil
+}
+
+// UnmarshalJSON decodes the pending operations RPC, where operations that were not applied are pairs of hash
+// and operation
+func (p *PendingOperations) UnmarshalJSON(v []byte) error {
+	var raw struct {
+		Applied       []MempoolOperation  `json:"applied"`
+		Refused       [][]json.RawMessage `json:"refused"`
+		BranchRefused [][]json.RawMessage `json:"branch_refused"`
+		BranchDelayed [][]json.RawMessage `json:"branch_delayed"`
+		Unprocessed   [][]json.RawMessage `json:"unprocessed"`
+	}
+	err := json.Unmarshal(v, &raw)
+	if err != nil {
+		return err
+	}
+
+	p.Applied = raw.Applied
+	for k := range p.Applied {
+		p.Applied[k].Status = OperationStatusApplied
+	}
+
+	lists := []struct {
+		status string
+		pairs  [][]json.RawMessage
+		ops    *[]MempoolOperation
+	}{
+		{OperationStatusRefused, raw.Refused, &p.Refused},
+		{OperationStatusBranchRefused, raw.BranchRefused, &p.BranchRefused},
+		{OperationStatusBranchDelayed, raw.BranchDelayed, &p.BranchDelayed},
+		{OperationStatusUnprocessed, raw.Unprocessed, &p.Unprocessed},
+	}
+	for _, list := range lists {
+		*list.ops = make([]MempoolOperation, len(list.pairs))
+		for k, pair := range list.pairs {
+			if len(pair) != 2 {
+				return errors.Errorf("invalid %s operation %s", list.status, pair)
+			}
+			op := &(*list.ops)[k]
+			if err := json.Unmarshal(pair[1], op); err != nil {
+				return errors.Wrapf(err, "invalid %s operation", list.status)
+			}
+			if err := json.Unmarshal(pair[0], &op.Hash); err != nil {
+				return errors.Wrapf(err, "invalid %s operation", list.status)
+			}
+			op.Status = list.status
+		}
+	}
+
+	return nil
+}
+
+// All returns every pending operation, whatever its status
+func (p PendingOperations) All() []MempoolOperation {
+	var all []MempoolOperation
+	all = append(all, p.Applied...)
+	all = append(all, p.Refused...)
+	all = append(all, p.BranchRefused...)
+	all = append(all, p.BranchDelayed...)
+	all = append(all, p.Unprocessed...)
+	return all
+}
+
+// Find returns the pending operation with the hash, if any
+func (p PendingOperations) Find(hash string) (MempoolOperation, bool) {
+	for _, op := range p.All() {
+		if op.Hash == hash {
+			return op, true
+		}
+	}
+	return MempoolOperation{}, false
+}
+
+// BySource returns the pending operations with a content from source
+func (p PendingOperations) BySource(source string) PendingOperations {
+	return p.filter(func(content OperationContents) bool {
+		contentSource, ok := contentsSource(content)
+		return ok && contentSource == source
+	})
+}
+
+// ByDestination returns the pending operations with a content sent to destination
+func (p PendingOperations) ByDestination(destination string) PendingOperations {
+	return p.filter(func(content OperationContents) bool {
+		transaction, ok := content.(*TransactionContents)
+		return ok && transaction.Destination == destination
+	})
+}
+
+// filter returns the pending operations with a content matching match
+func (p PendingOperations) filter(match func(OperationContents) bool) PendingOperations {
+	keep := func(ops []MempoolOperation) []MempoolOperation {
+		var kept []MempoolOperation
+		for _, op := range ops {
+			for _, content := range op.Contents {
+				if match(content) {
+					kept = append(kept, op)
+					break
+				}
+			}
+		}
+		return kept
+	}
+
+	return PendingOperations{
+		Applied:       keep(p.Applied),
+		Refused:       keep(p.Refused),
+		BranchRefused: keep(p.BranchRefused),
+		BranchDelayed: keep(p.BranchDelayed),
+		Unprocessed:   keep(p.Unprocessed),
+	}
+}
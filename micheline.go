@@ -0,0 +1,526 @@
+package gotezos
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Width above which Michelson text is broken into several lines
+	michelsonLineWidth = 80
+)
+
+// Kinds of Micheline nodes. MichelineEmpty is the kind of the zero Micheline, which holds no expression.
+const (
+	MichelineEmpty MichelineKind = iota
+	MichelineInt
+	MichelineString
+	MichelineBytes
+	MichelinePrim
+	MichelineSeq
+)
+
+// MichelineKind is the kind of a Micheline node
+type MichelineKind int
+
+// Micheline is a Micheline expression, the representation of Michelson code and data used by the node. Int,
+// String and Bytes are set for literals of their kind, Prim, Args and Annots for primitive applications, and
+// Seq for sequences.
+type Micheline struct {
+	Kind   MichelineKind
+	Int    *big.Int
+	String string
+	Bytes  []byte
+	Prim   string
+	Args   []Micheline
+	Annots []string
+	Seq    []Micheline
+}
+
+// NewMichelineInt returns an integer literal
+func NewMichelineInt(n *big.Int) Micheline {
+	return Micheline{Kind: MichelineInt, Int: new(big.Int).Set(n)}
+}
+
+// NewMichelineString returns a string literal
+func NewMichelineString(s string) Micheline {
+	return Micheline{Kind: MichelineString, String: s}
+}
+
+// NewMichelineBytes returns a bytes literal
+func NewMichelineBytes(b []byte) Micheline {
+	return Micheline{Kind: MichelineBytes, Bytes: b}
+}
+
+// NewMichelinePrim returns the application of a primitive to args with annotations
+func NewMichelinePrim(prim string, args []Micheline, annots ...string) Micheline {
+	return Micheline{Kind: MichelinePrim, Prim: prim, Args: args, Annots: annots}
+}
+
+// NewMichelineSeq returns a sequence of expressions
+func NewMichelineSeq(items ...Micheline) Micheline {
+	return Micheline{Kind: MichelineSeq, Seq: items}
+}
+
+// michelineJSON is the JSON encoding of a Micheline node that is not a sequence
+type michelineJSON struct {
+	Int    *string     `json:"int,omitempty"`
+	String *string     `json:"string,omitempty"`
+	Bytes  *string     `json:"bytes,omitempty"`
+	Prim   *string     `json:"prim,omitempty"`
+	Args   []Micheline `json:"args,omitempty"`
+	Annots []string    `json:"annots,omitempty"`
+}
+
+// MarshalJSON encodes the expression as Micheline JSON, and the zero Micheline as null
+func (m Micheline) MarshalJSON() ([]byte, error) {
+	var node michelineJSON
+	switch m.Kind {
+	case MichelineEmpty:
+		return []byte("null"), nil
+	case MichelineInt:
+		if m.Int == nil {
+			return nil, errors.New("could not encode micheline, int literal has no value")
+		}
+		s := m.Int.String()
+		node.Int = &s
+	case MichelineString:
+		node.String = &m.String
+	case MichelineBytes:
+		s := hex.EncodeToString(m.Bytes)
+		node.Bytes = &s
+	case MichelinePrim:
+		node.Prim = &m.Prim
+		node.Args = m.Args
+		node.Annots = m.Annots
+	case MichelineSeq:
+		if m.Seq == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(m.Seq)
+	default:
+		return nil, errors.Errorf("could not encode micheline, invalid kind %d", m.Kind)
+	}
+	return json.Marshal(node)
+}
+
+// UnmarshalJSON decodes an expression from Micheline JSON, and null as the zero Micheline
+func (m *Micheline) UnmarshalJSON(v []byte) error {
+	v = bytes.TrimSpace(v)
+	if bytes.Equal(v, []byte("null")) {
+		*m = Micheline{}
+		return nil
+	}
+	if len(v) > 0 && v[0] == '[' {
+		var seq []Micheline
+		if err := json.Unmarshal(v, &seq); err != nil {
+			return err
+		}
+		if seq == nil {
+			seq = []Micheline{}
+		}
+		*m = NewMichelineSeq(seq...)
+		return nil
+	}
+
+	var node michelineJSON
+	if err := json.Unmarshal(v, &node); err != nil {
+		return errors.Wrap(err, "invalid micheline")
+	}
+
+	switch {
+	case node.Int != nil:
+		n, ok := new(big.Int).SetString(*node.Int, 10)
+		if !ok {
+			return errors.Errorf("invalid micheline int '%s'", *node.Int)
+		}
+		*m = Micheline{Kind: MichelineInt, Int: n}
+	case node.String != nil:
+		*m = NewMichelineString(*node.String)
+	case node.Bytes != nil:
+		b, err := hex.DecodeString(*node.Bytes)
+		if err != nil {
+			return errors.Errorf("invalid micheline bytes '%s'", *node.Bytes)
+		}
+		*m = NewMichelineBytes(b)
+	case node.Prim != nil:
+		*m = NewMichelinePrim(*node.Prim, node.Args, node.Annots...)
+	default:
+		return errors.Errorf("invalid micheline node %s", v)
+	}
+	return nil
+}
+
+// Michelson returns the expression as Michelson text, broken into indented lines when it is long
+func (m Micheline) Michelson() string {
+	return m.michelson(0, false)
+}
+
+// compact returns the expression as Michelson text on a single line. Primitive applications with arguments
+// or annotations are wrapped in parentheses when they are the argument of another primitive.
+func (m Micheline) compact(wrapped bool) string {
+	switch m.Kind {
+	case MichelineEmpty:
+		return ""
+	case MichelineInt:
+		if m.Int == nil {
+			return "0"
+		}
+		return m.Int.String()
+	case MichelineString:
+		return quoteMichelson(m.String)
+	case MichelineBytes:
+		return "0x" + hex.EncodeToString(m.Bytes)
+	case MichelineSeq:
+		if len(m.Seq) == 0 {
+			return "{}"
+		}
+		items := make([]string, len(m.Seq))
+		for k, item := range m.Seq {
+			items[k] = item.compact(false)
+		}
+		return "{ " + strings.Join(items, " ; ") + " }"
+	}
+
+	parts := append([]string{m.Prim}, m.Annots...)
+	for _, arg := range m.Args {
+		parts = append(parts, arg.compact(true))
+	}
+	s := strings.Join(parts, " ")
+	if wrapped && (len(m.Args) > 0 || len(m.Annots) > 0) {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// michelson returns the expression as Michelson text starting at column col, breaking sequences and primitive
+// applications that do not fit on the line
+func (m Micheline) michelson(col int, wrapped bool) string {
+	compact := m.compact(wrapped)
+	if col+len(compact) <= michelsonLineWidth {
+		return compact
+	}
+
+	switch m.Kind {
+	case MichelineSeq:
+		items := make([]string, len(m.Seq))
+		for k, item := range m.Seq {
+			items[k] = item.michelson(col+2, false)
+		}
+		return "{ " + strings.Join(items, " ;\n"+strings.Repeat(" ", col+2)) + " }"
+	case MichelinePrim:
+		if len(m.Args) == 0 {
+			return compact
+		}
+		open, close := "", ""
+		if wrapped {
+			open, close = "(", ")"
+		}
+		argCol := col + len(open) + 2
+		s := open + strings.Join(append([]string{m.Prim}, m.Annots...), " ")
+		for _, arg := range m.Args {
+			s += "\n" + strings.Repeat(" ", argCol) + arg.michelson(argCol, true)
+		}
+		return s + close
+	}
+
+	return compact
+}
+
+// quoteMichelson quotes a string literal with the escapes of Michelson
+func quoteMichelson(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ParseMichelson parses Michelson text into a Micheline expression. Several expressions separated by
+// semicolons, such as the parameter, storage and code sections of a script, are parsed into a sequence.
+// Macros are kept as primitives and are not expanded.
+func ParseMichelson(text string) (Micheline, error) {
+	tokens, err := tokenizeMichelson(text)
+	if err != nil {
+		return Micheline{}, errors.Wrap(err, "could not parse michelson")
+	}
+
+	if len(tokens) == 0 {
+		return Micheline{}, errors.New("could not parse michelson, no expression")
+	}
+
+	p := michelsonParser{tokens: tokens}
+	items, separated, err := p.sequence("")
+	if err != nil {
+		return Micheline{}, errors.Wrap(err, "could not parse michelson")
+	}
+	if len(items) == 1 && !separated {
+		return items[0], nil
+	}
+	return NewMichelineSeq(items...), nil
+}
+
+// Kinds of Michelson tokens
+const (
+	michelsonTokenInt = iota
+	michelsonTokenString
+	michelsonTokenBytes
+	michelsonTokenIdent
+	michelsonTokenAnnot
+	michelsonTokenPunct
+)
+
+// michelsonToken is a token of Michelson text at offset pos
+type michelsonToken struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// tokenizeMichelson splits Michelson text into tokens, skipping whitespace and comments
+func tokenizeMichelson(text string) ([]michelsonToken, error) {
+	var tokens []michelsonToken
+	isIdent := func(c byte) bool {
+		return c == '_' || c == '.' || c == '%' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '#':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(text) && text[i+1] == '*':
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.Errorf("unterminated comment at %d", i)
+			}
+			i += end + 4
+
+		case c == '{' || c == '}' || c == '(' || c == ')' || c == ';':
+			tokens = append(tokens, michelsonToken{kind: michelsonTokenPunct, value: string(c), pos: i})
+			i++
+
+		case c == '"':
+			start := i
+			var b strings.Builder
+			for i++; ; i++ {
+				if i >= len(text) || text[i] == '\n' {
+					return nil, errors.Errorf("unterminated string at %d", start)
+				}
+				if text[i] == '"' {
+					i++
+					break
+				}
+				if text[i] != '\\' {
+					b.WriteByte(text[i])
+					continue
+				}
+				if i++; i >= len(text) {
+					return nil, errors.Errorf("unterminated string at %d", start)
+				}
+				escapes := map[byte]byte{'"': '"', '\\': '\\', 'n': '\n', 't': '\t', 'r': '\r', 'b': '\b'}
+				unescaped, ok := escapes[text[i]]
+				if !ok {
+					return nil, errors.Errorf("invalid escape '\\%c' at %d", text[i], i)
+				}
+				b.WriteByte(unescaped)
+			}
+			tokens = append(tokens, michelsonToken{kind: michelsonTokenString, value: b.String(), pos: start})
+
+		case c == '0' && i+1 < len(text) && text[i+1] == 'x':
+			start := i
+			for i += 2; i < len(text) && isIdent(text[i]); i++ {
+			}
+			tokens = append(tokens, michelsonToken{kind: michelsonTokenBytes, value: text[start+2 : i], pos: start})
+
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			for i++; i < len(text) && text[i] >= '0' && text[i] <= '9'; i++ {
+			}
+			tokens = append(tokens, michelsonToken{kind: michelsonTokenInt, value: text[start:i], pos: start})
+
+		case c == '%' || c == '@' || c == ':':
+			start := i
+			for i++; i < len(text) && isIdent(text[i]); i++ {
+			}
+			tokens = append(tokens, michelsonToken{kind: michelsonTokenAnnot, value: text[start:i], pos: start})
+
+		case isIdent(c):
+			start := i
+			for ; i < len(text) && isIdent(text[i]); i++ {
+			}
+			tokens = append(tokens, michelsonToken{kind: michelsonTokenIdent, value: text[start:i], pos: start})
+
+		default:
+			return nil, errors.Errorf("unexpected character '%c' at %d", c, i)
+		}
+	}
+
+	return tokens, nil
+}
+
+// michelsonParser parses a list of Michelson tokens
+type michelsonParser struct {
+	tokens []michelsonToken
+	next   int
+}
+
+// peek returns the next token, or false at the end of the text
+func (p *michelsonParser) peek() (michelsonToken, bool) {
+	if p.next >= len(p.tokens) {
+		return michelsonToken{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+// sequence parses expressions separated by semicolons until the closing punctuation end, or the end of the
+// text when end is empty. It reports whether any semicolon was found.
+func (p *michelsonParser) sequence(end string) ([]Micheline, bool, error) {
+	items := []Micheline{}
+	separated := false
+	for {
+		token, ok := p.peek()
+		if !ok {
+			if end != "" {
+				return nil, false, errors.Errorf("missing '%s' at the end of the text", end)
+			}
+			return items, separated, nil
+		}
+		if token.kind == michelsonTokenPunct && token.value == end {
+			p.next++
+			return items, separated, nil
+		}
+
+		item, err := p.application()
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, item)
+
+		token, ok = p.peek()
+		if ok && token.kind == michelsonTokenPunct && token.value == ";" {
+			p.next++
+			separated = true
+			continue
+		}
+		if ok && !(token.kind == michelsonTokenPunct && token.value == end) {
+			return nil, false, errors.Errorf("expected ';' at %d", token.pos)
+		}
+	}
+}
+
+// application parses a primitive with its annotations and arguments, or a single argument
+func (p *michelsonParser) application() (Micheline, error) {
+	token, ok := p.peek()
+	if !ok {
+		return Micheline{}, errors.New("unexpected end of the text")
+	}
+	if token.kind != michelsonTokenIdent {
+		return p.argument()
+	}
+	p.next++
+
+	prim := NewMichelinePrim(token.value, nil)
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind != michelsonTokenAnnot {
+			break
+		}
+		prim.Annots = append(prim.Annots, token.value)
+		p.next++
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || (token.kind == michelsonTokenPunct && token.value != "{" && token.value != "(") {
+			break
+		}
+		if token.kind == michelsonTokenAnnot {
+			return Micheline{}, errors.Errorf("unexpected annotation '%s' at %d", token.value, token.pos)
+		}
+		arg, err := p.argument()
+		if err != nil {
+			return Micheline{}, err
+		}
+		prim.Args = append(prim.Args, arg)
+	}
+
+	return prim, nil
+}
+
+// argument parses a literal, a sequence, a parenthesized application, or a primitive without arguments
+func (p *michelsonParser) argument() (Micheline, error) {
+	token, ok := p.peek()
+	if !ok {
+		return Micheline{}, errors.New("unexpected end of the text")
+	}
+	p.next++
+
+	switch token.kind {
+	case michelsonTokenInt:
+		n, ok := new(big.Int).SetString(token.value, 10)
+		if !ok {
+			return Micheline{}, errors.Errorf("invalid int '%s' at %d", token.value, token.pos)
+		}
+		return Micheline{Kind: MichelineInt, Int: n}, nil
+	case michelsonTokenString:
+		return NewMichelineString(token.value), nil
+	case michelsonTokenBytes:
+		b, err := hex.DecodeString(token.value)
+		if err != nil {
+			return Micheline{}, errors.Errorf("invalid bytes '0x%s' at %d", token.value, token.pos)
+		}
+		return NewMichelineBytes(b), nil
+	case michelsonTokenIdent:
+		return NewMichelinePrim(token.value, nil), nil
+	case michelsonTokenPunct:
+		switch token.value {
+		case "{":
+			items, _, err := p.sequence("}")
+			if err != nil {
+				return Micheline{}, err
+			}
+			return NewMichelineSeq(items...), nil
+		case "(":
+			expr, err := p.application()
+			if err != nil {
+				return Micheline{}, err
+			}
+			closing, ok := p.peek()
+			if !ok || closing.kind != michelsonTokenPunct || closing.value != ")" {
+				return Micheline{}, errors.Errorf("missing ')' for '(' at %d", token.pos)
+			}
+			p.next++
+			return expr, nil
+		}
+	}
+
+	return Micheline{}, errors.Errorf("unexpected '%s' at %d", token.value, token.pos)
+}
@@ -0,0 +1,236 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+// The type of the payload signed by the keys of the generic multisig contract: the chain id and address of the
+// contract, its counter and the action
+const multisigPayloadType = "pair (pair chain_id address) (pair nat (or (lambda unit (list operation)) (pair nat (list key))))"
+
+// MultisigService is a client of the generic multisig contract
+type MultisigService struct {
+	gt *GoTezos
+}
+
+// MultisigStorage is the storage of a generic multisig contract
+type MultisigStorage struct {
+	Counter   int      `michelson:"stored_counter"`
+	Threshold int      `michelson:"threshold"`
+	Keys      []string `michelson:"keys"`
+}
+
+// MultisigPayload is an action of a generic multisig contract to sign by its keys. Bytes are the packed payload
+// that is signed.
+type MultisigPayload struct {
+	ChainID  string
+	Contract string
+	Counter  int
+	Action   Micheline
+	Bytes    []byte
+}
+
+// newMultisigService returns a new MultisigService
+func (gt *GoTezos) newMultisigService() *MultisigService {
+	return &MultisigService{gt: gt}
+}
+
+// MultisigOperation returns the action of a generic multisig contract running a lambda emitting operations
+func MultisigOperation(lambda Micheline) Micheline {
+	return NewMichelinePrim("Left", []Micheline{lambda})
+}
+
+// MultisigTransfer returns the action of a generic multisig contract transferring an amount to an implicit
+// account or to an originated contract taking unit
+func MultisigTransfer(destination string, amount Mutez) (Micheline, error) {
+	lambda, err := TransferLambda(destination, amount)
+	if err != nil {
+		return lambda, err
+	}
+	return MultisigOperation(lambda), nil
+}
+
+// MultisigSetDelegate returns the action of a generic multisig contract setting its delegate, or removing it when
+// delegate is empty
+func MultisigSetDelegate(delegate string) (Micheline, error) {
+	if delegate == "" {
+		return MultisigOperation(RemoveDelegateLambda()), nil
+	}
+	lambda, err := SetDelegateLambda(delegate)
+	if err != nil {
+		return lambda, err
+	}
+	return MultisigOperation(lambda), nil
+}
+
+// MultisigChangeKeys returns the action of a generic multisig contract replacing its keys and threshold
+func MultisigChangeKeys(threshold int, keys []string) Micheline {
+	items := make([]Micheline, len(keys))
+	for k, key := range keys {
+		items[k] = NewMichelineString(key)
+	}
+	return NewMichelinePrim("Right", []Micheline{
+		NewMichelinePrim("Pair", []Micheline{NewMichelineInt(big.NewInt(int64(threshold))), NewMichelineSeq(items...)}),
+	})
+}
+
+// GetStorage gets the counter, threshold and keys of a generic multisig contract at the block id
+func (s *MultisigService) GetStorage(contract string, id interface{}) (MultisigStorage, error) {
+	var storage MultisigStorage
+	err := s.gt.Contract.GetTypedStorage(contract, id, &storage)
+	if err != nil {
+		return storage, errors.Wrapf(err, "could not get storage of multisig %s", contract)
+	}
+	return storage, nil
+}
+
+// NewPayload builds and packs the payload of an action of a generic multisig contract, for the current chain and
+// counter of the contract
+func (s *MultisigService) NewPayload(contract string, action Micheline) (MultisigPayload, error) {
+	storage, err := s.GetStorage(contract, "head")
+	if err != nil {
+		return MultisigPayload{}, errors.Wrap(err, "could not build multisig payload")
+	}
+
+	chainID, err := s.gt.Network.GetChainID()
+	if err != nil {
+		return MultisigPayload{}, errors.Wrap(err, "could not build multisig payload")
+	}
+
+	payload, err := newMultisigPayload(chainID, contract, storage.Counter, action)
+	if err != nil {
+		return payload, errors.Wrap(err, "could not build multisig payload")
+	}
+	return payload, nil
+}
+
+// Sign signs the packed payload with a signer
+func (p MultisigPayload) Sign(signer Signer) (string, error) {
+	signature, err := signer.Sign(p.Bytes)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not sign multisig payload of %s", p.Contract)
+	}
+	return signature, nil
+}
+
+// CollectSignatures signs a payload with signers, and returns the signatures in the order of the keys of the
+// multisig, with an empty string for the keys without a signer
+func CollectSignatures(payload MultisigPayload, keys []string, signers ...Signer) ([]string, error) {
+	slots := make(map[string]int, len(keys))
+	for k, key := range keys {
+		hash, err := publicKeyHash(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not collect multisig signatures")
+		}
+		slots[hash] = k
+	}
+
+	signatures := make([]string, len(keys))
+	for _, signer := range signers {
+		k, ok := slots[signer.PublicKeyHash()]
+		if !ok {
+			return nil, errors.Errorf("could not collect multisig signatures, %s is not a key of the multisig", signer.PublicKeyHash())
+		}
+		signature, err := payload.Sign(signer)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not collect multisig signatures")
+		}
+		signatures[k] = signature
+	}
+
+	return signatures, nil
+}
+
+// Submit calls the main entrypoint of a generic multisig contract from wallet with a payload and its signatures,
+// given in the order of the keys of the multisig. The counter and threshold are checked against the storage of the
+// contract first.
+func (s *MultisigService) Submit(payload MultisigPayload, signatures []string, wallet Wallet) (ContractCallResult, error) {
+	storage, err := s.GetStorage(payload.Contract, "head")
+	if err != nil {
+		return ContractCallResult{}, errors.Wrap(err, "could not submit multisig payload")
+	}
+
+	parameters, err := multisigMainParameters(payload, signatures, storage)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not submit multisig payload to %s", payload.Contract)
+	}
+
+	v, err := json.Marshal(parameters)
+	if err != nil {
+		return ContractCallResult{}, errors.Wrapf(err, "could not submit multisig payload to %s", payload.Contract)
+	}
+
+	result, err := s.gt.Operation.Call(ContractCall{Destination: payload.Contract, Entrypoint: "main", Parameters: v}, wallet)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not submit multisig payload to %s", payload.Contract)
+	}
+	return result, nil
+}
+
+// newMultisigPayload packs the payload of an action of a generic multisig contract
+func newMultisigPayload(chainID, contract string, counter int, action Micheline) (MultisigPayload, error) {
+	payload := MultisigPayload{ChainID: chainID, Contract: contract, Counter: counter, Action: action}
+
+	typ, err := ParseMichelson(multisigPayloadType)
+	if err != nil {
+		return payload, err
+	}
+
+	value := NewMichelinePrim("Pair", []Micheline{
+		NewMichelinePrim("Pair", []Micheline{NewMichelineString(chainID), NewMichelineString(contract)}),
+		NewMichelinePrim("Pair", []Micheline{NewMichelineInt(big.NewInt(int64(counter))), action}),
+	})
+
+	payload.Bytes, err = Pack(value, typ)
+	return payload, err
+}
+
+// multisigMainParameters returns the parameters of the main entrypoint of a generic multisig contract for a
+// payload and its signatures
+func multisigMainParameters(payload MultisigPayload, signatures []string, storage MultisigStorage) (Micheline, error) {
+	if payload.Counter != storage.Counter {
+		return Micheline{}, errors.Errorf("payload counter %d is not the multisig counter %d", payload.Counter, storage.Counter)
+	}
+	if len(signatures) != len(storage.Keys) {
+		return Micheline{}, errors.Errorf("got %d signatures for %d keys", len(signatures), len(storage.Keys))
+	}
+
+	sigs := make([]Micheline, len(signatures))
+	count := 0
+	for k, signature := range signatures {
+		if signature == "" {
+			sigs[k] = NewMichelinePrim("None", nil)
+			continue
+		}
+		sigs[k] = NewMichelinePrim("Some", []Micheline{NewMichelineString(signature)})
+		count++
+	}
+	if count < storage.Threshold {
+		return Micheline{}, errors.Errorf("got %d signatures for a threshold of %d", count, storage.Threshold)
+	}
+
+	return NewMichelinePrim("Pair", []Micheline{
+		NewMichelinePrim("Pair", []Micheline{NewMichelineInt(big.NewInt(int64(payload.Counter))), payload.Action}),
+		NewMichelineSeq(sigs...),
+	}), nil
+}
+
+// publicKeyHash returns the address of a public key
+func publicKeyHash(key string) (string, error) {
+	b, err := forgePublicKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := blake2b.New(20, nil)
+	if err != nil {
+		return "", err
+	}
+	hash.Write(b[1:])
+
+	return b58cencode(hash.Sum(nil), [][]byte{tz1, tz2, tz3}[b[0]]), nil
+}
@@ -0,0 +1,163 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Mutez is an amount of tez expressed in mutez, the smallest unit of tez. Mutez marshals to JSON as a string
+// like the Tezos RPC API, and its arithmetic is checked so amounts never overflow silently.
+type Mutez int64
+
+// ParseMutez parses a decimal amount of mutez such as "1500000"
+func ParseMutez(s string) (Mutez, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse mutez '%s'", s)
+	}
+	return Mutez(v), nil
+}
+
+// ParseTez parses a decimal amount of tez such as "1.5" with at most six decimals
+func ParseTez(s string) (Mutez, error) {
+	negative := strings.HasPrefix(s, "-")
+	whole, fraction := strings.TrimPrefix(s, "-"), ""
+	if i := strings.Index(whole, "."); i >= 0 {
+		whole, fraction = whole[:i], whole[i+1:]
+	}
+
+	if whole == "" || len(fraction) > 6 || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, errors.Errorf("could not parse tez '%s'", s)
+	}
+
+	m, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", 6-len(fraction)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse tez '%s'", s)
+	}
+
+	if negative {
+		m = -m
+	}
+	return Mutez(m), nil
+}
+
+// String returns the amount in mutez
+func (m Mutez) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+// Tez returns the amount in tez, without trailing zeros in its decimals
+func (m Mutez) Tez() string {
+	sign := ""
+	abs := uint64(m)
+	if m < 0 {
+		sign = "-"
+		abs = uint64(-int64(m))
+	}
+
+	whole := strconv.FormatUint(abs/MUTEZ, 10)
+	fraction := strings.TrimRight(strconv.FormatUint(abs%MUTEZ+MUTEZ, 10)[1:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+// Add returns the sum of two amounts, or an error if it overflows
+func (m Mutez) Add(n Mutez) (Mutez, error) {
+	sum := m + n
+	if (n > 0 && sum < m) || (n < 0 && sum > m) {
+		return 0, errors.Errorf("mutez overflow adding %s to %s", n, m)
+	}
+	return sum, nil
+}
+
+// Sub returns the difference of two amounts, or an error if it overflows
+func (m Mutez) Sub(n Mutez) (Mutez, error) {
+	diff := m - n
+	if (n > 0 && diff > m) || (n < 0 && diff < m) {
+		return 0, errors.Errorf("mutez overflow subtracting %s from %s", n, m)
+	}
+	return diff, nil
+}
+
+// Mul returns the amount multiplied by n, or an error if it overflows
+func (m Mutez) Mul(n int64) (Mutez, error) {
+	if m == 0 || n == 0 {
+		return 0, nil
+	}
+	product := int64(m) * n
+	if product/n != int64(m) || (int64(m) == -1 && n == math.MinInt64) || (n == -1 && int64(m) == math.MinInt64) {
+		return 0, errors.Errorf("mutez overflow multiplying %s by %d", m, n)
+	}
+	return Mutez(product), nil
+}
+
+// MulDiv returns the amount multiplied by num and divided by den, rounded toward zero. The intermediate
+// product is not limited in size, so this can be used to compute shares of an amount exactly.
+func (m Mutez) MulDiv(num, den Mutez) (Mutez, error) {
+	if den == 0 {
+		return 0, errors.Errorf("mutez division of %s by zero", m)
+	}
+
+	r := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(num)))
+	r.Quo(r, big.NewInt(int64(den)))
+	if !r.IsInt64() {
+		return 0, errors.Errorf("mutez overflow computing %s * %s / %s", m, num, den)
+	}
+	return Mutez(r.Int64()), nil
+}
+
+// ParseRate parses a decimal rate such as "0.05" exactly, for MulRate
+func ParseRate(s string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, errors.Errorf("could not parse rate '%s'", s)
+	}
+	return rate, nil
+}
+
+// MulRate returns the amount multiplied by an exact rate, rounded toward zero, so the amount never goes through a
+// float
+func (m Mutez) MulRate(rate *big.Rat) (Mutez, error) {
+	if rate == nil {
+		return 0, errors.New("invalid nil rate")
+	}
+
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(m)), rate)
+	q := new(big.Int).Quo(r.Num(), r.Denom())
+	if !q.IsInt64() {
+		return 0, errors.Errorf("mutez overflow computing %s * %s", m, rate.RatString())
+	}
+	return Mutez(q.Int64()), nil
+}
+
+// MarshalJSON encodes the amount as a JSON string of mutez
+func (m Mutez) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON decodes an amount of mutez from a JSON string, like the Tezos RPC API encodes it
+func (m *Mutez) UnmarshalJSON(v []byte) error {
+	if string(v) == "null" {
+		*m = 0
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return errors.Errorf("could not parse mutez %s, expected a JSON string", v)
+	}
+
+	parsed, err := ParseMutez(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -40,15 +40,15 @@ type NetworkConstants struct {
 	HardGasLimitPerOperation     string   `json:"hard_gas_limit_per_operation"`
 	HardGasLimitPerBlock         string   `json:"hard_gas_limit_per_block"`
 	ProofOfWorkThreshold         string   `json:"proof_of_work_threshold"`
-	TokensPerRoll                string   `json:"tokens_per_roll"`
+	TokensPerRoll                Mutez    `json:"tokens_per_roll"`
 	MichelsonMaximumTypeSize     int      `json:"michelson_maximum_type_size"`
 	SeedNonceRevelationTip       string   `json:"seed_nonce_revelation_tip"`
 	OriginationSize              int      `json:"origination_size"`
-	BlockSecurityDeposit         string   `json:"block_security_deposit"`
-	EndorsementSecurityDeposit   string   `json:"endorsement_security_deposit"`
-	BlockReward                  string   `json:"block_reward"`
-	EndorsementReward            string   `json:"endorsement_reward"`
-	CostPerByte                  string   `json:"cost_per_byte"`
+	BlockSecurityDeposit         Mutez    `json:"block_security_deposit"`
+	EndorsementSecurityDeposit   Mutez    `json:"endorsement_security_deposit"`
+	BlockReward                  Mutez    `json:"block_reward"`
+	EndorsementReward            Mutez    `json:"endorsement_reward"`
+	CostPerByte                  Mutez    `json:"cost_per_byte"`
 	HardStorageLimitPerOperation string   `json:"hard_storage_limit_per_operation"`
 }
 
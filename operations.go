@@ -1,33 +1,44 @@
 package gotezos
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"math"
 	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/blake2b"
 
 	"github.com/Messer4/base58check"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/ed25519"
 )
 
 var (
-	// How many Transactions per batch are injected. I recommend 100. Now 30 for easier testing
+	// How many Transactions per batch are injected when not limited by size or gas. I recommend 100.
 	batchSize = 100
 
+	// Gas added on top of the simulated consumption when computing gas limits
+	gasSafetyMargin = 100
+
 	// For (de)constructing addresses
 	tz1   = []byte{6, 161, 159}
+	kt1   = []byte{2, 90, 121}
 	edsk  = []byte{43, 246, 78, 7}
 	edsk2 = []byte{13, 15, 58, 7}
 	edpk  = []byte{13, 15, 37, 217}
 	edesk = []byte{7, 90, 60, 179, 41}
+
+	// For (de)constructing operation hashes
+	operationHashPrefix = []byte{5, 116}
+
+	// A well formed signature used when simulating unsigned operations
+	simulationSignature = "edsigtXomBKi5CTRf5cjATJWSyaRvhfYNHqSUGrn4SdbYRcGwQrUGjzEfQDTuqHhuA8b2d8NarZjz8TRf65WkpQmo423BtomS8Q"
 )
 
 // OperationService is a struct wrapper for operation related functions
 type OperationService struct {
-	gt *GoTezos
+	gt      *GoTezos
+	Counter *CounterManager
 }
 
 // Conts is helper structure to build out the contents of a a transfer operation to post to the Tezos RPC
@@ -43,159 +54,688 @@ type Transfer struct {
 	Signature string `json:"signature"`
 }
 
+// Origination is a helper structure describing a contract to deploy with Originate. Code and Storage
+// must be Micheline JSON. Fee, GasLimit and StorageLimit are
+// optional, when left at zero they are estimated by simulating the origination against the node.
+type Origination struct {
+	Code         json.RawMessage
+	Storage      json.RawMessage
+	Balance      Mutez
+	Delegate     string
+	Fee          Mutez
+	GasLimit     int
+	StorageLimit int
+}
+
+// OriginationResult is the outcome of an injected origination
+type OriginationResult struct {
+	OperationHash string
+	Contract      string
+	ConsumedGas   int
+	StorageSize   int
+	StorageBurn   Mutez
+}
+
+// ContractCall is a helper structure describing a smart contract invocation made with Call. Parameters must
+// be Micheline JSON and default to Unit, the Entrypoint defaults to "default". Fee, GasLimit and StorageLimit are
+// optional, when left at zero they are estimated by simulating the call.
+type ContractCall struct {
+	Destination  string
+	Entrypoint   string
+	Parameters   json.RawMessage
+	Amount       Mutez
+	Fee          Mutez
+	GasLimit     int
+	StorageLimit int
+}
+
+// ContractCallResult is the outcome of an injected smart contract invocation
+type ContractCallResult struct {
+	OperationHash       string
+	Storage             json.RawMessage
+	BigMapDiff          []StructBigMapDiff
+	ConsumedGas         int
+	StorageSize         int
+	PaidStorageSizeDiff int
+}
+
 // NewOperationService returns a New Operation Service
 func (gt *GoTezos) newOperationService() *OperationService {
-	return &OperationService{gt: gt}
+	return &OperationService{gt: gt, Counter: newCounterManager(gt)}
 }
 
 // CreateBatchPayment forges batch payments and returns them ready to inject to a Tezos RPC. PaymentFee must be expressed in mutez.
+// The fee and gas limit of every transfer are estimated when they are zero, see CreateBatchPaymentWithOptions.
 func (o *OperationService) CreateBatchPayment(payments []Payment, wallet Wallet, paymentFee int, gaslimit int) ([]string, error) {
+	return o.CreateBatchPaymentWithOptions(payments, wallet, BatchOptions{Fee: Mutez(paymentFee), GasLimit: gaslimit})
+}
+
+// CreateBatchPaymentWithOptions forges batch payments and returns them ready to inject to a Tezos RPC. Payments are
+// packed into batches by the size and gas limits of opts, and the fee and gas limit of every transfer are estimated
+// unless given in opts. Storage limits are always estimated. The signed batches hold their counters until they are
+// confirmed, rejected or expire, so later operations from wallet do not reuse them before they are injected.
+func (o *OperationService) CreateBatchPaymentWithOptions(payments []Payment, wallet Wallet, opts BatchOptions) ([]string, error) {
 
 	var operationSignatures []string
 
+	opts, err := o.batchOptions(opts)
+	if err != nil {
+		return operationSignatures, errors.Wrap(err, "could not create batch payment")
+	}
+
+	transfers := countTransfers(payments)
+	if transfers == 0 {
+		return operationSignatures, nil
+	}
+
 	// Get current branch head
 	blockHead, err := o.gt.Block.GetHead()
 	if err != nil {
 		return operationSignatures, errors.Wrap(err, "could not create batch payment")
 	}
 
-	// Get the counter for the payment address and increment it
-	counter, err := o.getAddressCounter(wallet.Address)
+	// Reserve a counter for each transfer, the reservation is split between the batches
+	first, err := o.Counter.Next(wallet.Address, transfers)
 	if err != nil {
 		return operationSignatures, errors.Wrap(err, "could not create batch payment")
 	}
-	counter++
 
-	// Split our slice of []Payment into batches
-	batches := o.splitPaymentIntoBatches(payments)
-	operationSignatures = make([]string, len(batches))
+	// Pack our slice of []Payment into batches, estimated at the reserved counters
+	batches := o.planBatches(blockHead.Hash, first, wallet.Address, payments, opts)
+	for k := range batches {
+		if batches[k].err != nil {
+			o.Counter.Release(wallet.Address)
+			return operationSignatures, errors.Wrap(batches[k].err, "could not create batch payment")
+		}
+	}
 
+	var tracked []TrackedOperation
+	counter := first
 	for k := range batches {
+		if len(batches[k].contents.Contents) == 0 {
+			continue
+		}
 
-		// Convert (ie: forge) each 'Payment' into an actual Tezos transfer operation
-		operationBytes, operationContents, newCounter, err := o.forgeOperationBytes(blockHead.Hash, counter, wallet, batches[k], paymentFee, gaslimit)
+		signed, err := o.createBatch(blockHead, first, counter, wallet, batches[k], opts)
 		if err != nil {
+			o.Counter.Release(wallet.Address)
 			return operationSignatures, errors.Wrap(err, "could not create batch payment")
 		}
-		counter = newCounter
+		tracked = append(tracked, trackedOperations(signed)...)
+		counter += len(batches[k].contents.Contents)
+
+		// Add the signature (raw operation bytes & signature of operations) of gt batch of transfers to the returnning slice
+		// gt will be used to POST to /injection/operation
+		for _, operation := range signed {
+			operationSignatures = append(operationSignatures, operation.operation)
+		}
+
+	}
+	o.Counter.Track(wallet.Address, blockHead, tracked...)
+
+	return operationSignatures, nil
+}
 
-		// Sign gt batch of operations with the secret key; return that signature
-		edsig, err := o.signOperationBytes(operationBytes, wallet)
+// signedBatch is a batch of payments signed by createBatch, using the counters first to last
+type signedBatch struct {
+	payments  []Payment
+	operation string
+	hash      string
+	first     int
+	last      int
+}
+
+// createBatch signs and preapplies a planned batch of payments using consecutive counters from counter, within a
+// reservation starting at first. A batch that exceeds the size or gas limits of opts, or that preapply reports as
+// too large for a block, is split in two and both halves are created with the same counters. It returns the signed
+// operations in counter order.
+func (o *OperationService) createBatch(blockHead Block, first, counter int, wallet Wallet, batch plannedBatch, opts BatchOptions) ([]signedBatch, error) {
+
+	// The batch was planned with the counters expected at the time, forge it again if they were taken since
+	if batch.contents.Contents[0].Counter != strconv.Itoa(counter) {
+		var err error
+		batch.contents = renumberContents(batch.contents, counter)
+		batch.operationBytes, err = o.forgeOperation(batch.contents)
 		if err != nil {
-			return operationSignatures, errors.Wrap(err, "could not create batch payment")
+			return nil, err
+		}
+	}
+
+	if !batchFits(batch, opts) {
+		if len(batch.payments) < 2 {
+			return nil, errors.Errorf("payment to %s does not fit in a batch", batch.payments[0].Address)
 		}
+		return o.splitBatch(blockHead, first, counter, wallet, batch.payments, opts)
+	}
+
+	// Sign gt batch of operations with the secret key; return that signature and the signed bytes
+	edsig, fullOperation, err := signOperation(batch.operationBytes, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	// We can validate gt batch against the node for any errors. Batches following one that is not injected yet
+	// are in the future of the chain, they were simulated at the current counter of the source when planned.
+	_, err = o.preApplyOperations(batch.contents, edsig, blockHead)
+	if err != nil && isBatchLimitError(err) && len(batch.payments) > 1 {
+		return o.splitBatch(blockHead, first, counter, wallet, batch.payments, opts)
+	}
+	if err = o.futureCounterError(err, wallet.Address, first, counter); err != nil {
+		return nil, err
+	}
+
+	opHash, err := operationHash(fullOperation)
+	if err != nil {
+		return nil, err
+	}
 
-		// Extract and decode the bytes of the signature
-		decodedSignature, err := o.decodeSignature(edsig)
+	return []signedBatch{{
+		payments:  batch.payments,
+		operation: fullOperation,
+		hash:      opHash,
+		first:     counter,
+		last:      counter + len(batch.contents.Contents) - 1,
+	}}, nil
+}
+
+// splitBatch creates the two halves of a batch of payments that does not fit in a block, using consecutive
+// counters from counter within a reservation starting at first
+func (o *OperationService) splitBatch(blockHead Block, first, counter int, wallet Wallet, payments []Payment, opts BatchOptions) ([]signedBatch, error) {
+	var signed []signedBatch
+
+	half := len(payments) / 2
+	for _, part := range [][]Payment{payments[:half], payments[half:]} {
+		batch := o.forgeBatch(blockHead.Hash, counter, wallet.Address, part, opts)
+		if batch.err != nil {
+			return nil, batch.err
+		}
+		if len(batch.contents.Contents) == 0 {
+			continue
+		}
+
+		partSigned, err := o.createBatch(blockHead, first, counter, wallet, batch, opts)
 		if err != nil {
-			return operationSignatures, errors.Wrap(err, "could not create batch payment")
+			return nil, err
+		}
+		signed = append(signed, partSigned...)
+		counter += len(batch.contents.Contents)
+	}
+
+	return signed, nil
+}
+
+// trackedOperations returns signed batches as operations to track with the CounterManager
+func trackedOperations(signed []signedBatch) []TrackedOperation {
+	tracked := make([]TrackedOperation, len(signed))
+	for k := range signed {
+		tracked[k] = TrackedOperation{Hash: signed[k].hash, First: signed[k].first, Last: signed[k].last}
+	}
+	return tracked
+}
+
+// countTransfers returns the number of transfers forged for a batch of payments
+func countTransfers(batch []Payment) int {
+	count := 0
+	for k := range batch {
+		if batch[k].Amount > 0 {
+			count++
 		}
+	}
+	return count
+}
+
+// Originate forges, signs and injects the origination of a new smart contract from wallet. The storage
+// burn is estimated by simulating the origination first, and the address of the originated contract is
+// computed locally from the operation hash.
+func (o *OperationService) Originate(origination Origination, wallet Wallet) (OriginationResult, error) {
+	var result OriginationResult
+
+	operation := StructContents{
+		Kind:     "origination",
+		Balance:  origination.Balance,
+		Delegate: origination.Delegate,
+		Script: &StructScript{
+			Code:    origination.Code,
+			Storage: origination.Storage,
+		},
+	}
+
+	opHash, applied, err := o.injectManagerOperation(operation, wallet, origination.Fee, origination.GasLimit, origination.StorageLimit)
+	result.OperationHash = opHash
+	if err != nil {
+		return result, errors.Wrap(err, "could not originate contract")
+	}
+
+	originated, ok := applied.(*OriginationContents)
+	if !ok {
+		return result, errors.Errorf("could not originate contract, unexpected %s receipt", applied.OperationKind())
+	}
+
+	// Every origination consumes gas and stores its script, a receipt without them is incomplete
+	opResult := originated.Metadata.OperationResult
+	if opResult.ConsumedGas == 0 || opResult.StorageSize == 0 {
+		return result, errors.New("could not originate contract, no consumed gas or storage size in receipt")
+	}
+	result.ConsumedGas = opResult.ConsumedGas
+	result.StorageSize = opResult.StorageSize
+	result.StorageBurn, err = o.gt.Constants.CostPerByte.Mul(int64(opResult.PaidStorageSizeDiff + o.gt.Constants.OriginationSize))
+	if err != nil {
+		return result, errors.Wrap(err, "could not originate contract")
+	}
+
+	result.Contract, err = originatedContractAddress(result.OperationHash, 0)
+	if err != nil {
+		return result, errors.Wrap(err, "could not originate contract")
+	}
+
+	return result, nil
+}
+
+// Call forges, signs and injects a transaction from wallet invoking an entrypoint of a smart contract. The
+// entrypoint is validated against the entrypoints of the destination contract, and the resulting storage
+// and big map diffs are taken from the receipt of the preapplied operation.
+func (o *OperationService) Call(call ContractCall, wallet Wallet) (ContractCallResult, error) {
+	var result ContractCallResult
+
+	operation, err := o.callContents(call, nil)
+	if err != nil {
+		return result, errors.Wrapf(err, "could not call contract %s", call.Destination)
+	}
 
-		decodedSignature = decodedSignature[10:(len(decodedSignature))]
+	opHash, applied, err := o.injectManagerOperation(operation, wallet, call.Fee, call.GasLimit, call.StorageLimit)
+	result.OperationHash = opHash
+	if err != nil {
+		return result, errors.Wrapf(err, "could not call contract %s", call.Destination)
+	}
+
+	transaction, ok := applied.(*TransactionContents)
+	if !ok {
+		return result, errors.Errorf("could not call contract %s, unexpected %s receipt", call.Destination, applied.OperationKind())
+	}
 
-		// The signed bytes of gt batch
-		fullOperation := operationBytes + decodedSignature
+	opResult := transaction.Metadata.OperationResult
+	result.Storage = opResult.Storage
+	result.BigMapDiff = opResult.BigMapDiff
+	result.ConsumedGas = opResult.ConsumedGas
+	result.StorageSize = opResult.StorageSize
+	result.PaidStorageSizeDiff = opResult.PaidStorageSizeDiff
+
+	return result, nil
+}
+
+// CreateBatchCall forges batches of smart contract invocations from wallet and returns them signed and ready to
+// inject to a Tezos RPC, like CreateBatchPaymentWithOptions. Calls are packed in order into batches by the size and gas limits
+// of opts, and the fee and gas limit of every call are estimated unless given in opts. The fees and limits of the
+// calls themselves are ignored.
+func (o *OperationService) CreateBatchCall(calls []ContractCall, wallet Wallet, opts BatchOptions) ([]string, error) {
+	var operationSignatures []string
 
-		// We can validate gt batch against the node for any errors
-		err = o.preApplyOperations(operationContents, edsig, blockHead)
+	opts, err := o.batchOptions(opts)
+	if err != nil {
+		return operationSignatures, errors.Wrap(err, "could not create batch call")
+	}
+
+	blockHead, err := o.gt.Block.GetHead()
+	if err != nil {
+		return operationSignatures, errors.Wrap(err, "could not create batch call")
+	}
+
+	contents := make([]StructContents, len(calls))
+	entrypoints := make(map[string]map[string]json.RawMessage)
+	for k := range calls {
+		contents[k], err = o.callContents(calls[k], entrypoints)
 		if err != nil {
-			return operationSignatures, errors.Wrap(err, "could not create batch payment")
+			return operationSignatures, errors.Wrapf(err, "could not create batch call to contract %s", calls[k].Destination)
 		}
-		// Add the signature (raw operation bytes & signature of operations) of gt batch of transfers to the returnning slice
-		// gt will be used to POST to /injection/operation
-		operationSignatures[k] = fullOperation
+		contents[k].Source = wallet.Address
+	}
+	if len(contents) == 0 {
+		return operationSignatures, nil
+	}
 
+	// Reserve a counter for each call, the reservation is split between the batches
+	first, err := o.Counter.Next(wallet.Address, len(contents))
+	if err != nil {
+		return operationSignatures, errors.Wrap(err, "could not create batch call")
 	}
+	counter := first
+
+	var tracked []TrackedOperation
+	for len(contents) > 0 {
+		n := len(contents)
+		if n > opts.MaxTransfers {
+			n = opts.MaxTransfers
+		}
+
+		var batch plannedBatch
+		for {
+			batch = o.forgeContents(plannedBatch{contents: renumberContents(Conts{Branch: blockHead.Hash, Contents: contents[:n]}, counter)}, opts)
+			if batch.err != nil {
+				o.Counter.Release(wallet.Address)
+				return operationSignatures, errors.Wrap(batch.err, "could not create batch call")
+			}
+			if batchFits(batch, opts) {
+				break
+			}
+			if n == 1 {
+				o.Counter.Release(wallet.Address)
+				return operationSignatures, errors.Errorf("could not create batch call, call to %s does not fit in a batch", contents[0].Destination)
+			}
+			n = n / 2
+		}
+
+		edsig, fullOperation, err := signOperation(batch.operationBytes, wallet)
+		if err != nil {
+			o.Counter.Release(wallet.Address)
+			return operationSignatures, errors.Wrap(err, "could not create batch call")
+		}
+
+		// Batches following one that is not injected yet are in the future of the chain
+		_, err = o.preApplyOperations(batch.contents, edsig, blockHead)
+		if err = o.futureCounterError(err, wallet.Address, first, counter); err != nil {
+			o.Counter.Release(wallet.Address)
+			return operationSignatures, errors.Wrap(err, "could not create batch call")
+		}
+
+		opHash, err := operationHash(fullOperation)
+		if err != nil {
+			o.Counter.Release(wallet.Address)
+			return operationSignatures, errors.Wrap(err, "could not create batch call")
+		}
+
+		tracked = append(tracked, TrackedOperation{Hash: opHash, First: counter, Last: counter + n - 1})
+		operationSignatures = append(operationSignatures, fullOperation)
+		counter += n
+		contents = contents[n:]
+	}
+	o.Counter.Track(wallet.Address, blockHead, tracked...)
 
 	return operationSignatures, nil
 }
 
-//Sign previously forged Operation bytes using secret key of wallet
-func (o *OperationService) signOperationBytes(operationBytes string, wallet Wallet) (string, error) {
+// callContents builds the transaction of a smart contract invocation, after checking its entrypoint against the
+// entrypoints of the destination. The entrypoints of each destination are cached in entrypoints when it is not nil.
+func (o *OperationService) callContents(call ContractCall, entrypoints map[string]map[string]json.RawMessage) (StructContents, error) {
+	entrypoint := call.Entrypoint
+	if entrypoint == "" {
+		entrypoint = "default"
+	}
 
-	//Prefixes
-	edsigByte := []byte{9, 245, 205, 134, 18}
-	watermark := []byte{3}
+	if entrypoint != "default" {
+		known, ok := entrypoints[call.Destination]
+		if !ok {
+			var err error
+			known, err = o.gt.Contract.GetEntrypoints(call.Destination)
+			if err != nil {
+				return StructContents{}, err
+			}
+			if entrypoints != nil {
+				entrypoints[call.Destination] = known
+			}
+		}
+		if _, ok := known[entrypoint]; !ok {
+			return StructContents{}, errors.Errorf("unknown entrypoint '%s'", entrypoint)
+		}
+	}
 
-	opBytes, err := hex.DecodeString(operationBytes)
+	parameters := call.Parameters
+	if len(parameters) == 0 {
+		parameters = json.RawMessage(`{"prim":"Unit"}`)
+	}
+
+	return StructContents{
+		Kind:        "transaction",
+		Amount:      call.Amount,
+		Destination: call.Destination,
+		Parameters: &StructParameters{
+			Entrypoint: entrypoint,
+			Value:      parameters,
+		},
+	}, nil
+}
+
+// injectManagerOperation fills in the source, counter, fee and limits of a single manager operation, then forges,
+// signs, preapplies and injects it. A fee or limit left at zero is estimated by simulating the operation. It returns
+// the hash of the injected operation and its preapplied contents. Once the operation is signed it holds its counter,
+// even if injecting it fails afterwards, until the CounterManager learns what happened to it.
+func (o *OperationService) injectManagerOperation(operation StructContents, wallet Wallet, fee Mutez, gasLimit int, storageLimit int) (string, OperationContents, error) {
+	blockHead, err := o.gt.Block.GetHead()
 	if err != nil {
-		return "", errors.Wrap(err, "could not sign operation bytes")
+		return "", nil, err
 	}
-	opBytes = append(watermark, opBytes...)
 
-	// Generic hash of 32 bytes
-	genericHash, err := blake2b.New(32, []byte{})
+	counter, err := o.Counter.Next(wallet.Address, 1)
+	if err != nil {
+		return "", nil, err
+	}
 
-	// Write operation bytes to hash
-	i, err := genericHash.Write(opBytes)
+	operation.Source = wallet.Address
+	operation.Counter = strconv.Itoa(counter)
 
+	fullOperation, opHash, applied, err := o.signManagerOperation(blockHead, operation, wallet, fee, gasLimit, storageLimit)
 	if err != nil {
-		return "", errors.Wrap(err, "could not sign operation bytes")
+		o.Counter.Release(wallet.Address)
+		return "", applied, err
+	}
+	o.Counter.Track(wallet.Address, blockHead, TrackedOperation{Hash: opHash, First: counter, Last: counter})
+
+	// The operation is rejected by InjectOperation if the node does not accept it
+	resp, err := o.InjectOperation(fullOperation)
+	if err != nil {
+		return "", applied, err
+	}
+
+	injectedHash, err := unmarshalString(resp)
+	if err != nil {
+		return opHash, applied, err
+	}
+	if injectedHash != opHash {
+		return opHash, applied, errors.Errorf("injected operation hash '%s' does not match computed hash '%s'", injectedHash, opHash)
 	}
-	if i != len(opBytes) {
-		return "", errors.Errorf("could not sign operation, generic hash length %d does not match bytes length %d", i, len(opBytes))
+
+	return opHash, applied, nil
+}
+
+// signManagerOperation estimates, forges, signs and preapplies a single manager operation. It returns the signed
+// operation, its hash and its preapplied contents.
+func (o *OperationService) signManagerOperation(blockHead Block, operation StructContents, wallet Wallet, fee Mutez, gasLimit int, storageLimit int) (string, string, OperationContents, error) {
+	contents := Conts{Contents: []StructContents{operation}, Branch: blockHead.Hash}
+
+	estimates, err := o.Estimate(contents)
+	if err != nil {
+		return "", "", nil, err
 	}
 
-	finalHash := genericHash.Sum([]byte{})
+	if fee > 0 {
+		estimates[0].Fee = fee
+	}
+	if gasLimit > 0 {
+		estimates[0].GasLimit = gasLimit
+	}
+	if storageLimit > 0 {
+		estimates[0].StorageLimit = storageLimit
+	}
+	contents = applyEstimates(contents, estimates)
 
-	// Sign the finalized generic hash of operations and b58 encode
-	sig := ed25519.Sign(wallet.Kp.PrivKey, finalHash)
-	//sig := sodium.Bytes(finalHash).SignDetached(wallet.Kp.PrivKey)
-	edsig := b58cencode(sig, edsigByte)
+	operationBytes, err := o.forgeOperation(contents)
+	if err != nil {
+		return "", "", nil, err
+	}
 
-	return edsig, nil
+	edsig, fullOperation, err := signOperation(operationBytes, wallet)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	preapplied, err := o.preApplyOperations(contents, edsig, blockHead)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(preapplied) != 1 || len(preapplied[0].Contents) != 1 {
+		return "", "", nil, errors.New("unexpected preapply response")
+	}
+
+	applied := preapplied[0].Contents[0]
+	if _, err = appliedResult(applied); err != nil {
+		return "", "", applied, err
+	}
+
+	opHash, err := operationHash(fullOperation)
+	if err != nil {
+		return "", "", applied, err
+	}
+
+	return fullOperation, opHash, applied, nil
 }
 
-func (o *OperationService) forgeOperationBytes(branchHash string, counter int, wallet Wallet, batch []Payment, paymentFee int, gaslimit int) (string, Conts, int, error) {
+//Sign previously forged Operation bytes using the secret key of a signer
+func signOperationBytes(operationBytes string, signer Signer) (string, error) {
+	opBytes, err := hex.DecodeString(operationBytes)
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign operation bytes")
+	}
 
-	var contents Conts
-	var combinedOps []StructContents
+	// Sign the watermarked operation bytes
+	signature, err := signer.Sign(append([]byte{operationWatermark}, opBytes...))
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign operation bytes")
+	}
 
-	//left here to display how to reveal a new wallet (needs funds to be revealed!)
-	/**
-	  combinedOps = append(combinedOps, StructContents{Kind: "reveal", PublicKey: wallet.pk , Source: wallet.address, Fee: "0", GasLimit: "127", StorageLimit: "0", Counter: strCounter})
-	  counter++
-	**/
+	return signature, nil
+}
 
-	for k := range batch {
+// signOperation signs forged operation bytes and returns the signature along with the signed operation bytes
+func signOperation(operationBytes string, signer Signer) (string, string, error) {
+	signature, err := signOperationBytes(operationBytes, signer)
+	if err != nil {
+		return "", "", err
+	}
 
-		if batch[k].Amount > 0 {
+	// Extract and decode the bytes of the signature
+	decodedSignature, err := signatureBytes(signature)
+	if err != nil {
+		return "", "", errors.Wrap(err, "could not sign operation bytes")
+	}
 
-			operation := StructContents{
-				Kind:         "transaction",
-				Source:       wallet.Address,
-				Fee:          strconv.Itoa(paymentFee),
-				GasLimit:     strconv.Itoa(gaslimit),
-				StorageLimit: "0",
-				Amount:       strconv.FormatFloat(roundPlus(batch[k].Amount, 0), 'f', -1, 64),
-				Destination:  batch[k].Address,
-				Counter:      strconv.Itoa(counter),
-			}
-			combinedOps = append(combinedOps, operation)
+	return signature, operationBytes + hex.EncodeToString(decodedSignature), nil
+}
+
+// batchContents builds the transfers of a batch of payments from source, using consecutive counters from counter.
+// Payments without an amount are skipped.
+func batchContents(branchHash string, counter int, source string, batch []Payment) Conts {
+	contents := Conts{Branch: branchHash}
+	for k := range batch {
+		if batch[k].Amount > 0 {
+			contents.Contents = append(contents.Contents, StructContents{
+				Kind:        "transaction",
+				Source:      source,
+				Amount:      batch[k].Amount,
+				Destination: batch[k].Address,
+				Counter:     strconv.Itoa(counter),
+			})
 			counter++
 		}
 	}
-	contents.Contents = combinedOps
-	contents.Branch = branchHash
+	return contents
+}
+
+// renumberContents returns contents using consecutive counters from counter
+func renumberContents(contents Conts, counter int) Conts {
+	renumbered := Conts{Branch: contents.Branch, Contents: make([]StructContents, len(contents.Contents))}
+	for i, content := range contents.Contents {
+		content.Counter = strconv.Itoa(counter + i)
+		renumbered.Contents[i] = content
+	}
+	return renumbered
+}
+
+// overrideEstimates replaces the estimated fee and gas limit of every content with paymentFee and gaslimit
+// when they are not zero
+func overrideEstimates(estimates []OperationEstimate, paymentFee Mutez, gaslimit int) []OperationEstimate {
+	for k := range estimates {
+		if paymentFee > 0 {
+			estimates[k].Fee = paymentFee
+		}
+		if gaslimit > 0 {
+			estimates[k].GasLimit = gaslimit
+		}
+	}
+	return estimates
+}
 
+// forgeOperation forges the contents of an operation with the node and returns the forged bytes
+func (o *OperationService) forgeOperation(contents Conts) (string, error) {
 	var opBytes string
 
 	forge := "/chains/main/blocks/head/helpers/forge/operations"
 	output, err := o.gt.Post(forge, contents.string())
 	if err != nil {
-		return "", contents, counter, errors.Wrapf(err, "could not forge operation '%s' with contents '%s'", forge, contents.string())
+		return "", errors.Wrapf(err, "could not forge operation '%s' with contents '%s'", forge, contents.string())
 	}
 
 	err = json.Unmarshal(output, &opBytes)
 	if err != nil {
-		return "", contents, counter, errors.Wrapf(err, "could not forge operation '%s' with contents '%s'", forge, contents.string())
+		return "", errors.Wrapf(err, "could not forge operation '%s' with contents '%s'", forge, contents.string())
 	}
 
-	return opBytes, contents, counter, nil
+	return opBytes, nil
+}
+
+// runOperation simulates the contents of an operation against the head block without checking its signature
+func (o *OperationService) runOperation(contents Conts) (Operation, error) {
+	var operation Operation
+
+	// The signature is not checked by the node, but must be well formed
+	var transfer Transfer
+	transfer.Conts = contents
+	transfer.Signature = simulationSignature
+
+	transferOp, err := json.Marshal(transfer)
+	if err != nil {
+		return operation, errors.Wrap(err, "could not run operation, could not marshal into json")
+	}
+
+	query := "/chains/main/blocks/head/helpers/scripts/run_operation"
+	resp, err := o.gt.Post(query, string(transferOp))
+	if err != nil {
+		return operation, errors.Wrapf(err, "could not run operation '%s' with contents '%s'", query, string(transferOp))
+	}
+
+	err = json.Unmarshal(resp, &operation)
+	if err != nil {
+		return operation, errors.Wrapf(err, "could not run operation '%s'", query)
+	}
+
+	if len(operation.Contents) != len(contents.Contents) {
+		return operation, errors.Errorf("could not run operation '%s', expected %d results but got %d", query, len(contents.Contents), len(operation.Contents))
+	}
+
+	return operation, nil
+}
+
+// futureCounterError returns the error of preapplying contents of source starting at counter, unless it is only
+// counter_in_the_future and the counters before counter are used by the previous contents of the same reservation
+// starting at first, or by operations in flight
+func (o *OperationService) futureCounterError(err error, source string, first, counter int) error {
+	if err == nil || !strings.Contains(err.Error(), "counter_in_the_future") {
+		return err
+	}
+	if counter > first {
+		return nil
+	}
+
+	covered, coverErr := o.Counter.covers(source, counter)
+	if coverErr != nil {
+		return errors.Wrap(coverErr, "could not check counters in flight")
+	}
+	if !covered {
+		return errors.Wrapf(err, "counters of %s before %d are not used by any operation in flight", source, counter)
+	}
+	return nil
 }
 
 // Pre-apply an operation, or batch of operations, to a Tezos node to ensure correctness
-func (o *OperationService) preApplyOperations(paymentOperations Conts, signature string, blockHead Block) error {
+func (o *OperationService) preApplyOperations(paymentOperations Conts, signature string, blockHead Block) ([]Operation, error) {
 
 	// Create a full transfer request
 	var transfer Transfer
@@ -210,17 +750,23 @@ func (o *OperationService) preApplyOperations(paymentOperations Conts, signature
 	// Convert object to JSON string
 	transfersOp, err := json.Marshal(transfers)
 	if err != nil {
-		return errors.Wrap(err, "could not preapply operations, could not marshal into json")
+		return nil, errors.Wrap(err, "could not preapply operations, could not marshal into json")
 	}
 
 	// POST the JSON to the RPC
 	query := "/chains/main/blocks/head/helpers/preapply/operations"
-	_, err = o.gt.Post(query, string(transfersOp))
+	resp, err := o.gt.Post(query, string(transfersOp))
 	if err != nil {
-		return errors.Wrapf(err, "could not preapply operations '%s' with contents '%s'", query, string(transfersOp))
+		return nil, errors.Wrapf(err, "could not preapply operations '%s' with contents '%s'", query, string(transfersOp))
 	}
 
-	return nil
+	var applied []Operation
+	err = json.Unmarshal(resp, &applied)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not preapply operations '%s'", query)
+	}
+
+	return applied, nil
 }
 
 // InjectOperation injects an signed operation string and returns the response
@@ -232,6 +778,10 @@ func (o *OperationService) InjectOperation(op string) ([]byte, error) {
 	}
 	resp, err := o.gt.Post(post, string(jsonBytes))
 	if err != nil {
+		// Resync the counters of the source if the operation was tracked
+		if opHash, hashErr := operationHash(op); hashErr == nil {
+			o.Counter.Reject(opHash)
+		}
 		return resp, errors.Wrapf(err, "could not inject operation '%s' with contents '%s'", post, string(jsonBytes))
 	}
 	return resp, nil
@@ -255,18 +805,6 @@ func (o *OperationService) getAddressCounter(address string) (int, error) {
 	return counter, nil
 }
 
-func (o *OperationService) splitPaymentIntoBatches(rewards []Payment) [][]Payment {
-	var batches [][]Payment
-	for i := 0; i < len(rewards); i += batchSize {
-		end := i + batchSize
-		if end > len(rewards) {
-			end = len(rewards)
-		}
-		batches = append(batches, rewards[i:end])
-	}
-	return batches
-}
-
 // GetBlockOperationHashes returns list of operations in block at specific level
 func (o *OperationService) GetBlockOperationHashes(id interface{}) ([]string, error) {
 
@@ -290,13 +828,53 @@ func (o *OperationService) GetBlockOperationHashes(id interface{}) ([]string, er
 	return operations, nil
 }
 
-//Helper function to return the decoded signature
-func (o *OperationService) decodeSignature(sig string) (string, error) {
-	decBytes, err := base58check.Decode(sig)
+// appliedResult returns the operation result of simulated or preapplied contents, or an error if it was not applied
+func appliedResult(contents OperationContents) (operationResult, error) {
+	result, _, ok := contentsResult(contents)
+	if !ok {
+		return result, errors.Errorf("no operation result for %s", contents.OperationKind())
+	}
+
+	if result.Status != OperationStatusApplied {
+		var ids []string
+		for _, e := range result.Errors {
+			ids = append(ids, e.ID)
+		}
+		return result, errors.Errorf("%s %s: %s", contents.OperationKind(), result.Status, strings.Join(ids, ", "))
+	}
+
+	return result, nil
+}
+
+// operationHash computes the hash of a signed operation
+func operationHash(signedOperation string) (string, error) {
+	opBytes, err := hex.DecodeString(signedOperation)
 	if err != nil {
-		return "", errors.Wrap(err, "could not decode signature")
+		return "", errors.Wrap(err, "could not compute operation hash")
 	}
-	return hex.EncodeToString(decBytes), nil
+
+	hash := blake2b.Sum256(opBytes)
+	return b58cencode(hash[:], operationHashPrefix), nil
+}
+
+// originatedContractAddress computes the KT1 address of the contract originated at index in an operation
+func originatedContractAddress(opHash string, index int) (string, error) {
+	decoded, err := base58check.Decode(opHash)
+	if err != nil || len(decoded) != len(operationHashPrefix)+32 {
+		return "", errors.Errorf("could not compute originated contract address, invalid operation hash '%s'", opHash)
+	}
+
+	nonce := make([]byte, 4)
+	binary.BigEndian.PutUint32(nonce, uint32(index))
+
+	hash, err := blake2b.New(20, []byte{})
+	if err != nil {
+		return "", errors.Wrap(err, "could not compute originated contract address")
+	}
+	hash.Write(decoded[len(operationHashPrefix):])
+	hash.Write(nonce)
+
+	return b58cencode(hash.Sum(nil), kt1), nil
 }
 
 //Helper Function to get the right format for wallet.
@@ -317,16 +895,6 @@ func b58cdecode(payload string, prefix []byte) []byte {
 	return b58c[len(prefix):]
 }
 
-//Helper Functions to round float64
-func roundPlus(f float64, places int) float64 {
-	shift := math.Pow(10, float64(places))
-	return round(f*shift) / shift
-}
-
-func round(f float64) float64 {
-	return math.Floor(f + .5)
-}
-
 func (c Conts) string() string {
 	res, _ := json.Marshal(c)
 	return string(res)
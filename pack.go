@@ -0,0 +1,633 @@
+package gotezos
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	// Byte prefixing data packed with PACK
+	packedDataTag = byte(5)
+
+	// For (de)constructing script expression hashes and chain ids
+	exprPrefix    = []byte{13, 44, 64, 27}
+	chainIDPrefix = []byte{87, 82, 0}
+)
+
+// Tags of the binary encoding of Micheline nodes
+const (
+	michelineTagInt          = 0
+	michelineTagString       = 1
+	michelineTagSeq          = 2
+	michelineTagPrim         = 3
+	michelineTagPrimAnnots   = 4
+	michelineTagPrim1        = 5
+	michelineTagPrim1Annots  = 6
+	michelineTagPrim2        = 7
+	michelineTagPrim2Annots  = 8
+	michelineTagPrimN        = 9
+	michelineTagBytes        = 10
+	michelineMaxBinaryLength = 1 << 30
+)
+
+// Pack serializes a value of a Michelson type like the PACK instruction. Addresses, keys, key hashes,
+// signatures, chain ids and timestamps given in their readable form are packed in their optimized form, as
+// the node does.
+func Pack(value, typ Micheline) ([]byte, error) {
+	optimized, err := convertData(value, typ, optimizeData)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not pack data")
+	}
+
+	encoded, err := encodeMicheline(optimized)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not pack data")
+	}
+
+	return append([]byte{packedDataTag}, encoded...), nil
+}
+
+// Unpack deserializes data packed with PACK. Values of domain specific types, such as addresses, are returned
+// in their optimized form, use UnpackTyped to get them in their readable form.
+func Unpack(packed []byte) (Micheline, error) {
+	if len(packed) == 0 || packed[0] != packedDataTag {
+		return Micheline{}, errors.New("could not unpack data, missing pack prefix")
+	}
+
+	value, n, err := decodeMicheline(packed[1:])
+	if err != nil {
+		return Micheline{}, errors.Wrap(err, "could not unpack data")
+	}
+	if n != len(packed)-1 {
+		return Micheline{}, errors.Errorf("could not unpack data, %d trailing bytes", len(packed)-1-n)
+	}
+
+	return value, nil
+}
+
+// UnpackTyped deserializes data packed with PACK as a value of a Michelson type, with addresses, keys, key
+// hashes, signatures, chain ids and timestamps in their readable form
+func UnpackTyped(packed []byte, typ Micheline) (Micheline, error) {
+	value, err := Unpack(packed)
+	if err != nil {
+		return value, err
+	}
+
+	readable, err := convertData(value, typ, readableData)
+	if err != nil {
+		return value, errors.Wrap(err, "could not unpack data")
+	}
+
+	return readable, nil
+}
+
+// ScriptExpressionHash returns the hash of a value of a Michelson type, the "expr..." hash used as the key of
+// big maps
+func ScriptExpressionHash(value, typ Micheline) (string, error) {
+	packed, err := Pack(value, typ)
+	if err != nil {
+		return "", errors.Wrap(err, "could not hash script expression")
+	}
+
+	hash := blake2b.Sum256(packed)
+	return b58cencode(hash[:], exprPrefix), nil
+}
+
+// PackData packs a value of a Michelson type with the node, for checking data packed locally with Pack
+func (s *ContractService) PackData(value, typ Micheline) ([]byte, error) {
+	query := "/chains/main/blocks/head/helpers/scripts/pack_data"
+	args, err := json.Marshal(struct {
+		Data Micheline `json:"data"`
+		Type Micheline `json:"type"`
+	}{value, typ})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not pack data '%s'", query)
+	}
+
+	resp, err := s.gt.Post(query, string(args))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not pack data '%s'", query)
+	}
+
+	var packed struct {
+		Packed string `json:"packed"`
+	}
+	err = json.Unmarshal(resp, &packed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not pack data '%s'", query)
+	}
+
+	b, err := hex.DecodeString(packed.Packed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not pack data '%s'", query)
+	}
+
+	return b, nil
+}
+
+// convertData walks a value along its type and converts the values of domain specific types with leaf
+func convertData(value, typ Micheline, leaf func(value Micheline, typ string) (Micheline, error)) (Micheline, error) {
+	if typ.Kind != MichelinePrim {
+		return value, errors.Errorf("invalid type %s", typ.Michelson())
+	}
+
+	mismatch := errors.Errorf("value %s does not match type %s", value.Michelson(), typ.Michelson())
+	convertArgs := func(types ...Micheline) (Micheline, error) {
+		if len(value.Args) != len(types) {
+			return value, mismatch
+		}
+		converted := NewMichelinePrim(value.Prim, make([]Micheline, len(types)), value.Annots...)
+		for k := range types {
+			arg, err := convertData(value.Args[k], types[k], leaf)
+			if err != nil {
+				return value, err
+			}
+			converted.Args[k] = arg
+		}
+		return converted, nil
+	}
+	convertSeq := func(convertItem func(Micheline) (Micheline, error)) (Micheline, error) {
+		if value.Kind != MichelineSeq {
+			return value, mismatch
+		}
+		converted := NewMichelineSeq(make([]Micheline, len(value.Seq))...)
+		for k, item := range value.Seq {
+			var err error
+			converted.Seq[k], err = convertItem(item)
+			if err != nil {
+				return value, err
+			}
+		}
+		return converted, nil
+	}
+
+	switch typ.Prim {
+	case "pair", "or", "option":
+		if len(typ.Args) != 2 && typ.Prim != "option" || len(typ.Args) != 1 && typ.Prim == "option" {
+			return value, errors.Errorf("invalid type %s", typ.Michelson())
+		}
+		if value.Kind != MichelinePrim {
+			return value, mismatch
+		}
+		switch {
+		case typ.Prim == "pair" && value.Prim == "Pair":
+			return convertArgs(typ.Args[0], typ.Args[1])
+		case typ.Prim == "or" && value.Prim == "Left":
+			return convertArgs(typ.Args[0])
+		case typ.Prim == "or" && value.Prim == "Right":
+			return convertArgs(typ.Args[1])
+		case typ.Prim == "option" && value.Prim == "Some":
+			return convertArgs(typ.Args[0])
+		case typ.Prim == "option" && value.Prim == "None":
+			return convertArgs()
+		}
+		return value, mismatch
+
+	case "list", "set":
+		if len(typ.Args) != 1 {
+			return value, errors.Errorf("invalid type %s", typ.Michelson())
+		}
+		return convertSeq(func(item Micheline) (Micheline, error) {
+			return convertData(item, typ.Args[0], leaf)
+		})
+
+	case "map", "big_map":
+		if len(typ.Args) != 2 {
+			return value, errors.Errorf("invalid type %s", typ.Michelson())
+		}
+		// Big maps held in a storage are referred to by their id
+		if typ.Prim == "big_map" && value.Kind == MichelineInt {
+			return value, nil
+		}
+		return convertSeq(func(item Micheline) (Micheline, error) {
+			if item.Kind != MichelinePrim || item.Prim != "Elt" || len(item.Args) != 2 {
+				return item, mismatch
+			}
+			key, err := convertData(item.Args[0], typ.Args[0], leaf)
+			if err != nil {
+				return item, err
+			}
+			val, err := convertData(item.Args[1], typ.Args[1], leaf)
+			if err != nil {
+				return item, err
+			}
+			return NewMichelinePrim("Elt", []Micheline{key, val}, item.Annots...), nil
+		})
+
+	case "lambda":
+		if len(typ.Args) != 2 {
+			return value, errors.Errorf("invalid type %s", typ.Michelson())
+		}
+		if value.Kind != MichelineSeq {
+			return value, mismatch
+		}
+		return convertCode(value, leaf)
+	}
+
+	return leaf(value, typ.Prim)
+}
+
+// convertCode walks the instructions of code, including the code of nested lambdas and contracts, and converts
+// the values pushed by PUSH instructions along their type with leaf
+func convertCode(code Micheline, leaf func(value Micheline, typ string) (Micheline, error)) (Micheline, error) {
+	switch code.Kind {
+	case MichelineSeq:
+		converted := NewMichelineSeq(make([]Micheline, len(code.Seq))...)
+		for k, instr := range code.Seq {
+			var err error
+			converted.Seq[k], err = convertCode(instr, leaf)
+			if err != nil {
+				return code, err
+			}
+		}
+		return converted, nil
+
+	case MichelinePrim:
+		if code.Prim == "PUSH" && len(code.Args) == 2 {
+			value, err := convertData(code.Args[1], code.Args[0], leaf)
+			if err != nil {
+				return code, err
+			}
+			return NewMichelinePrim(code.Prim, []Micheline{code.Args[0], value}, code.Annots...), nil
+		}
+		if len(code.Args) == 0 {
+			return code, nil
+		}
+		converted := NewMichelinePrim(code.Prim, make([]Micheline, len(code.Args)), code.Annots...)
+		for k, arg := range code.Args {
+			var err error
+			converted.Args[k], err = convertCode(arg, leaf)
+			if err != nil {
+				return code, err
+			}
+		}
+		return converted, nil
+	}
+
+	return code, nil
+}
+
+// optimizeData converts a value of a domain specific type from its readable to its optimized form
+func optimizeData(value Micheline, typ string) (Micheline, error) {
+	if typ == "timestamp" && value.Kind == MichelineString {
+		t, err := time.Parse(time.RFC3339, value.String)
+		if err != nil {
+			return value, errors.Errorf("invalid timestamp '%s'", value.String)
+		}
+		return NewMichelineInt(big.NewInt(t.Unix())), nil
+	}
+	if value.Kind != MichelineString {
+		return value, nil
+	}
+
+	var b []byte
+	var err error
+	switch typ {
+	case "address", "contract":
+		address, entrypoint := value.String, ""
+		if i := strings.Index(address, "%"); i >= 0 {
+			address, entrypoint = address[:i], address[i+1:]
+		}
+		b, err = forgeContractID(address)
+		if entrypoint != "default" {
+			b = append(b, entrypoint...)
+		}
+	case "key_hash":
+		b, err = forgePublicKeyHash(value.String)
+	case "key":
+		b, err = forgePublicKey(value.String)
+	case "signature":
+		b, err = signatureBytes(value.String)
+	case "chain_id":
+		b, err = decodeBase58(value.String, chainIDPrefix, 4)
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return value, errors.Wrapf(err, "invalid %s", typ)
+	}
+
+	return NewMichelineBytes(b), nil
+}
+
+// readableData converts a value of a domain specific type from its optimized to its readable form
+func readableData(value Micheline, typ string) (Micheline, error) {
+	if typ == "timestamp" && value.Kind == MichelineInt {
+		if !value.Int.IsInt64() {
+			return value, nil
+		}
+		return NewMichelineString(time.Unix(value.Int.Int64(), 0).UTC().Format(time.RFC3339)), nil
+	}
+	if value.Kind != MichelineBytes {
+		return value, nil
+	}
+
+	var s string
+	var err error
+	b := value.Bytes
+	switch typ {
+	case "address", "contract":
+		if len(b) < 22 {
+			return value, errors.Errorf("invalid %s 0x%x", typ, b)
+		}
+		s, err = decodeContractID(b[:22])
+		if len(b) > 22 {
+			s += "%" + string(b[22:])
+		}
+	case "key_hash":
+		s, err = decodePublicKeyHash(b)
+	case "key":
+		s, err = decodePublicKey(b)
+	case "signature":
+		if len(b) != 64 {
+			return value, errors.Errorf("invalid signature 0x%x", b)
+		}
+		s = b58cencode(b, genericSig)
+	case "chain_id":
+		if len(b) != 4 {
+			return value, errors.Errorf("invalid chain id 0x%x", b)
+		}
+		s = b58cencode(b, chainIDPrefix)
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return value, err
+	}
+
+	return NewMichelineString(s), nil
+}
+
+// decodePublicKeyHash decodes a forged implicit account address
+func decodePublicKeyHash(b []byte) (string, error) {
+	prefixes := [][]byte{tz1, tz2, tz3}
+	if len(b) != 21 || int(b[0]) >= len(prefixes) {
+		return "", errors.Errorf("invalid public key hash 0x%x", b)
+	}
+	return b58cencode(b[1:], prefixes[b[0]]), nil
+}
+
+// decodeContractID decodes a forged implicit or originated account address
+func decodeContractID(b []byte) (string, error) {
+	if len(b) == 22 && b[0] == 0 {
+		return decodePublicKeyHash(b[1:])
+	}
+	if len(b) == 22 && b[0] == 1 && b[21] == 0 {
+		return b58cencode(b[1:21], kt1), nil
+	}
+	return "", errors.Errorf("invalid contract address 0x%x", b)
+}
+
+// decodePublicKey decodes a forged public key
+func decodePublicKey(b []byte) (string, error) {
+	prefixes := []struct {
+		prefix []byte
+		length int
+	}{{edpk, 32}, {sppk, 33}, {p2pk, 33}}
+
+	if len(b) == 0 || int(b[0]) >= len(prefixes) || len(b) != 1+prefixes[b[0]].length {
+		return "", errors.Errorf("invalid public key 0x%x", b)
+	}
+	return b58cencode(b[1:], prefixes[b[0]].prefix), nil
+}
+
+// encodeMicheline encodes a Micheline expression in its binary form
+func encodeMicheline(m Micheline) ([]byte, error) {
+	switch m.Kind {
+	case MichelineInt:
+		if m.Int == nil {
+			return nil, errors.New("int literal has no value")
+		}
+		return append([]byte{michelineTagInt}, forgeZarithInt(m.Int)...), nil
+
+	case MichelineString:
+		return append([]byte{michelineTagString}, forgeBytes([]byte(m.String))...), nil
+
+	case MichelineBytes:
+		return append([]byte{michelineTagBytes}, forgeBytes(m.Bytes)...), nil
+
+	case MichelineSeq:
+		var seq []byte
+		for _, item := range m.Seq {
+			encoded, err := encodeMicheline(item)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, encoded...)
+		}
+		return append([]byte{michelineTagSeq}, forgeBytes(seq)...), nil
+
+	case MichelinePrim:
+		return encodeMichelinePrim(m)
+	}
+
+	return nil, errors.Errorf("invalid micheline kind %d", m.Kind)
+}
+
+// encodeMichelinePrim encodes a Micheline primitive application
+func encodeMichelinePrim(m Micheline) ([]byte, error) {
+	code := -1
+	for k, p := range michelinePrims {
+		if p == m.Prim {
+			code = k
+			break
+		}
+	}
+	if code < 0 {
+		return nil, errors.Errorf("unknown micheline primitive '%s'", m.Prim)
+	}
+
+	var args []byte
+	for _, arg := range m.Args {
+		encoded, err := encodeMicheline(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, encoded...)
+	}
+	annots := []byte(strings.Join(m.Annots, " "))
+
+	// Primitives with up to two arguments have a dedicated tag with and without annotations
+	if len(m.Args) <= 2 {
+		tag := byte(michelineTagPrim + 2*len(m.Args))
+		if len(m.Annots) > 0 {
+			tag++
+		}
+		encoded := append([]byte{tag, byte(code)}, args...)
+		if len(m.Annots) > 0 {
+			encoded = append(encoded, forgeBytes(annots)...)
+		}
+		return encoded, nil
+	}
+
+	encoded := append([]byte{michelineTagPrimN, byte(code)}, forgeBytes(args)...)
+	return append(encoded, forgeBytes(annots)...), nil
+}
+
+// decodeMicheline decodes a Micheline expression from its binary form, and returns the number of bytes read
+func decodeMicheline(b []byte) (Micheline, int, error) {
+	if len(b) == 0 {
+		return Micheline{}, 0, errors.New("unexpected end of micheline")
+	}
+
+	switch tag := b[0]; tag {
+	case michelineTagInt:
+		n, read, err := decodeZarithInt(b[1:])
+		if err != nil {
+			return Micheline{}, 0, err
+		}
+		return Micheline{Kind: MichelineInt, Int: n}, 1 + read, nil
+
+	case michelineTagString:
+		s, read, err := decodeBytes(b[1:])
+		if err != nil {
+			return Micheline{}, 0, err
+		}
+		return NewMichelineString(string(s)), 1 + read, nil
+
+	case michelineTagBytes:
+		s, read, err := decodeBytes(b[1:])
+		if err != nil {
+			return Micheline{}, 0, err
+		}
+		return NewMichelineBytes(append([]byte{}, s...)), 1 + read, nil
+
+	case michelineTagSeq:
+		items, read, err := decodeBytes(b[1:])
+		if err != nil {
+			return Micheline{}, 0, err
+		}
+		seq, err := decodeMichelineList(items)
+		if err != nil {
+			return Micheline{}, 0, err
+		}
+		return NewMichelineSeq(seq...), 1 + read, nil
+
+	case michelineTagPrim, michelineTagPrimAnnots, michelineTagPrim1, michelineTagPrim1Annots,
+		michelineTagPrim2, michelineTagPrim2Annots, michelineTagPrimN:
+		if len(b) < 2 || int(b[1]) >= len(michelinePrims) {
+			return Micheline{}, 0, errors.New("invalid micheline primitive")
+		}
+		prim := NewMichelinePrim(michelinePrims[b[1]], nil)
+		read := 2
+
+		if tag == michelineTagPrimN {
+			args, n, err := decodeBytes(b[read:])
+			if err != nil {
+				return Micheline{}, 0, err
+			}
+			read += n
+			prim.Args, err = decodeMichelineList(args)
+			if err != nil {
+				return Micheline{}, 0, err
+			}
+		} else {
+			for k := 0; k < int(tag-michelineTagPrim)/2; k++ {
+				arg, n, err := decodeMicheline(b[read:])
+				if err != nil {
+					return Micheline{}, 0, err
+				}
+				read += n
+				prim.Args = append(prim.Args, arg)
+			}
+		}
+
+		if tag == michelineTagPrimN || (tag-michelineTagPrim)%2 == 1 {
+			annots, n, err := decodeBytes(b[read:])
+			if err != nil {
+				return Micheline{}, 0, err
+			}
+			read += n
+			if len(annots) > 0 {
+				prim.Annots = strings.Split(string(annots), " ")
+			}
+		}
+
+		return prim, read, nil
+	}
+
+	return Micheline{}, 0, errors.Errorf("invalid micheline tag %d", b[0])
+}
+
+// decodeMichelineList decodes Micheline expressions filling b
+func decodeMichelineList(b []byte) ([]Micheline, error) {
+	list := []Micheline{}
+	for len(b) > 0 {
+		item, n, err := decodeMicheline(b)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		b = b[n:]
+	}
+	return list, nil
+}
+
+// decodeBytes decodes bytes prefixed with their length, and returns the number of bytes read
+func decodeBytes(b []byte) ([]byte, int, error) {
+	if len(b) < 4 {
+		return nil, 0, errors.New("unexpected end of micheline")
+	}
+	length := binary.BigEndian.Uint32(b)
+	if length > michelineMaxBinaryLength || int(length) > len(b)-4 {
+		return nil, 0, errors.New("unexpected end of micheline")
+	}
+	return b[4 : 4+length], 4 + int(length), nil
+}
+
+// forgeZarithInt forges an integer as a signed zarith number
+func forgeZarithInt(n *big.Int) []byte {
+	v := new(big.Int).Abs(n)
+	sign := byte(0)
+	if n.Sign() < 0 {
+		sign = 0x40
+	}
+
+	// The first byte holds the sign and 6 bits, the following bytes 7 bits each
+	first := byte(new(big.Int).And(v, big.NewInt(0x3f)).Int64()) | sign
+	v.Rsh(v, 6)
+	if v.Sign() == 0 {
+		return []byte{first}
+	}
+
+	forged := []byte{first | 0x80}
+	for {
+		b := byte(new(big.Int).And(v, big.NewInt(0x7f)).Int64())
+		v.Rsh(v, 7)
+		if v.Sign() == 0 {
+			return append(forged, b)
+		}
+		forged = append(forged, b|0x80)
+	}
+}
+
+// decodeZarithInt decodes a signed zarith number, and returns the number of bytes read
+func decodeZarithInt(b []byte) (*big.Int, int, error) {
+	if len(b) == 0 {
+		return nil, 0, errors.New("unexpected end of micheline")
+	}
+
+	n := big.NewInt(int64(b[0] & 0x3f))
+	shift := uint(6)
+	read := 1
+	for more := b[0]&0x80 != 0; more; read++ {
+		if read >= len(b) {
+			return nil, 0, errors.New("unexpected end of micheline")
+		}
+		n.Or(n, new(big.Int).Lsh(big.NewInt(int64(b[read]&0x7f)), shift))
+		shift += 7
+		more = b[read]&0x80 != 0
+	}
+
+	if b[0]&0x40 != 0 {
+		n.Neg(n)
+	}
+	return n, read, nil
+}
@@ -0,0 +1,825 @@
+package gotezos
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Directory of the FileJournal used by RunPayout when a payout run has no journal
+	payoutJournalDir = "payouts"
+
+	// How many blocks are fetched concurrently when checking payments
+	paymentScanWorkers = 50
+)
+
+// Statuses of the batches of a payout run recorded in its journal. The payments of applied batches were made and
+// the payments of failed batches were not. Signed and injected batches may still be included, they are resolved
+// on the chain when the run is resumed.
+const (
+	PayoutBatchSigned   = "signed"
+	PayoutBatchInjected = "injected"
+	PayoutBatchApplied  = "applied"
+	PayoutBatchFailed   = "failed"
+)
+
+// PayoutRun is a payout injected batch by batch by RunPayout. ID names the run in its journal and must be reused to
+// resume the run. Journal defaults to a FileJournal in the payouts directory, and Wait configures how each batch is
+// waited for before the next one is injected, its Branch is set to the branch of each batch.
+type PayoutRun struct {
+	ID       string
+	Payments []Payment
+	Options  BatchOptions
+	Wait     WaitOptions
+	Journal  Journal
+}
+
+// PayoutBatch is a batch of a payout run as recorded in its journal. Operation is the signed operation, kept so
+// the batch can be injected again when the run is resumed.
+type PayoutBatch struct {
+	Index         int
+	Payments      []Payment
+	OperationHash string
+	Operation     string
+	Branch        string
+	Status        string
+	BlockHash     string
+	Error         string
+}
+
+// BatchPreview is a batch of payments simulated by DryRunBatchPayment. Contents are the transfers exactly as
+// they would be signed, with their counters, fees and limits, and OperationBytes are their unsigned forged
+// bytes. Receipts are the simulated contents with their metadata, as preapply would return them. Failures
+// lists why the batch would not be applied. Estimates and totals are only set when the simulation succeeded.
+type BatchPreview struct {
+	Payments       []Payment
+	Contents       Conts
+	OperationBytes string
+	Estimates      []OperationEstimate
+	Amount         Mutez
+	Fees           Mutez
+	Burn           Mutez
+	TotalDebited   Mutez
+	Receipts       OperationContentsList
+	Failures       []string
+}
+
+// RunPayout pays a payout run from wallet, injecting its batches one by one and waiting for each of them to be
+// included before the next one. Every batch is recorded in the journal of the run before it is injected and again
+// as its status changes. A run stopped by an error or a crash is resumed by calling RunPayout again with the same
+// ID: batches left signed or injected are injected again and waited for, since a signed operation can only be
+// included once, and only the payments that were not applied are paid. It returns every batch of the run.
+func (o *OperationService) RunPayout(run PayoutRun, wallet Wallet) ([]PayoutBatch, error) {
+	journal := run.Journal
+	if journal == nil {
+		fileJournal, err := NewFileJournal(payoutJournalDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+		}
+		journal = fileJournal
+	}
+
+	opts, err := o.batchOptions(run.Options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+	}
+
+	batches, err := journal.Batches(run.ID)
+	if err != nil {
+		return batches, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+	}
+
+	// Find out what happened to the batches in flight when the run stopped
+	for k := range batches {
+		if batches[k].Status != PayoutBatchSigned && batches[k].Status != PayoutBatchInjected {
+			continue
+		}
+		batches[k], err = o.resolvePayoutBatch(batches[k], run.Wait)
+		if err != nil {
+			return batches, errors.Wrapf(err, "could not resume payout '%s'", run.ID)
+		}
+		if err := journal.Record(run.ID, batches[k]); err != nil {
+			return batches, errors.Wrapf(err, "could not resume payout '%s'", run.ID)
+		}
+	}
+
+	index := 0
+	if len(batches) > 0 {
+		index = batches[len(batches)-1].Index + 1
+	}
+
+	remaining := unpaidPayments(run.Payments, batches)
+	for len(remaining) > 0 {
+		blockHead, err := o.gt.Block.GetHead()
+		if err != nil {
+			return batches, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+		}
+
+		counter, err := o.Counter.peek(wallet.Address)
+		if err != nil {
+			return batches, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+		}
+
+		batch := o.planBatch(blockHead.Hash, counter, wallet.Address, remaining, opts)
+		if batch.err != nil {
+			return batches, errors.Wrapf(batch.err, "could not run payout '%s'", run.ID)
+		}
+		remaining = remaining[len(batch.payments):]
+		if len(batch.contents.Contents) == 0 {
+			continue
+		}
+
+		counter, err = o.Counter.Next(wallet.Address, len(batch.contents.Contents))
+		if err != nil {
+			return batches, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+		}
+
+		signed, err := o.createBatch(blockHead, counter, counter, wallet, batch, opts)
+		if err != nil {
+			o.Counter.Release(wallet.Address)
+			return batches, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+		}
+		o.Counter.Track(wallet.Address, blockHead, trackedOperations(signed)...)
+
+		for k, operation := range signed {
+			payoutBatch := PayoutBatch{
+				Index:         index,
+				Payments:      operation.payments,
+				OperationHash: operation.hash,
+				Operation:     operation.operation,
+				Branch:        blockHead.Hash,
+				Status:        PayoutBatchSigned,
+			}
+			index++
+
+			payoutBatch, err = o.injectPayoutBatch(run, journal, payoutBatch)
+			batches = append(batches, payoutBatch)
+			if err != nil {
+				for _, rest := range signed[k+1:] {
+					o.Counter.Reject(rest.hash)
+				}
+				return batches, errors.Wrapf(err, "could not run payout '%s'", run.ID)
+			}
+		}
+	}
+
+	return batches, nil
+}
+
+// injectPayoutBatch records a signed batch of a payout run, injects it and waits for it to be included. It returns
+// an error if the batch was not applied, or if its status is unknown.
+func (o *OperationService) injectPayoutBatch(run PayoutRun, journal Journal, batch PayoutBatch) (PayoutBatch, error) {
+	err := journal.Record(run.ID, batch)
+	if err != nil {
+		o.Counter.Reject(batch.OperationHash)
+		return batch, err
+	}
+
+	// The node may have received the operation even if injecting it failed, so the batch stays signed
+	_, err = o.InjectOperation(batch.Operation)
+	if err != nil {
+		batch.Error = err.Error()
+		if recordErr := journal.Record(run.ID, batch); recordErr != nil {
+			return batch, recordErr
+		}
+		return batch, errors.Wrapf(err, "could not inject batch %d", batch.Index)
+	}
+
+	batch.Status = PayoutBatchInjected
+	err = journal.Record(run.ID, batch)
+	if err != nil {
+		return batch, err
+	}
+
+	batch, err = o.waitPayoutBatch(batch, run.Wait)
+	if err != nil {
+		return batch, errors.Wrapf(err, "could not wait for batch %d", batch.Index)
+	}
+
+	err = journal.Record(run.ID, batch)
+	if err != nil {
+		return batch, err
+	}
+
+	if batch.Status != PayoutBatchApplied {
+		return batch, errors.Errorf("batch %d %s: %s", batch.Index, batch.Status, batch.Error)
+	}
+
+	return batch, nil
+}
+
+// resolvePayoutBatch finds out whether a batch left signed or injected by a stopped payout run was applied. The
+// batch is injected again in case it never reached the node, which is harmless since it can only be included once.
+func (o *OperationService) resolvePayoutBatch(batch PayoutBatch, wait WaitOptions) (PayoutBatch, error) {
+	o.InjectOperation(batch.Operation)
+	return o.waitPayoutBatch(batch, wait)
+}
+
+// waitPayoutBatch waits for a batch of a payout run to be included or to be known never to be included, and sets
+// its status. A batch refused by the mempool may still be included on another branch, so it only fails once its
+// branch is older than the max operations ttl. An error is returned when the outcome of the batch could not be
+// determined.
+func (o *OperationService) waitPayoutBatch(batch PayoutBatch, wait WaitOptions) (PayoutBatch, error) {
+	wait.Branch = batch.Branch
+	if wait.PollInterval <= 0 {
+		wait.PollInterval = waitPollInterval
+	}
+
+	var receipt OperationReceipt
+	for {
+		// Check the expiry before Wait scans the blocks, so a batch included before it expired is found
+		expired, err := o.branchExpired(batch.Branch)
+		if err != nil {
+			return batch, err
+		}
+
+		receipt, err = o.Wait(batch.OperationHash, wait)
+		if err == nil {
+			break
+		}
+
+		switch receipt.Status {
+		case OperationStatusExpired:
+			batch.Status = PayoutBatchFailed
+			batch.Error = err.Error()
+			return batch, nil
+		case OperationStatusRefused:
+			batch.Error = err.Error()
+			if expired {
+				batch.Status = PayoutBatchFailed
+				return batch, nil
+			}
+			time.Sleep(wait.PollInterval)
+			continue
+		}
+		return batch, err
+	}
+
+	batch.BlockHash = receipt.BlockHash
+	batch.Error = ""
+	batch.Status = PayoutBatchApplied
+	if receipt.Status != OperationStatusApplied {
+		batch.Status = PayoutBatchFailed
+		batch.Error = "operation " + receipt.Status
+	}
+
+	return batch, nil
+}
+
+// branchExpired returns whether operations forged on a branch can no longer be included, because the head is
+// more than the max operations ttl after the branch
+func (o *OperationService) branchExpired(hash string) (bool, error) {
+	branch, err := o.gt.Block.Get(hash)
+	if err != nil {
+		return false, err
+	}
+	head, err := o.gt.Block.GetHead()
+	if err != nil {
+		return false, err
+	}
+	return head.Header.Level > branch.Header.Level+head.Metadata.MaxOperationsTTL, nil
+}
+
+// unpaidPayments returns the payments not made by the applied batches of a payout run. Payments are matched by
+// address and amount, so the same payment listed twice is paid twice.
+func unpaidPayments(payments []Payment, batches []PayoutBatch) []Payment {
+	paid := make(map[Payment]int)
+	for _, batch := range batches {
+		if batch.Status != PayoutBatchApplied {
+			continue
+		}
+		for _, payment := range batch.Payments {
+			paid[payment]++
+		}
+	}
+
+	var unpaid []Payment
+	for _, payment := range payments {
+		if paid[payment] > 0 {
+			paid[payment]--
+			continue
+		}
+		unpaid = append(unpaid, payment)
+	}
+	return unpaid
+}
+
+// BatchOptions configures how CreateBatchPaymentWithOptions and DryRunBatchPayment pack payments into batches. MaxTransfers
+// defaults to 100 transfers per batch, MaxSize to the max operation data length of the network in bytes and MaxGas
+// to the hard gas limit per block, and the size and gas limits can only be lowered. Fee and GasLimit replace the
+// estimated fee and gas limit of every transfer when they are not zero.
+type BatchOptions struct {
+	MaxTransfers int
+	MaxSize      int
+	MaxGas       int
+	Fee          Mutez
+	GasLimit     int
+}
+
+// plannedBatch is a batch of payments forged by planBatches, with the error preventing it from being forged
+type plannedBatch struct {
+	payments       []Payment
+	contents       Conts
+	estimates      []OperationEstimate
+	operationBytes string
+	err            error
+}
+
+// DryRunBatchPayment simulates batch payments from source without signing them, and returns a preview of every
+// batch for review before the payout is signed with SignBatchPreview. Payments are packed into batches like
+// CreateBatchPaymentWithOptions would. Batches that would fail, including batches that would overdraw the balance
+// of source after the previous batches, are reported in their Failures.
+func (o *OperationService) DryRunBatchPayment(payments []Payment, source string, opts BatchOptions) ([]BatchPreview, error) {
+	var previews []BatchPreview
+
+	opts, err := o.batchOptions(opts)
+	if err != nil {
+		return previews, errors.Wrap(err, "could not dry run batch payment")
+	}
+
+	blockHead, err := o.gt.Block.GetHead()
+	if err != nil {
+		return previews, errors.Wrap(err, "could not dry run batch payment")
+	}
+
+	balance, err := o.gt.Account.GetBalance(source)
+	if err != nil {
+		return previews, errors.Wrap(err, "could not dry run batch payment")
+	}
+
+	counter, err := o.Counter.peek(source)
+	if err != nil {
+		return previews, errors.Wrap(err, "could not dry run batch payment")
+	}
+
+	batches := o.planBatches(blockHead.Hash, counter, source, payments, opts)
+
+	debited := Mutez(0)
+	for _, batch := range batches {
+		preview, err := o.previewBatch(batch)
+		if err != nil {
+			return previews, errors.Wrap(err, "could not dry run batch payment")
+		}
+
+		if len(preview.Failures) == 0 {
+			debited, err = debited.Add(preview.TotalDebited)
+			if err != nil {
+				return previews, errors.Wrap(err, "could not dry run batch payment")
+			}
+			if debited > balance {
+				preview.Failures = append(preview.Failures, fmt.Sprintf("balance of %s tez does not cover the %s tez debited by this and the previous batches", balance.Tez(), debited.Tez()))
+			}
+		}
+
+		previews = append(previews, preview)
+	}
+
+	return previews, nil
+}
+
+// previewBatch simulates a planned batch of payments. The node only runs contents at the next counter of their
+// source, so the batch is simulated from there and its receipts are renumbered to the counters it was planned and
+// estimated with, which do not change how the contents are applied. Failures of the simulation are reported in the
+// preview, and only errors preventing the simulation from running are returned.
+func (o *OperationService) previewBatch(batch plannedBatch) (BatchPreview, error) {
+	preview := BatchPreview{
+		Payments:       batch.payments,
+		Contents:       batch.contents,
+		OperationBytes: batch.operationBytes,
+	}
+	if len(preview.Contents.Contents) == 0 {
+		return preview, nil
+	}
+
+	// Simulate the batch as planned, or without fees and limits if it could not be estimated
+	var simulated Operation
+	var err error
+	if batch.err != nil {
+		_, simulated, err = o.simulate(preview.Contents)
+	} else {
+		_, simulated, err = o.simulateAtCounter(preview.Contents)
+	}
+	if err != nil {
+		preview.Failures = append(preview.Failures, err.Error())
+		return preview, nil
+	}
+	preview.Receipts = simulated.Contents
+	if counter, err := strconv.Atoi(preview.Contents.Contents[0].Counter); err == nil {
+		renumberReceipts(preview.Receipts, counter)
+	}
+	if preview.Failures = contentsFailures(simulated.Contents); len(preview.Failures) > 0 {
+		return preview, nil
+	}
+	if batch.err != nil {
+		preview.Failures = append(preview.Failures, batch.err.Error())
+		return preview, nil
+	}
+
+	preview.Estimates = batch.estimates
+	for k, content := range preview.Contents.Contents {
+		if preview.Amount, err = preview.Amount.Add(content.Amount); err != nil {
+			return preview, err
+		}
+		if preview.Fees, err = preview.Fees.Add(batch.estimates[k].Fee); err != nil {
+			return preview, err
+		}
+		if preview.Burn, err = preview.Burn.Add(batch.estimates[k].Burn); err != nil {
+			return preview, err
+		}
+	}
+
+	preview.TotalDebited, err = preview.Amount.Add(preview.Fees)
+	if err != nil {
+		return preview, err
+	}
+	preview.TotalDebited, err = preview.TotalDebited.Add(preview.Burn)
+	if err != nil {
+		return preview, err
+	}
+
+	return preview, nil
+}
+
+// batchOptions fills the defaults of opts and caps its limits to the limits of the network
+func (o *OperationService) batchOptions(opts BatchOptions) (BatchOptions, error) {
+	hardGasLimitPerBlock, err := strconv.Atoi(o.gt.Constants.HardGasLimitPerBlock)
+	if err != nil {
+		return opts, errors.Wrap(err, "invalid hard gas limit per block constant")
+	}
+
+	if opts.MaxTransfers <= 0 {
+		opts.MaxTransfers = batchSize
+	}
+	if opts.MaxSize <= 0 || opts.MaxSize > o.gt.Constants.MaxOperationDataLength {
+		opts.MaxSize = o.gt.Constants.MaxOperationDataLength
+	}
+	if opts.MaxGas <= 0 || opts.MaxGas > hardGasLimitPerBlock {
+		opts.MaxGas = hardGasLimitPerBlock
+	}
+
+	return opts, nil
+}
+
+// planBatches packs payments from source into batches fitting opts, numbering their transfers from counter.
+// Batches that could not be forged are kept with their error, and the following batches are still planned.
+func (o *OperationService) planBatches(branchHash string, counter int, source string, payments []Payment, opts BatchOptions) []plannedBatch {
+	var batches []plannedBatch
+
+	for len(payments) > 0 {
+		batch := o.planBatch(branchHash, counter, source, payments, opts)
+		batches = append(batches, batch)
+		counter += len(batch.contents.Contents)
+		payments = payments[len(batch.payments):]
+	}
+
+	return batches
+}
+
+// planBatch forges the largest batch from the first payments that fits the size and gas limits of opts. The
+// batch is shrunk in proportion to how much it exceeds the limits until it fits.
+func (o *OperationService) planBatch(branchHash string, counter int, source string, payments []Payment, opts BatchOptions) plannedBatch {
+	n := len(payments)
+	if n > opts.MaxTransfers {
+		n = opts.MaxTransfers
+	}
+
+	for {
+		batch := o.forgeBatch(branchHash, counter, source, payments[:n], opts)
+		if batch.err != nil || batchFits(batch, opts) {
+			return batch
+		}
+		if n == 1 {
+			batch.err = errors.Errorf("payment to %s does not fit in a batch", payments[0].Address)
+			return batch
+		}
+
+		size, gas := batchUsage(batch)
+		next := n * opts.MaxSize / size
+		if gas > 0 && n*opts.MaxGas/gas < next {
+			next = n * opts.MaxGas / gas
+		}
+		if next >= n {
+			next = n - 1
+		}
+		if next < 1 {
+			next = 1
+		}
+		n = next
+	}
+}
+
+// forgeBatch estimates and forges the transfers of a batch of payments using consecutive counters from counter
+func (o *OperationService) forgeBatch(branchHash string, counter int, source string, payments []Payment, opts BatchOptions) plannedBatch {
+	return o.forgeContents(plannedBatch{
+		payments: payments,
+		contents: batchContents(branchHash, counter, source, payments),
+	}, opts)
+}
+
+// forgeContents estimates and forges the contents of a batch
+func (o *OperationService) forgeContents(batch plannedBatch, opts BatchOptions) plannedBatch {
+	if len(batch.contents.Contents) == 0 {
+		return batch
+	}
+
+	// Estimate the gas, storage and fee of every transfer, storage is needed for unallocated destinations
+	estimates, err := o.Estimate(batch.contents)
+	if err != nil {
+		batch.err = err
+		return batch
+	}
+	batch.estimates = overrideEstimates(estimates, opts.Fee, opts.GasLimit)
+	batch.contents = applyEstimates(batch.contents, batch.estimates)
+
+	batch.operationBytes, batch.err = o.forgeOperation(batch.contents)
+	return batch
+}
+
+// batchUsage returns the size in bytes of a forged batch once signed, and the total of its gas limits
+func batchUsage(batch plannedBatch) (int, int) {
+	gas := 0
+	for _, content := range batch.contents.Contents {
+		gasLimit, _ := strconv.Atoi(content.GasLimit)
+		gas += gasLimit
+	}
+	return len(batch.operationBytes)/2 + 64, gas
+}
+
+// batchFits returns whether a forged batch is within the size and gas limits of opts
+func batchFits(batch plannedBatch, opts BatchOptions) bool {
+	size, gas := batchUsage(batch)
+	return size <= opts.MaxSize && gas <= opts.MaxGas
+}
+
+// isBatchLimitError returns whether an error of the node means an operation is too large or uses too much gas
+// to be included in a block
+func isBatchLimitError(err error) bool {
+	for _, id := range []string{"oversized_operation", "gas_exhausted.block"} {
+		if strings.Contains(err.Error(), id) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignBatchPreview signs a batch previewed by DryRunBatchPayment and preapplies it, and returns the signed operation
+// bytes ready to inject. The contents are signed exactly as previewed, so an error is returned if the wallet is not
+// the source of the batch, if the counters of the source moved since the preview, or if the node forges the contents
+// differently. Previews must be signed in order, before their branch becomes older than the max operations ttl.
+func (o *OperationService) SignBatchPreview(preview BatchPreview, wallet Wallet) (string, error) {
+	contents := preview.Contents
+	if len(preview.Failures) > 0 {
+		return "", errors.Errorf("could not sign batch preview, batch would fail: %s", preview.Failures[0])
+	}
+	if len(contents.Contents) == 0 {
+		return "", errors.New("could not sign batch preview, batch has no transfers")
+	}
+	for _, content := range contents.Contents {
+		if content.Source != wallet.Address {
+			return "", errors.Errorf("could not sign batch preview, transfer source %s is not wallet %s", content.Source, wallet.Address)
+		}
+	}
+
+	counter, err := o.Counter.Next(wallet.Address, len(contents.Contents))
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign batch preview")
+	}
+
+	fullOperation, err := o.signPreview(preview, counter, wallet)
+	if err != nil {
+		o.Counter.Release(wallet.Address)
+		return "", errors.Wrap(err, "could not sign batch preview")
+	}
+
+	return fullOperation, nil
+}
+
+// signPreview checks the counter and forged bytes of a previewed batch before signing and preapplying it, and
+// tracks the counters of the signed operation as in flight
+func (o *OperationService) signPreview(preview BatchPreview, counter int, wallet Wallet) (string, error) {
+	if first := preview.Contents.Contents[0].Counter; first != strconv.Itoa(counter) {
+		return "", errors.Errorf("preview is stale, it starts at counter %s but the next counter is %d", first, counter)
+	}
+
+	operationBytes, err := o.forgeOperation(preview.Contents)
+	if err != nil {
+		return "", err
+	}
+	if operationBytes != preview.OperationBytes {
+		return "", errors.New("forged operation does not match the preview")
+	}
+
+	blockHead, err := o.gt.Block.Get(preview.Contents.Branch)
+	if err != nil {
+		return "", err
+	}
+
+	edsig, fullOperation, err := signOperation(operationBytes, wallet)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = o.preApplyOperations(preview.Contents, edsig, blockHead)
+	if err != nil {
+		return "", err
+	}
+
+	opHash, err := operationHash(fullOperation)
+	if err != nil {
+		return "", err
+	}
+	o.Counter.Track(wallet.Address, blockHead, TrackedOperation{Hash: opHash, First: counter, Last: counter + len(preview.Contents.Contents) - 1})
+
+	return fullOperation, nil
+}
+
+// contentsFailures describes the contents of a simulated operation that were not applied
+func contentsFailures(contents OperationContentsList) []string {
+	var failures []string
+	for k, content := range contents {
+		if _, err := appliedResult(content); err != nil {
+			destination := ""
+			if transaction, ok := content.(*TransactionContents); ok {
+				destination = transaction.Destination
+			}
+			failures = append(failures, fmt.Sprintf("content %d to %s: %s", k, destination, err.Error()))
+		}
+	}
+	return failures
+}
+
+// PaidPayment is a payment found on chain by CheckPayments
+type PaidPayment struct {
+	Payment
+	OperationHash string
+	Level         int
+}
+
+// PaymentCheck is the outcome of CheckPayments. Paid are the payments already made on chain and Unpaid are the
+// payments left to make, in the order they were given.
+type PaymentCheck struct {
+	Paid   []PaidPayment
+	Unpaid []Payment
+}
+
+// paymentScanJob is a block scanned by CheckPayments
+type paymentScanJob struct {
+	payer string
+	level int
+}
+
+// paymentScanJobResult is the applied transfers of a payer found in a block by CheckPayments
+type paymentScanJobResult struct {
+	level     int
+	transfers []PaidPayment
+	err       error
+}
+
+// CheckPayments scans the blocks from the end of cycle to level last for transfers from payer, and reports which of
+// the payments for that cycle, as returned by DelegateReport.GetPayments, were already made. Last is capped at the
+// head level. A payment is made by an applied transfer of the same amount to the same address, and every transfer
+// accounts for a single payment. Transfers made by a smart contract payer as internal operations are found as well.
+func (o *OperationService) CheckPayments(payer string, cycle int, last int, payments []Payment) (PaymentCheck, error) {
+	check := PaymentCheck{}
+
+	head, err := o.gt.Block.GetHead()
+	if err != nil {
+		return check, errors.Wrapf(err, "could not check payments of cycle %d", cycle)
+	}
+	if last > head.Header.Level {
+		last = head.Header.Level
+	}
+
+	first := (cycle+1)*o.gt.Constants.BlocksPerCycle + 1
+	if first > last {
+		return check, errors.Errorf("could not check payments of cycle %d, first level %d is after last level %d", cycle, first, last)
+	}
+	levels := last - first + 1
+
+	done := make(chan struct{})
+	defer close(done)
+
+	jobs := make(chan paymentScanJob, paymentScanWorkers)
+	results := make(chan paymentScanJobResult, paymentScanWorkers)
+
+	for w := 1; w <= paymentScanWorkers; w++ {
+		go o.paymentScanWorker(jobs, results, done)
+	}
+
+	// Jobs are queued as the workers take them, and no longer once a block could not be scanned
+	go func() {
+		defer close(jobs)
+		for level := first; level <= last; level++ {
+			select {
+			case jobs <- paymentScanJob{payer: payer, level: level}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	byLevel := make(map[int][]PaidPayment)
+	for i := 0; i < levels; i++ {
+		result := <-results
+		if result.err != nil {
+			return check, errors.Wrapf(result.err, "could not check payments of cycle %d", cycle)
+		}
+		byLevel[result.level] = result.transfers
+	}
+
+	// Match payments with the earliest transfers first
+	var transfers []PaidPayment
+	for level := first; level <= last; level++ {
+		transfers = append(transfers, byLevel[level]...)
+	}
+
+	used := make([]bool, len(transfers))
+	for _, payment := range payments {
+		found := false
+		for k, transfer := range transfers {
+			if !used[k] && transfer.Payment == payment {
+				used[k] = true
+				found = true
+				check.Paid = append(check.Paid, transfer)
+				break
+			}
+		}
+		if !found {
+			check.Unpaid = append(check.Unpaid, payment)
+		}
+	}
+
+	return check, nil
+}
+
+func (o *OperationService) paymentScanWorker(jobs <-chan paymentScanJob, results chan<- paymentScanJobResult, done <-chan struct{}) {
+	for j := range jobs {
+		result := paymentScanJobResult{level: j.level}
+		operations, err := o.getManagerOperations(j.level)
+		if err != nil {
+			result.err = err
+		} else {
+			result.transfers = payerTransfers(j.payer, j.level, operations)
+		}
+
+		select {
+		case results <- result:
+		case <-done:
+			return
+		}
+	}
+}
+
+// getManagerOperations gets the manager operations of the block at level
+func (o *OperationService) getManagerOperations(level int) ([]Operation, error) {
+	var operations []Operation
+	query := "/chains/main/blocks/" + strconv.Itoa(level) + "/operations/3"
+	resp, err := o.gt.Get(query, nil)
+	if err != nil {
+		return operations, errors.Wrapf(err, "could not get manager operations '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, &operations)
+	if err != nil {
+		return operations, errors.Wrapf(err, "could not get manager operations '%s'", query)
+	}
+
+	return operations, nil
+}
+
+// payerTransfers returns the applied transfers from payer in operations, including internal transfers
+func payerTransfers(payer string, level int, operations []Operation) []PaidPayment {
+	var transfers []PaidPayment
+
+	add := func(hash, source, destination string, amount Mutez, status string) {
+		if source != payer || status != OperationStatusApplied {
+			return
+		}
+		transfers = append(transfers, PaidPayment{
+			Payment:       Payment{Address: destination, Amount: amount},
+			OperationHash: hash,
+			Level:         level,
+		})
+	}
+
+	for _, operation := range operations {
+		for _, contents := range operation.Contents {
+			if transaction, ok := contents.(*TransactionContents); ok && transaction.Metadata != nil {
+				add(operation.Hash, transaction.Source, transaction.Destination, transaction.Amount, transaction.Metadata.OperationResult.Status)
+			}
+			_, internal, _ := contentsResult(contents)
+			for _, op := range internal {
+				if transaction, ok := op.(*InternalTransaction); ok {
+					add(operation.Hash, transaction.Source, transaction.Destination, transaction.Amount, transaction.Result.Status)
+				}
+			}
+		}
+	}
+
+	return transfers
+}
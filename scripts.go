@@ -0,0 +1,151 @@
+package gotezos
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// RunCodeInput is a script run by the node with its storage and input. ChainID is the chain of the node when
+// empty, and Entrypoint is the default entrypoint when empty.
+type RunCodeInput struct {
+	Script     Micheline `json:"script"`
+	Storage    Micheline `json:"storage"`
+	Input      Micheline `json:"input"`
+	Amount     Mutez     `json:"amount"`
+	ChainID    string    `json:"chain_id"`
+	Source     string    `json:"source,omitempty"`
+	Payer      string    `json:"payer,omitempty"`
+	Gas        int       `json:"gas,string,omitempty"`
+	Entrypoint string    `json:"entrypoint,omitempty"`
+}
+
+// RunCodeResult is the storage, operations and big map diff resulting from running a script
+type RunCodeResult struct {
+	Storage    Micheline                `json:"storage"`
+	Operations InternalOperationResults `json:"operations"`
+	BigMapDiff []StructBigMapDiff       `json:"big_map_diff,omitempty"`
+}
+
+// TraceCodeResult is the result of running a script with the stack after each instruction
+type TraceCodeResult struct {
+	RunCodeResult
+	Trace []TraceStep `json:"trace"`
+}
+
+// TraceStep is the stack after the instruction at Location and the gas left, a number or "unaccounted"
+type TraceStep struct {
+	Location int              `json:"location"`
+	Gas      string           `json:"gas"`
+	Stack    []TraceStackItem `json:"stack"`
+}
+
+// TraceStackItem is a value on the stack of a trace
+type TraceStackItem struct {
+	Item  Micheline `json:"item"`
+	Annot string    `json:"annot,omitempty"`
+}
+
+// TypecheckCodeResult is the stack types before and after each instruction of a script, and the gas left, a
+// number or "unaccounted"
+type TypecheckCodeResult struct {
+	TypeMap []TypeMapEntry `json:"type_map"`
+	Gas     string         `json:"gas"`
+}
+
+// TypeMapEntry is the stack types before and after the instruction at Location
+type TypeMapEntry struct {
+	Location    int         `json:"location"`
+	StackBefore []StackType `json:"stack_before"`
+	StackAfter  []StackType `json:"stack_after"`
+}
+
+// StackType is the type of a value on the stack with its annotations
+type StackType struct {
+	Type   Micheline `json:"type"`
+	Annots []string  `json:"annots,omitempty"`
+}
+
+// RunCode runs a script with the node and returns the resulting storage, operations and big map diff
+func (s *ContractService) RunCode(input RunCodeInput) (RunCodeResult, error) {
+	var result RunCodeResult
+	err := s.runCode("run_code", input, &result)
+	if err != nil {
+		return result, errors.Wrap(err, "could not run code")
+	}
+	return result, nil
+}
+
+// TraceCode runs a script with the node like RunCode, and also returns the stack and gas after each instruction
+func (s *ContractService) TraceCode(input RunCodeInput) (TraceCodeResult, error) {
+	var result TraceCodeResult
+	err := s.runCode("trace_code", input, &result)
+	if err != nil {
+		return result, errors.Wrap(err, "could not trace code")
+	}
+	return result, nil
+}
+
+// TypecheckCode typechecks a script with the node and returns the stack types of its instructions
+func (s *ContractService) TypecheckCode(code Micheline) (TypecheckCodeResult, error) {
+	var result TypecheckCodeResult
+	args := struct {
+		Program Micheline `json:"program"`
+	}{code}
+
+	err := s.postScript("typecheck_code", args, &result)
+	if err != nil {
+		return result, errors.Wrap(err, "could not typecheck code")
+	}
+	return result, nil
+}
+
+// TypecheckData typechecks a value against a Michelson type with the node and returns the gas left
+func (s *ContractService) TypecheckData(value, typ Micheline) (string, error) {
+	var result struct {
+		Gas string `json:"gas"`
+	}
+	args := struct {
+		Data Micheline `json:"data"`
+		Type Micheline `json:"type"`
+	}{value, typ}
+
+	err := s.postScript("typecheck_data", args, &result)
+	if err != nil {
+		return "", errors.Wrap(err, "could not typecheck data")
+	}
+	return result.Gas, nil
+}
+
+// runCode fills in the chain id of a script run and posts it to the rpc
+func (s *ContractService) runCode(rpc string, input RunCodeInput, out interface{}) error {
+	if input.ChainID == "" {
+		chainID, err := s.gt.Network.GetChainID()
+		if err != nil {
+			return err
+		}
+		input.ChainID = chainID
+	}
+	return s.postScript(rpc, input, out)
+}
+
+// postScript posts args to a scripts helper rpc of the head block and unmarshals the response into out
+func (s *ContractService) postScript(rpc string, args interface{}, out interface{}) error {
+	query := "/chains/main/blocks/head/helpers/scripts/" + rpc
+	v, err := json.Marshal(args)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal '%s'", query)
+	}
+
+	resp, err := s.gt.Post(query, string(v))
+	if err != nil {
+		return errors.Wrapf(err, "could not post '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, out)
+	if err != nil {
+		return errors.Wrapf(err, "could not unmarshal '%s'", query)
+	}
+
+	return nil
+}
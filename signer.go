@@ -0,0 +1,56 @@
+package gotezos
+
+import (
+	"github.com/Messer4/base58check"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// Watermark of the messages signed for operations
+	operationWatermark = byte(3)
+
+	// For (de)constructing signatures
+	edsig      = []byte{9, 245, 205, 134, 18}
+	spsig1     = []byte{13, 115, 101, 19, 63}
+	p2sig      = []byte{54, 240, 44, 52}
+	genericSig = []byte{4, 130, 43}
+)
+
+// Signer signs messages for an account, such as a Wallet or a remote signer. Messages start with a watermark byte
+// telling what is signed, 3 for operations, and Sign returns the base58 signature of their blake2b hash.
+type Signer interface {
+	PublicKeyHash() string
+	Sign(message []byte) (string, error)
+}
+
+// PublicKeyHash returns the address of the wallet
+func (w Wallet) PublicKeyHash() string {
+	return w.Address
+}
+
+// Sign signs the blake2b hash of a message with the secret key of the wallet, and returns an edsig signature
+func (w Wallet) Sign(message []byte) (string, error) {
+	if len(w.Kp.PrivKey) != ed25519.PrivateKeySize {
+		return "", errors.Errorf("could not sign message, wallet %s has no valid ed25519 secret key", w.Address)
+	}
+
+	hash := blake2b.Sum256(message)
+	return b58cencode(ed25519.Sign(w.Kp.PrivKey, hash[:]), edsig), nil
+}
+
+// signatureBytes decodes a base58 signature into its 64 bytes
+func signatureBytes(signature string) ([]byte, error) {
+	decoded, err := base58check.Decode(signature)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode signature '%s'", signature)
+	}
+
+	for _, prefix := range [][]byte{edsig, spsig1, p2sig, genericSig} {
+		if len(decoded) == len(prefix)+64 && string(decoded[:len(prefix)]) == string(prefix) {
+			return decoded[len(prefix):], nil
+		}
+	}
+	return nil, errors.Errorf("could not decode signature '%s', unknown prefix", signature)
+}
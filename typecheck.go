@@ -0,0 +1,1142 @@
+package gotezos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of the errors found by the typechecker, named after the errors of the node
+const (
+	TypeErrorInvalidType       = "invalid_type"
+	TypeErrorInvalidData       = "invalid_constant"
+	TypeErrorComparable        = "comparable_type_expected"
+	TypeErrorUnknownPrimitive  = "unknown_primitive"
+	TypeErrorInvalidArity      = "invalid_arity"
+	TypeErrorBadStack          = "bad_stack"
+	TypeErrorUnmatchedBranches = "unmatched_branches"
+	TypeErrorFailNotInTail     = "fail_not_in_tail_position"
+	TypeErrorSelfInLambda      = "self_in_lambda"
+	TypeErrorInvalidScript     = "invalid_script"
+)
+
+// TypeError is an error found by the typechecker at a node of the checked expression. Like the node does, locations
+// number the nodes of the expression in prefix order from 0, not counting annotations.
+type TypeError struct {
+	Location int
+	Kind     string
+	Message  string
+}
+
+// Error returns the message of the error with its location
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("%s at location %d: %s", e.Kind, e.Location, e.Message)
+}
+
+// The number of arguments of each type
+var typeArities = map[string]int{
+	"int": 0, "nat": 0, "string": 0, "bytes": 0, "mutez": 0, "bool": 0, "key_hash": 0, "timestamp": 0,
+	"address": 0, "key": 0, "unit": 0, "signature": 0, "operation": 0, "chain_id": 0,
+	"option": 1, "list": 1, "set": 1, "contract": 1,
+	"pair": 2, "or": 2, "lambda": 2, "map": 2, "big_map": 2,
+}
+
+// The types of the simple comparable values
+var comparableTypes = map[string]bool{
+	"int": true, "nat": true, "string": true, "bytes": true, "mutez": true, "bool": true, "key_hash": true,
+	"timestamp": true, "address": true,
+}
+
+// The numbers of arguments each instruction accepts
+var instructionArities = map[string][]int{
+	"DROP": {0, 1}, "DUP": {0}, "SWAP": {0}, "DIG": {1}, "DUG": {1}, "PUSH": {2}, "SOME": {0}, "NONE": {1},
+	"UNIT": {0}, "IF_NONE": {2}, "PAIR": {0}, "CAR": {0}, "CDR": {0}, "LEFT": {1}, "RIGHT": {1}, "IF_LEFT": {2},
+	"NIL": {1}, "CONS": {0}, "IF_CONS": {2}, "SIZE": {0}, "EMPTY_SET": {1}, "EMPTY_MAP": {2},
+	"EMPTY_BIG_MAP": {2}, "MAP": {1}, "ITER": {1}, "MEM": {0}, "GET": {0}, "UPDATE": {0}, "IF": {2},
+	"LOOP": {1}, "LOOP_LEFT": {1}, "LAMBDA": {3}, "EXEC": {0}, "APPLY": {0}, "DIP": {1, 2}, "FAILWITH": {0},
+	"CAST": {1}, "RENAME": {0}, "CONCAT": {0}, "SLICE": {0}, "PACK": {0}, "UNPACK": {1}, "ADD": {0},
+	"SUB": {0}, "MUL": {0}, "EDIV": {0}, "ABS": {0}, "ISNAT": {0}, "INT": {0}, "NEG": {0}, "LSL": {0},
+	"LSR": {0}, "OR": {0}, "AND": {0}, "XOR": {0}, "NOT": {0}, "COMPARE": {0}, "EQ": {0}, "NEQ": {0},
+	"LT": {0}, "GT": {0}, "LE": {0}, "GE": {0}, "SELF": {0}, "CONTRACT": {1}, "TRANSFER_TOKENS": {0},
+	"SET_DELEGATE": {0}, "CREATE_CONTRACT": {1}, "IMPLICIT_ACCOUNT": {0}, "NOW": {0}, "AMOUNT": {0},
+	"BALANCE": {0}, "CHECK_SIGNATURE": {0}, "BLAKE2B": {0}, "SHA256": {0}, "SHA512": {0}, "HASH_KEY": {0},
+	"STEPS_TO_QUOTA": {0}, "SOURCE": {0}, "SENDER": {0}, "ADDRESS": {0}, "CHAIN_ID": {0},
+}
+
+// The result types of the instructions operating on simple types, by the types of their operands
+var operatorTypes = map[string]map[string]string{
+	"ADD": {
+		"nat nat": "nat", "nat int": "int", "int nat": "int", "int int": "int",
+		"timestamp int": "timestamp", "int timestamp": "timestamp", "mutez mutez": "mutez",
+	},
+	"SUB": {
+		"nat nat": "int", "nat int": "int", "int nat": "int", "int int": "int",
+		"timestamp int": "timestamp", "timestamp timestamp": "int", "mutez mutez": "mutez",
+	},
+	"MUL": {
+		"nat nat": "nat", "nat int": "int", "int nat": "int", "int int": "int", "mutez nat": "mutez",
+		"nat mutez": "mutez",
+	},
+	"EDIV": {
+		"nat nat": "option (pair nat nat)", "nat int": "option (pair int nat)", "int nat": "option (pair int nat)",
+		"int int": "option (pair int nat)", "mutez nat": "option (pair mutez mutez)",
+		"mutez mutez": "option (pair nat mutez)",
+	},
+	"ABS":              {"int": "nat"},
+	"ISNAT":            {"int": "option nat"},
+	"INT":              {"nat": "int"},
+	"NEG":              {"nat": "int", "int": "int"},
+	"LSL":              {"nat nat": "nat"},
+	"LSR":              {"nat nat": "nat"},
+	"OR":               {"bool bool": "bool", "nat nat": "nat"},
+	"AND":              {"bool bool": "bool", "nat nat": "nat", "int nat": "nat"},
+	"XOR":              {"bool bool": "bool", "nat nat": "nat"},
+	"NOT":              {"bool": "bool", "nat": "int", "int": "int"},
+	"EQ":               {"int": "bool"},
+	"NEQ":              {"int": "bool"},
+	"LT":               {"int": "bool"},
+	"GT":               {"int": "bool"},
+	"LE":               {"int": "bool"},
+	"GE":               {"int": "bool"},
+	"BLAKE2B":          {"bytes": "bytes"},
+	"SHA256":           {"bytes": "bytes"},
+	"SHA512":           {"bytes": "bytes"},
+	"HASH_KEY":         {"key": "key_hash"},
+	"CHECK_SIGNATURE":  {"key signature bytes": "bool"},
+	"IMPLICIT_ACCOUNT": {"key_hash": "contract unit"},
+}
+
+// The types pushed by the instructions reading the context of the execution
+var contextTypes = map[string]string{
+	"UNIT": "unit", "NOW": "timestamp", "AMOUNT": "mutez", "BALANCE": "mutez", "STEPS_TO_QUOTA": "nat",
+	"SOURCE": "address", "SENDER": "address", "CHAIN_ID": "chain_id",
+}
+
+// TypecheckType checks that an expression is a well formed Michelson type
+func TypecheckType(typ Micheline) error {
+	return checkType(typ, 0)
+}
+
+// TypecheckData checks that a value has a Michelson type, typechecking the code of its lambdas. Errors in the value
+// are located in the value, but the type is checked first and its errors are located in the type. Big maps may be
+// given by their id, as in the storage of a contract.
+func TypecheckData(value, typ Micheline) error {
+	if err := checkType(typ, 0); err != nil {
+		return err
+	}
+	return (&typechecker{}).data(value, typ, 0)
+}
+
+// TypecheckScript checks the types of the parameter and storage of a script, and that its code turns a stack
+// holding the pair of the parameter and storage into a stack holding the pair of a list of operations and the
+// storage. Macros must be expanded.
+func TypecheckScript(code Micheline) error {
+	_, _, err := checkScript(code, 0)
+	return err
+}
+
+// TypecheckLambda checks that code turns a stack holding a value of type arg into a stack holding a value of type
+// ret, like the code of a lambda
+func TypecheckLambda(code, arg, ret Micheline) error {
+	return (&typechecker{}).lambda(code, arg, ret, 0)
+}
+
+// Typecheck typechecks the code of the script and its storage
+func (s Script) Typecheck() error {
+	if err := TypecheckScript(s.Code); err != nil {
+		return err
+	}
+
+	storage, err := s.StorageType()
+	if err != nil {
+		return err
+	}
+	return TypecheckData(s.Storage, storage)
+}
+
+// TypecheckParameter typechecks a parameter of an entrypoint of the script, the whole parameter type for the
+// default entrypoint unless one is annotated %default
+func (s Script) TypecheckParameter(entrypoint string, value Micheline) error {
+	if entrypoint == "" {
+		entrypoint = "default"
+	}
+
+	entrypoints, err := s.Entrypoints()
+	if err != nil {
+		return err
+	}
+	typ, ok := entrypoints[entrypoint]
+	if !ok && entrypoint == "default" {
+		typ, err = s.ParameterType()
+		if err != nil {
+			return err
+		}
+		ok = true
+	}
+	if !ok {
+		return &TypeError{Kind: TypeErrorInvalidScript, Message: fmt.Sprintf("script has no entrypoint %s", entrypoint)}
+	}
+
+	return TypecheckData(value, typ)
+}
+
+// typechecker checks the instructions of a script, or of a lambda when parameter is nil
+type typechecker struct {
+	parameter *Micheline
+}
+
+// checkScript checks a script at loc and returns its parameter and storage types
+func checkScript(code Micheline, loc int) (Micheline, Micheline, error) {
+	if code.Kind != MichelineSeq {
+		return Micheline{}, Micheline{}, typeError(loc, TypeErrorInvalidScript, "expected a sequence of the parameter, storage and code sections, got %s", code.Michelson())
+	}
+
+	sections := make(map[string]Micheline)
+	locations := make(map[string]int)
+	for k, childLoc := range childLocations(code, loc) {
+		section := code.Seq[k]
+		if section.Kind != MichelinePrim || (section.Prim != "parameter" && section.Prim != "storage" && section.Prim != "code") {
+			return Micheline{}, Micheline{}, typeError(childLoc, TypeErrorInvalidScript, "unexpected section %s", section.Michelson())
+		}
+		if _, ok := sections[section.Prim]; ok {
+			return Micheline{}, Micheline{}, typeError(childLoc, TypeErrorInvalidScript, "duplicate %s section", section.Prim)
+		}
+		if len(section.Args) != 1 {
+			return Micheline{}, Micheline{}, typeError(childLoc, TypeErrorInvalidArity, "%s section expects 1 argument, got %d", section.Prim, len(section.Args))
+		}
+		sections[section.Prim] = section.Args[0]
+		locations[section.Prim] = childLoc + 1
+	}
+	for _, name := range []string{"parameter", "storage", "code"} {
+		if _, ok := sections[name]; !ok {
+			return Micheline{}, Micheline{}, typeError(loc, TypeErrorInvalidScript, "missing %s section", name)
+		}
+	}
+
+	parameter, storage := sections["parameter"], sections["storage"]
+	if err := checkType(parameter, locations["parameter"]); err != nil {
+		return parameter, storage, err
+	}
+	if err := checkType(storage, locations["storage"]); err != nil {
+		return parameter, storage, err
+	}
+	if forbidden := findType(parameter, "operation", "big_map"); forbidden != "" {
+		return parameter, storage, typeError(locations["parameter"], TypeErrorInvalidType, "type %s is not allowed in the parameter", forbidden)
+	}
+	if forbidden := findType(storage, "operation", "contract"); forbidden != "" {
+		return parameter, storage, typeError(locations["storage"], TypeErrorInvalidType, "type %s is not allowed in the storage", forbidden)
+	}
+
+	c := &typechecker{parameter: &parameter}
+	input := newType("pair", parameter, storage)
+	output := newType("pair", newType("list", newType("operation")), storage)
+	stack, failed, err := c.seq(sections["code"], locations["code"], []Micheline{input})
+	if err != nil {
+		return parameter, storage, err
+	}
+	if !failed && (len(stack) != 1 || !sameMichelineType(stack[0], output)) {
+		return parameter, storage, typeError(locations["code"], TypeErrorBadStack, "code ends with stack %s instead of %s", stackString(stack), output.Michelson())
+	}
+
+	return parameter, storage, nil
+}
+
+// checkType checks a type at loc
+func checkType(typ Micheline, loc int) error {
+	if typ.Kind != MichelinePrim {
+		return typeError(loc, TypeErrorInvalidType, "expected a type, got %s", typ.Michelson())
+	}
+	arity, ok := typeArities[typ.Prim]
+	if !ok {
+		return typeError(loc, TypeErrorUnknownPrimitive, "unknown type %s", typ.Prim)
+	}
+	if len(typ.Args) != arity {
+		return typeError(loc, TypeErrorInvalidArity, "type %s expects %d arguments, got %d", typ.Prim, arity, len(typ.Args))
+	}
+
+	locs := childLocations(typ, loc)
+	for k, arg := range typ.Args {
+		if err := checkType(arg, locs[k]); err != nil {
+			return err
+		}
+	}
+
+	switch typ.Prim {
+	case "set", "map", "big_map":
+		if !isComparable(typ.Args[0]) {
+			return typeError(locs[0], TypeErrorComparable, "%s of %s expects a comparable key type", typ.Prim, typ.Args[0].Michelson())
+		}
+	}
+
+	return nil
+}
+
+// isComparable returns whether values of a type can be compared
+func isComparable(typ Micheline) bool {
+	if typ.Kind != MichelinePrim {
+		return false
+	}
+	if typ.Prim == "pair" && len(typ.Args) == 2 {
+		return comparableTypes[typ.Args[0].Prim] && isComparable(typ.Args[1])
+	}
+	return comparableTypes[typ.Prim] && len(typ.Args) == 0
+}
+
+// data checks a value of a type at loc
+func (c *typechecker) data(value, typ Micheline, loc int) error {
+	invalid := func() error {
+		return typeError(loc, TypeErrorInvalidData, "expected a value of type %s, got %s", typ.Michelson(), value.Michelson())
+	}
+	isPrim := func(prim string, args int) bool {
+		return value.Kind == MichelinePrim && value.Prim == prim && len(value.Args) == args
+	}
+	locs := childLocations(value, loc)
+
+	switch typ.Prim {
+	case "int":
+		if value.Kind != MichelineInt {
+			return invalid()
+		}
+	case "nat":
+		if value.Kind != MichelineInt || value.Int.Sign() < 0 {
+			return invalid()
+		}
+	case "mutez":
+		if value.Kind != MichelineInt || value.Int.Sign() < 0 || !value.Int.IsInt64() {
+			return invalid()
+		}
+	case "string":
+		if value.Kind != MichelineString {
+			return invalid()
+		}
+	case "bytes":
+		if value.Kind != MichelineBytes {
+			return invalid()
+		}
+	case "bool":
+		if !isPrim("True", 0) && !isPrim("False", 0) {
+			return invalid()
+		}
+	case "unit":
+		if !isPrim("Unit", 0) {
+			return invalid()
+		}
+	case "timestamp":
+		if value.Kind == MichelineString {
+			if _, err := time.Parse(time.RFC3339, value.String); err != nil {
+				return invalid()
+			}
+		} else if value.Kind != MichelineInt {
+			return invalid()
+		}
+	case "key_hash", "address", "contract", "key", "signature", "chain_id":
+		if value.Kind != MichelineString && value.Kind != MichelineBytes {
+			return invalid()
+		}
+		// The readable form is checked by optimizing it, and the optimized form by making it readable
+		convert := optimizeData
+		if value.Kind == MichelineBytes {
+			convert = readableData
+		}
+		if _, err := convert(value, typ.Prim); err != nil {
+			return invalid()
+		}
+
+	case "option":
+		switch {
+		case isPrim("None", 0):
+		case isPrim("Some", 1):
+			return c.data(value.Args[0], typ.Args[0], locs[0])
+		default:
+			return invalid()
+		}
+	case "pair":
+		if !isPrim("Pair", 2) {
+			return invalid()
+		}
+		if err := c.data(value.Args[0], typ.Args[0], locs[0]); err != nil {
+			return err
+		}
+		return c.data(value.Args[1], typ.Args[1], locs[1])
+	case "or":
+		switch {
+		case isPrim("Left", 1):
+			return c.data(value.Args[0], typ.Args[0], locs[0])
+		case isPrim("Right", 1):
+			return c.data(value.Args[0], typ.Args[1], locs[0])
+		default:
+			return invalid()
+		}
+
+	case "list", "set":
+		if value.Kind != MichelineSeq {
+			return invalid()
+		}
+		for k, item := range value.Seq {
+			if err := c.data(item, typ.Args[0], locs[k]); err != nil {
+				return err
+			}
+			if typ.Prim == "set" && k > 0 {
+				if order, ok := compareData(value.Seq[k-1], item, typ.Args[0]); ok && order >= 0 {
+					return typeError(locs[k], TypeErrorInvalidData, "set elements must be in strictly increasing order")
+				}
+			}
+		}
+
+	case "map", "big_map":
+		if typ.Prim == "big_map" && value.Kind == MichelineInt {
+			return nil
+		}
+		if value.Kind != MichelineSeq {
+			return invalid()
+		}
+		for k, elt := range value.Seq {
+			if elt.Kind != MichelinePrim || elt.Prim != "Elt" || len(elt.Args) != 2 {
+				return typeError(locs[k], TypeErrorInvalidData, "expected an Elt of %s, got %s", typ.Michelson(), elt.Michelson())
+			}
+			eltLocs := childLocations(elt, locs[k])
+			if err := c.data(elt.Args[0], typ.Args[0], eltLocs[0]); err != nil {
+				return err
+			}
+			if err := c.data(elt.Args[1], typ.Args[1], eltLocs[1]); err != nil {
+				return err
+			}
+			if k > 0 {
+				if order, ok := compareData(value.Seq[k-1].Args[0], elt.Args[0], typ.Args[0]); ok && order >= 0 {
+					return typeError(locs[k], TypeErrorInvalidData, "map keys must be in strictly increasing order")
+				}
+			}
+		}
+
+	case "lambda":
+		if value.Kind != MichelineSeq {
+			return invalid()
+		}
+		return (&typechecker{}).lambda(value, typ.Args[0], typ.Args[1], loc)
+
+	default:
+		return typeError(loc, TypeErrorInvalidData, "values of type %s can not be written", typ.Prim)
+	}
+
+	return nil
+}
+
+// compareData compares two values of a comparable type, and reports false for the types whose readable values do
+// not compare like the values
+func compareData(a, b, typ Micheline) (int, bool) {
+	switch typ.Prim {
+	case "int", "nat", "mutez":
+		if a.Kind == MichelineInt && b.Kind == MichelineInt {
+			return a.Int.Cmp(b.Int), true
+		}
+	case "string":
+		if a.Kind == MichelineString && b.Kind == MichelineString {
+			return strings.Compare(a.String, b.String), true
+		}
+	case "bytes":
+		if a.Kind == MichelineBytes && b.Kind == MichelineBytes {
+			return strings.Compare(string(a.Bytes), string(b.Bytes)), true
+		}
+	case "bool":
+		if a.Kind == MichelinePrim && b.Kind == MichelinePrim {
+			return strings.Compare(a.Prim, b.Prim), true
+		}
+	case "pair":
+		if len(a.Args) == 2 && len(b.Args) == 2 {
+			order, ok := compareData(a.Args[0], b.Args[0], typ.Args[0])
+			if !ok || order != 0 {
+				return order, ok
+			}
+			return compareData(a.Args[1], b.Args[1], typ.Args[1])
+		}
+	}
+	return 0, false
+}
+
+// lambda checks the code of a lambda from arg to ret at loc
+func (c *typechecker) lambda(code, arg, ret Micheline, loc int) error {
+	stack, failed, err := c.seq(code, loc, []Micheline{arg})
+	if err != nil {
+		return err
+	}
+	if !failed && (len(stack) != 1 || !sameMichelineType(stack[0], ret)) {
+		return typeError(loc, TypeErrorBadStack, "lambda ends with stack %s instead of %s", stackString(stack), ret.Michelson())
+	}
+	return nil
+}
+
+// seq checks a sequence of instructions at loc on a stack, and returns the resulting stack or whether it fails
+func (c *typechecker) seq(code Micheline, loc int, stack []Micheline) ([]Micheline, bool, error) {
+	if code.Kind != MichelineSeq {
+		return nil, false, typeError(loc, TypeErrorInvalidData, "expected a sequence of instructions, got %s", code.Michelson())
+	}
+
+	locs := childLocations(code, loc)
+	for k, instr := range code.Seq {
+		var failed bool
+		var err error
+		stack, failed, err = c.instr(instr, locs[k], stack)
+		if err != nil {
+			return nil, false, err
+		}
+		if failed {
+			if k != len(code.Seq)-1 {
+				return nil, false, typeError(locs[k+1], TypeErrorFailNotInTail, "instruction after a failing instruction")
+			}
+			return nil, true, nil
+		}
+	}
+
+	return stack, false, nil
+}
+
+// instr checks an instruction at loc on a stack, and returns the resulting stack or whether it fails
+func (c *typechecker) instr(instr Micheline, loc int, stack []Micheline) ([]Micheline, bool, error) {
+	if instr.Kind == MichelineSeq {
+		return c.seq(instr, loc, stack)
+	}
+	if instr.Kind != MichelinePrim {
+		return nil, false, typeError(loc, TypeErrorUnknownPrimitive, "expected an instruction, got %s", instr.Michelson())
+	}
+
+	arities, ok := instructionArities[instr.Prim]
+	if !ok {
+		return nil, false, typeError(loc, TypeErrorUnknownPrimitive, "unknown instruction %s", instr.Prim)
+	}
+	arityOk := false
+	for _, arity := range arities {
+		arityOk = arityOk || arity == len(instr.Args)
+	}
+	if !arityOk {
+		return nil, false, typeError(loc, TypeErrorInvalidArity, "%s does not take %d arguments", instr.Prim, len(instr.Args))
+	}
+
+	locs := childLocations(instr, loc)
+	need := func(n int) error {
+		if len(stack) < n {
+			return typeError(loc, TypeErrorBadStack, "%s needs %d values on the stack, got %s", instr.Prim, n, stackString(stack))
+		}
+		return nil
+	}
+	badStack := func() error {
+		return typeError(loc, TypeErrorBadStack, "%s can not be applied to the stack %s", instr.Prim, stackString(stack))
+	}
+	push := func(n int, types ...Micheline) []Micheline {
+		return append(types, stack[n:]...)
+	}
+	count := func() (int, error) {
+		if len(instr.Args) == 0 {
+			return 1, nil
+		}
+		n := instr.Args[0]
+		if n.Kind != MichelineInt || n.Int.Sign() < 0 || !n.Int.IsInt64() || n.Int.Int64() > 1023 {
+			return 0, typeError(locs[0], TypeErrorInvalidData, "%s expects a natural number below 1024, got %s", instr.Prim, n.Michelson())
+		}
+		return int(n.Int.Int64()), nil
+	}
+	typeArg := func(k int) (Micheline, error) {
+		return instr.Args[k], checkType(instr.Args[k], locs[k])
+	}
+
+	if results, ok := operatorTypes[instr.Prim]; ok {
+		var n int
+		for operands := range results {
+			n = len(strings.Fields(operands))
+			break
+		}
+		if err := need(n); err != nil {
+			return nil, false, err
+		}
+		operands := make([]string, n)
+		for k := range operands {
+			operands[k] = stack[k].Prim
+			if len(stack[k].Args) > 0 {
+				return nil, false, badStack()
+			}
+		}
+		result, ok := results[strings.Join(operands, " ")]
+		if !ok {
+			return nil, false, badStack()
+		}
+		typ, err := ParseMichelson(result)
+		if err != nil {
+			return nil, false, err
+		}
+		return push(n, typ), false, nil
+	}
+	if result, ok := contextTypes[instr.Prim]; ok {
+		return push(0, newType(result)), false, nil
+	}
+
+	switch instr.Prim {
+	case "DROP":
+		n, err := count()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(n); err != nil {
+			return nil, false, err
+		}
+		return push(n), false, nil
+
+	case "DUP":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		return push(0, stack[0]), false, nil
+
+	case "SWAP":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		return push(2, stack[1], stack[0]), false, nil
+
+	case "DIG", "DUG":
+		n, err := count()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(n + 1); err != nil {
+			return nil, false, err
+		}
+		moved := append([]Micheline{}, stack[:n+1]...)
+		if instr.Prim == "DIG" {
+			moved = append([]Micheline{moved[n]}, moved[:n]...)
+		} else {
+			moved = append(moved[1:], moved[0])
+		}
+		return push(n+1, moved...), false, nil
+
+	case "PUSH":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		if forbidden := findType(typ, "operation", "big_map", "contract"); forbidden != "" {
+			return nil, false, typeError(locs[0], TypeErrorInvalidType, "values of type %s can not be pushed", forbidden)
+		}
+		if err := c.data(instr.Args[1], typ, locs[1]); err != nil {
+			return nil, false, err
+		}
+		return push(0, typ), false, nil
+
+	case "SOME":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		return push(1, newType("option", stack[0])), false, nil
+
+	case "NONE":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		return push(0, newType("option", typ)), false, nil
+
+	case "PAIR":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		return push(2, newType("pair", stack[0], stack[1])), false, nil
+
+	case "CAR", "CDR":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if stack[0].Prim != "pair" {
+			return nil, false, badStack()
+		}
+		if instr.Prim == "CAR" {
+			return push(1, stack[0].Args[0]), false, nil
+		}
+		return push(1, stack[0].Args[1]), false, nil
+
+	case "LEFT", "RIGHT":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if instr.Prim == "LEFT" {
+			return push(1, newType("or", stack[0], typ)), false, nil
+		}
+		return push(1, newType("or", typ, stack[0])), false, nil
+
+	case "NIL":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		return push(0, newType("list", typ)), false, nil
+
+	case "CONS":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		if stack[1].Prim != "list" || !sameMichelineType(stack[0], stack[1].Args[0]) {
+			return nil, false, badStack()
+		}
+		return push(2, stack[1]), false, nil
+
+	case "EMPTY_SET":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		set := newType("set", typ)
+		if err := checkType(set, loc); err != nil {
+			return nil, false, err
+		}
+		return push(0, set), false, nil
+
+	case "EMPTY_MAP", "EMPTY_BIG_MAP":
+		key, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		value, err := typeArg(1)
+		if err != nil {
+			return nil, false, err
+		}
+		m := newType(strings.ToLower(strings.TrimPrefix(instr.Prim, "EMPTY_")), key, value)
+		if err := checkType(m, loc); err != nil {
+			return nil, false, err
+		}
+		return push(0, m), false, nil
+
+	case "SIZE":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		switch stack[0].Prim {
+		case "set", "map", "list", "string", "bytes":
+			return push(1, newType("nat")), false, nil
+		}
+		return nil, false, badStack()
+
+	case "MEM", "GET":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		collection := stack[1]
+		switch {
+		case instr.Prim == "MEM" && collection.Prim == "set" && sameMichelineType(stack[0], collection.Args[0]):
+			return push(2, newType("bool")), false, nil
+		case (collection.Prim == "map" || collection.Prim == "big_map") && sameMichelineType(stack[0], collection.Args[0]):
+			if instr.Prim == "MEM" {
+				return push(2, newType("bool")), false, nil
+			}
+			return push(2, newType("option", collection.Args[1])), false, nil
+		}
+		return nil, false, badStack()
+
+	case "UPDATE":
+		if err := need(3); err != nil {
+			return nil, false, err
+		}
+		collection := stack[2]
+		switch {
+		case collection.Prim == "set" && sameMichelineType(stack[0], collection.Args[0]) && stack[1].Prim == "bool":
+			return push(3, collection), false, nil
+		case (collection.Prim == "map" || collection.Prim == "big_map") && sameMichelineType(stack[0], collection.Args[0]) &&
+			sameMichelineType(stack[1], newType("option", collection.Args[1])):
+			return push(3, collection), false, nil
+		}
+		return nil, false, badStack()
+
+	case "IF", "IF_NONE", "IF_LEFT", "IF_CONS":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		var whenTrue, whenFalse []Micheline
+		top, rest := stack[0], stack[1:]
+		switch {
+		case instr.Prim == "IF" && top.Prim == "bool":
+			whenTrue, whenFalse = rest, rest
+		case instr.Prim == "IF_NONE" && top.Prim == "option":
+			whenTrue, whenFalse = rest, append([]Micheline{top.Args[0]}, rest...)
+		case instr.Prim == "IF_LEFT" && top.Prim == "or":
+			whenTrue, whenFalse = append([]Micheline{top.Args[0]}, rest...), append([]Micheline{top.Args[1]}, rest...)
+		case instr.Prim == "IF_CONS" && top.Prim == "list":
+			whenTrue, whenFalse = append([]Micheline{top.Args[0], top}, rest...), rest
+		default:
+			return nil, false, badStack()
+		}
+		return c.branches(instr, locs, whenTrue, whenFalse)
+
+	case "LOOP", "LOOP_LEFT":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		top, rest := stack[0], stack[1:]
+		var body, after []Micheline
+		switch {
+		case instr.Prim == "LOOP" && top.Prim == "bool":
+			body, after = rest, rest
+		case instr.Prim == "LOOP_LEFT" && top.Prim == "or":
+			body, after = append([]Micheline{top.Args[0]}, rest...), append([]Micheline{top.Args[1]}, rest...)
+		default:
+			return nil, false, badStack()
+		}
+		result, failed, err := c.seq(instr.Args[0], locs[0], body)
+		if err != nil {
+			return nil, false, err
+		}
+		if !failed && !sameStack(result, stack) {
+			return nil, false, typeError(locs[0], TypeErrorBadStack, "%s body ends with stack %s instead of %s", instr.Prim, stackString(result), stackString(stack))
+		}
+		return after, false, nil
+
+	case "MAP", "ITER":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		top, rest := stack[0], stack[1:]
+		var elt Micheline
+		switch top.Prim {
+		case "list":
+			elt = top.Args[0]
+		case "set":
+			if instr.Prim == "MAP" {
+				return nil, false, badStack()
+			}
+			elt = top.Args[0]
+		case "map":
+			elt = newType("pair", top.Args[0], top.Args[1])
+		default:
+			return nil, false, badStack()
+		}
+		result, failed, err := c.seq(instr.Args[0], locs[0], append([]Micheline{elt}, rest...))
+		if err != nil {
+			return nil, false, err
+		}
+		if instr.Prim == "ITER" {
+			if !failed && !sameStack(result, rest) {
+				return nil, false, typeError(locs[0], TypeErrorBadStack, "ITER body ends with stack %s instead of %s", stackString(result), stackString(rest))
+			}
+			return rest, false, nil
+		}
+		if failed {
+			return nil, false, typeError(locs[0], TypeErrorBadStack, "MAP body always fails")
+		}
+		if len(result) == 0 || !sameStack(result[1:], rest) {
+			return nil, false, typeError(locs[0], TypeErrorBadStack, "MAP body ends with stack %s instead of a value on %s", stackString(result), stackString(rest))
+		}
+		if top.Prim == "list" {
+			return append([]Micheline{newType("list", result[0])}, rest...), false, nil
+		}
+		return append([]Micheline{newType("map", top.Args[0], result[0])}, rest...), false, nil
+
+	case "LAMBDA":
+		arg, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		ret, err := typeArg(1)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := (&typechecker{}).lambda(instr.Args[2], arg, ret, locs[2]); err != nil {
+			return nil, false, err
+		}
+		return push(0, newType("lambda", arg, ret)), false, nil
+
+	case "EXEC":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		if stack[1].Prim != "lambda" || !sameMichelineType(stack[0], stack[1].Args[0]) {
+			return nil, false, badStack()
+		}
+		return push(2, stack[1].Args[1]), false, nil
+
+	case "APPLY":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		lambda := stack[1]
+		if lambda.Prim != "lambda" || lambda.Args[0].Prim != "pair" || !sameMichelineType(stack[0], lambda.Args[0].Args[0]) {
+			return nil, false, badStack()
+		}
+		return push(2, newType("lambda", lambda.Args[0].Args[1], lambda.Args[1])), false, nil
+
+	case "DIP":
+		n := 1
+		code, codeLoc := instr.Args[0], locs[0]
+		if len(instr.Args) == 2 {
+			var err error
+			if n, err = count(); err != nil {
+				return nil, false, err
+			}
+			code, codeLoc = instr.Args[1], locs[1]
+		}
+		if err := need(n); err != nil {
+			return nil, false, err
+		}
+		result, failed, err := c.seq(code, codeLoc, stack[n:])
+		if err != nil {
+			return nil, false, err
+		}
+		// The instructions after DIP run on the protected values, so its body cannot fail
+		if failed {
+			return nil, false, typeError(loc, TypeErrorFailNotInTail, "DIP body always fails")
+		}
+		return append(append([]Micheline{}, stack[:n]...), result...), false, nil
+
+	case "FAILWITH":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		return nil, true, nil
+
+	case "CAST":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if !sameMichelineType(stack[0], typ) {
+			return nil, false, badStack()
+		}
+		return push(1, typ), false, nil
+
+	case "RENAME":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		return stack, false, nil
+
+	case "CONCAT":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if stack[0].Prim == "list" && (stack[0].Args[0].Prim == "string" || stack[0].Args[0].Prim == "bytes") {
+			return push(1, stack[0].Args[0]), false, nil
+		}
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		if (stack[0].Prim == "string" || stack[0].Prim == "bytes") && stack[1].Prim == stack[0].Prim {
+			return push(2, stack[0]), false, nil
+		}
+		return nil, false, badStack()
+
+	case "SLICE":
+		if err := need(3); err != nil {
+			return nil, false, err
+		}
+		if stack[0].Prim != "nat" || stack[1].Prim != "nat" || (stack[2].Prim != "string" && stack[2].Prim != "bytes") {
+			return nil, false, badStack()
+		}
+		return push(3, newType("option", stack[2])), false, nil
+
+	case "PACK":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if forbidden := findType(stack[0], "operation", "big_map", "contract"); forbidden != "" {
+			return nil, false, badStack()
+		}
+		return push(1, newType("bytes")), false, nil
+
+	case "UNPACK":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if stack[0].Prim != "bytes" {
+			return nil, false, badStack()
+		}
+		return push(1, newType("option", typ)), false, nil
+
+	case "COMPARE":
+		if err := need(2); err != nil {
+			return nil, false, err
+		}
+		if !isComparable(stack[0]) || !sameMichelineType(stack[0], stack[1]) {
+			return nil, false, badStack()
+		}
+		return push(2, newType("int")), false, nil
+
+	case "SELF":
+		if c.parameter == nil {
+			return nil, false, typeError(loc, TypeErrorSelfInLambda, "SELF can not be used in a lambda")
+		}
+		parameter := *c.parameter
+		if entrypoint := fieldAnnot(instr); entrypoint != "" && entrypoint != "default" {
+			entrypoints, _ := Script{Code: NewMichelineSeq(NewMichelinePrim("parameter", []Micheline{parameter}))}.Entrypoints()
+			typ, ok := entrypoints[entrypoint]
+			if !ok {
+				return nil, false, typeError(loc, TypeErrorBadStack, "no entrypoint %s", entrypoint)
+			}
+			parameter = typ
+		}
+		return push(0, newType("contract", parameter)), false, nil
+
+	case "CONTRACT":
+		typ, err := typeArg(0)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if stack[0].Prim != "address" {
+			return nil, false, badStack()
+		}
+		return push(1, newType("option", newType("contract", typ))), false, nil
+
+	case "ADDRESS":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if stack[0].Prim != "contract" {
+			return nil, false, badStack()
+		}
+		return push(1, newType("address")), false, nil
+
+	case "TRANSFER_TOKENS":
+		if err := need(3); err != nil {
+			return nil, false, err
+		}
+		if stack[1].Prim != "mutez" || stack[2].Prim != "contract" || !sameMichelineType(stack[0], stack[2].Args[0]) {
+			return nil, false, badStack()
+		}
+		return push(3, newType("operation")), false, nil
+
+	case "SET_DELEGATE":
+		if err := need(1); err != nil {
+			return nil, false, err
+		}
+		if !sameMichelineType(stack[0], newType("option", newType("key_hash"))) {
+			return nil, false, badStack()
+		}
+		return push(1, newType("operation")), false, nil
+
+	case "CREATE_CONTRACT":
+		_, storage, err := checkScript(instr.Args[0], locs[0])
+		if err != nil {
+			return nil, false, err
+		}
+		if err := need(3); err != nil {
+			return nil, false, err
+		}
+		if !sameMichelineType(stack[0], newType("option", newType("key_hash"))) || stack[1].Prim != "mutez" || !sameMichelineType(stack[2], storage) {
+			return nil, false, badStack()
+		}
+		return push(3, newType("operation"), newType("address")), false, nil
+	}
+
+	return nil, false, typeError(loc, TypeErrorUnknownPrimitive, "unknown instruction %s", instr.Prim)
+}
+
+// branches checks the two branches of a conditional instruction on their stacks, and returns the stack they end
+// with or whether they both fail
+func (c *typechecker) branches(instr Micheline, locs []int, whenTrue, whenFalse []Micheline) ([]Micheline, bool, error) {
+	first, firstFailed, err := c.seq(instr.Args[0], locs[0], whenTrue)
+	if err != nil {
+		return nil, false, err
+	}
+	second, secondFailed, err := c.seq(instr.Args[1], locs[1], whenFalse)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case firstFailed && secondFailed:
+		return nil, true, nil
+	case firstFailed:
+		return second, false, nil
+	case secondFailed:
+		return first, false, nil
+	}
+	if !sameStack(first, second) {
+		return nil, false, typeError(locs[1], TypeErrorUnmatchedBranches, "branches of %s end with stacks %s and %s", instr.Prim, stackString(first), stackString(second))
+	}
+	return first, false, nil
+}
+
+// findType returns the first of prims a type is made of, or an empty string
+func findType(typ Micheline, prims ...string) string {
+	for _, prim := range prims {
+		if typ.Prim == prim {
+			return prim
+		}
+	}
+	if typ.Prim == "lambda" {
+		return ""
+	}
+	for _, arg := range typ.Args {
+		if found := findType(arg, prims...); found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// sameStack returns whether two stacks hold the same types
+func sameStack(a, b []Micheline) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !sameMichelineType(a[k], b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// stackString returns a stack of types as text, top first
+func stackString(stack []Micheline) string {
+	types := make([]string, len(stack))
+	for k, typ := range stack {
+		types[k] = typ.compact(false)
+	}
+	return "[" + strings.Join(types, " : ") + "]"
+}
+
+// newType returns a type without annotations
+func newType(prim string, args ...Micheline) Micheline {
+	return NewMichelinePrim(prim, args)
+}
+
+// childLocations returns the locations of the arguments of a primitive, or the items of a sequence, at loc
+func childLocations(node Micheline, loc int) []int {
+	children := node.Args
+	if node.Kind == MichelineSeq {
+		children = node.Seq
+	}
+
+	locs := make([]int, len(children))
+	next := loc + 1
+	for k, child := range children {
+		locs[k] = next
+		next += michelineSize(child)
+	}
+	return locs
+}
+
+// michelineSize returns the number of nodes of an expression
+func michelineSize(node Micheline) int {
+	size := 1
+	for _, arg := range node.Args {
+		size += michelineSize(arg)
+	}
+	for _, item := range node.Seq {
+		size += michelineSize(item)
+	}
+	return size
+}
+
+// typeError returns a TypeError at loc
+func typeError(loc int, kind string, format string, args ...interface{}) *TypeError {
+	return &TypeError{Location: loc, Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
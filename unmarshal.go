@@ -0,0 +1,362 @@
+package gotezos
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	michelineReflectType = reflect.TypeOf(Micheline{})
+	bigIntReflectType    = reflect.TypeOf(big.Int{})
+	timeReflectType      = reflect.TypeOf(time.Time{})
+	bigMapReflectType    = reflect.TypeOf(BigMap{})
+)
+
+// UnmarshalMicheline decodes a value of a Michelson type into the Go value pointed to by v.
+//
+// Pairs and ors decode into structs. The pairs nested in a pair without a field annotation are flattened, and
+// each leaf is decoded into the struct field whose `michelson` tag, or else whose name ignoring case and
+// underscores, matches its field annotation. Leaves without an annotation go to the fields without a tag that
+// are left, in the order of the leaves of the type. An or decodes its branch into the field matching the branch
+// the same way, every branch of the type having its own field whichever is taken, so fields for ors are usually
+// pointers. Leaves matching no field are skipped, and a `michelson:"-"` tag skips a field.
+//
+// Options decode into pointers, nil for None. Ints, nats and mutez decode into Go integers, Mutez or big.Int,
+// strings, addresses, keys, key hashes, signatures and chain ids into strings, bytes into []byte, bools into
+// bool, timestamps into time.Time, lists and sets into slices, maps into Go maps, and big maps into their id
+// or a BigMap. Any value can be decoded into a Micheline, such as lambdas.
+func UnmarshalMicheline(value, typ Micheline, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("could not unmarshal micheline into non pointer %T", v)
+	}
+
+	readable, err := convertData(value, typ, readableData)
+	if err != nil {
+		return errors.Wrap(err, "could not unmarshal micheline")
+	}
+
+	err = unmarshalMicheline(readable, typ, rv.Elem())
+	if err != nil {
+		return errors.Wrap(err, "could not unmarshal micheline")
+	}
+
+	return nil
+}
+
+// unmarshalMicheline decodes a readable value of a Michelson type into out
+func unmarshalMicheline(value, typ Micheline, out reflect.Value) error {
+	if out.Type() == michelineReflectType {
+		out.Set(reflect.ValueOf(value))
+		return nil
+	}
+	if out.Kind() == reflect.Interface && out.NumMethod() == 0 {
+		out.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	mismatch := func() error {
+		return errors.Errorf("can not decode %s of type %s into %s", value.Michelson(), typ.Michelson(), out.Type())
+	}
+
+	if typ.Prim == "option" && len(typ.Args) == 1 {
+		switch {
+		case value.Kind == MichelinePrim && value.Prim == "None":
+			out.Set(reflect.Zero(out.Type()))
+			return nil
+		case value.Kind == MichelinePrim && value.Prim == "Some" && len(value.Args) == 1:
+			value, typ = value.Args[0], typ.Args[0]
+		default:
+			return mismatch()
+		}
+	}
+
+	if out.Kind() == reflect.Ptr {
+		elem := reflect.New(out.Type().Elem())
+		if err := unmarshalMicheline(value, typ, elem.Elem()); err != nil {
+			return err
+		}
+		out.Set(elem)
+		return nil
+	}
+
+	switch typ.Prim {
+	case "int", "nat", "mutez":
+		if value.Kind != MichelineInt {
+			return mismatch()
+		}
+		return setMichelineInt(value.Int, out, mismatch)
+
+	case "string", "address", "contract", "key", "key_hash", "signature", "chain_id":
+		if value.Kind != MichelineString || out.Kind() != reflect.String {
+			return mismatch()
+		}
+		out.SetString(value.String)
+		return nil
+
+	case "bytes":
+		if value.Kind != MichelineBytes || out.Kind() != reflect.Slice || out.Type().Elem().Kind() != reflect.Uint8 {
+			return mismatch()
+		}
+		out.SetBytes(append([]byte{}, value.Bytes...))
+		return nil
+
+	case "bool":
+		if value.Kind != MichelinePrim || (value.Prim != "True" && value.Prim != "False") || out.Kind() != reflect.Bool {
+			return mismatch()
+		}
+		out.SetBool(value.Prim == "True")
+		return nil
+
+	case "unit":
+		return nil
+
+	case "timestamp":
+		if value.Kind == MichelineInt {
+			return setMichelineInt(value.Int, out, mismatch)
+		}
+		if value.Kind != MichelineString {
+			return mismatch()
+		}
+		t, err := time.Parse(time.RFC3339, value.String)
+		if err != nil {
+			return errors.Errorf("invalid timestamp '%s'", value.String)
+		}
+		if out.Type() != timeReflectType {
+			return setMichelineInt(big.NewInt(t.Unix()), out, mismatch)
+		}
+		out.Set(reflect.ValueOf(t))
+		return nil
+
+	case "list", "set":
+		if value.Kind != MichelineSeq || out.Kind() != reflect.Slice || len(typ.Args) != 1 {
+			return mismatch()
+		}
+		items := reflect.MakeSlice(out.Type(), len(value.Seq), len(value.Seq))
+		for k, item := range value.Seq {
+			if err := unmarshalMicheline(item, typ.Args[0], items.Index(k)); err != nil {
+				return err
+			}
+		}
+		out.Set(items)
+		return nil
+
+	case "map", "big_map":
+		if typ.Prim == "big_map" && value.Kind == MichelineInt {
+			if out.Type() == bigMapReflectType {
+				bigMaps, err := findBigMaps(value, typ)
+				if err != nil {
+					return err
+				}
+				out.Set(reflect.ValueOf(bigMaps[0]))
+				return nil
+			}
+			return setMichelineInt(value.Int, out, mismatch)
+		}
+		if value.Kind != MichelineSeq || out.Kind() != reflect.Map || len(typ.Args) != 2 {
+			return mismatch()
+		}
+		entries := reflect.MakeMapWithSize(out.Type(), len(value.Seq))
+		for _, elt := range value.Seq {
+			if elt.Kind != MichelinePrim || elt.Prim != "Elt" || len(elt.Args) != 2 {
+				return mismatch()
+			}
+			key := reflect.New(out.Type().Key()).Elem()
+			if err := unmarshalMicheline(elt.Args[0], typ.Args[0], key); err != nil {
+				return err
+			}
+			val := reflect.New(out.Type().Elem()).Elem()
+			if err := unmarshalMicheline(elt.Args[1], typ.Args[1], val); err != nil {
+				return err
+			}
+			entries.SetMapIndex(key, val)
+		}
+		out.Set(entries)
+		return nil
+
+	case "pair", "or":
+		if out.Kind() != reflect.Struct || out.Type() == bigIntReflectType || out.Type() == timeReflectType {
+			return mismatch()
+		}
+		leaves, err := michelineLeaves(value, typ, true)
+		if err != nil {
+			return err
+		}
+		return unmarshalMichelineStruct(typeLeaves(typ, true), leaves, out)
+	}
+
+	return mismatch()
+}
+
+// michelineLeaf is a value of a pair or or decoded into a struct field, with its position among the leaves of
+// the type
+type michelineLeaf struct {
+	value Micheline
+	typ   Micheline
+	annot string
+	slot  int
+}
+
+// michelineLeaves flattens the pairs, and the branches of ors, nested in a value without a field annotation.
+// The root of the value is always flattened.
+func michelineLeaves(value, typ Micheline, root bool) ([]michelineLeaf, error) {
+	return michelineSlots(value, typ, root, 0)
+}
+
+// typeLeaves flattens the pairs and ors nested in a type without a field annotation like michelineLeaves, with
+// the leaves of every branch of the ors
+func typeLeaves(typ Micheline, root bool) []michelineLeaf {
+	annot := fieldAnnot(typ)
+	if !flattenedType(typ, annot, root) {
+		return []michelineLeaf{{typ: typ, annot: annot}}
+	}
+	return append(typeLeaves(typ.Args[0], false), typeLeaves(typ.Args[1], false)...)
+}
+
+// flattenedType returns whether a pair or or type is flattened into its leaves
+func flattenedType(typ Micheline, annot string, root bool) bool {
+	return (root || annot == "") && typ.Kind == MichelinePrim && (typ.Prim == "pair" || typ.Prim == "or") && len(typ.Args) == 2
+}
+
+// michelineSlots flattens a value like michelineLeaves, numbering its leaves from slot by their position among the
+// leaves of the type
+func michelineSlots(value, typ Micheline, root bool, slot int) ([]michelineLeaf, error) {
+	annot := fieldAnnot(typ)
+	if !flattenedType(typ, annot, root) {
+		return []michelineLeaf{{value: value, typ: typ, annot: annot, slot: slot}}, nil
+	}
+
+	mismatch := errors.Errorf("value %s does not match type %s", value.Michelson(), typ.Michelson())
+	if value.Kind != MichelinePrim {
+		return nil, mismatch
+	}
+
+	switch {
+	case typ.Prim == "pair" && value.Prim == "Pair" && len(value.Args) == 2:
+		left, err := michelineSlots(value.Args[0], typ.Args[0], false, slot)
+		if err != nil {
+			return nil, err
+		}
+		right, err := michelineSlots(value.Args[1], typ.Args[1], false, slot+len(typeLeaves(typ.Args[0], false)))
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	case typ.Prim == "or" && value.Prim == "Left" && len(value.Args) == 1:
+		return michelineSlots(value.Args[0], typ.Args[0], false, slot)
+	case typ.Prim == "or" && value.Prim == "Right" && len(value.Args) == 1:
+		return michelineSlots(value.Args[0], typ.Args[1], false, slot+len(typeLeaves(typ.Args[0], false)))
+	}
+
+	return nil, mismatch
+}
+
+// unmarshalMichelineStruct decodes the leaves of a pair or or into the fields of a struct. Fields are matched to
+// the leaves of the type, so the leaves of a value go to the same fields whichever branches of its ors are taken.
+func unmarshalMichelineStruct(slots, leaves []michelineLeaf, out reflect.Value) error {
+	t := out.Type()
+	used := make([]bool, t.NumField())
+	var untagged []int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("michelson") == "-" {
+			used[i] = true
+			continue
+		}
+		if field.Tag.Get("michelson") == "" {
+			untagged = append(untagged, i)
+		}
+	}
+
+	fields := make([]int, len(slots))
+	for k, leaf := range slots {
+		fields[k] = -1
+		if leaf.annot == "" {
+			continue
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if !used[i] && t.Field(i).Tag.Get("michelson") == leaf.annot {
+				fields[k] = i
+				break
+			}
+		}
+		if fields[k] < 0 {
+			for _, i := range untagged {
+				if !used[i] && normalizeFieldName(t.Field(i).Name) == normalizeFieldName(leaf.annot) {
+					fields[k] = i
+					break
+				}
+			}
+		}
+		if fields[k] >= 0 {
+			used[fields[k]] = true
+		}
+	}
+
+	for k, leaf := range slots {
+		if leaf.annot != "" {
+			continue
+		}
+		for _, i := range untagged {
+			if !used[i] {
+				fields[k] = i
+				used[i] = true
+				break
+			}
+		}
+	}
+
+	for _, leaf := range leaves {
+		if fields[leaf.slot] < 0 {
+			continue
+		}
+		err := unmarshalMicheline(leaf.value, leaf.typ, out.Field(fields[leaf.slot]))
+		if err != nil {
+			return errors.Wrapf(err, "could not decode field %s", t.Field(fields[leaf.slot]).Name)
+		}
+	}
+
+	return nil
+}
+
+// setMichelineInt sets an integer into an integer, Mutez or big.Int
+func setMichelineInt(n *big.Int, out reflect.Value, mismatch func() error) error {
+	switch out.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !n.IsInt64() || out.OverflowInt(n.Int64()) {
+			return errors.Errorf("%s overflows %s", n, out.Type())
+		}
+		out.SetInt(n.Int64())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !n.IsUint64() || out.OverflowUint(n.Uint64()) {
+			return errors.Errorf("%s overflows %s", n, out.Type())
+		}
+		out.SetUint(n.Uint64())
+		return nil
+	}
+	if out.Type() == bigIntReflectType {
+		out.Set(reflect.ValueOf(*new(big.Int).Set(n)))
+		return nil
+	}
+	return mismatch()
+}
+
+// fieldAnnot returns the field annotation of a type without its '%', or an empty string
+func fieldAnnot(typ Micheline) string {
+	for _, annot := range typ.Annots {
+		if strings.HasPrefix(annot, "%") {
+			return annot[1:]
+		}
+	}
+	return ""
+}
+
+// normalizeFieldName lowers a name and removes its underscores, so "total_supply" matches "TotalSupply"
+func normalizeFieldName(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
@@ -0,0 +1,240 @@
+package gotezos
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// VotingService is a struct wrapper for the votes of the amendment process. Every function takes the id of the
+// block to query, a level or a hash such as "head", so past voting periods can be looked at as well.
+type VotingService struct {
+	gt *GoTezos
+}
+
+// VotingPeriod is a voting period of the amendment process at a block. Position is the position of the block in
+// the period, and Remaining the number of blocks of the period after it.
+type VotingPeriod struct {
+	Index     int
+	Kind      string
+	Position  int
+	Remaining int
+}
+
+// ProposalSupport is a proposal of a proposal period with the rolls of the delegates that upvoted it
+type ProposalSupport struct {
+	Proposal string
+	Rolls    int
+}
+
+// Ballots is the number of rolls that voted each ballot in a voting period
+type Ballots struct {
+	Yay  int `json:"yay"`
+	Nay  int `json:"nay"`
+	Pass int `json:"pass"`
+}
+
+// Ballot is the ballot cast by a delegate in a voting period
+type Ballot struct {
+	PublicKeyHash string `json:"pkh"`
+	Ballot        string `json:"ballot"`
+}
+
+// Listing is the number of rolls of a delegate allowed to vote in a voting period
+type Listing struct {
+	PublicKeyHash string `json:"pkh"`
+	Rolls         int    `json:"rolls"`
+}
+
+// Participation is the outcome of the ballots of a voting period at a block. Participation, Quorum and Approval are
+// in hundredths of a percent like the quorum of the protocol. Approval is the share of yay among yay and nay rolls,
+// and a supermajority is an approval of at least 80%.
+type Participation struct {
+	Period        VotingPeriod
+	Rolls         int
+	Ballots       Ballots
+	Participation int
+	Quorum        int
+	QuorumReached bool
+	Approval      int
+	Supermajority bool
+}
+
+// newVotingService returns a new VotingService
+func (gt *GoTezos) newVotingService() *VotingService {
+	return &VotingService{gt: gt}
+}
+
+// GetCurrentPeriod gets the voting period of the block id
+func (v *VotingService) GetCurrentPeriod(id interface{}) (VotingPeriod, error) {
+	var period VotingPeriod
+
+	block, err := v.gt.Block.Get(id)
+	if err != nil {
+		return period, errors.Wrap(err, "could not get current voting period")
+	}
+
+	period.Index = block.Metadata.Level.VotingPeriod
+	period.Kind = block.Metadata.VotingPeriodKind
+	period.Position = block.Metadata.Level.VotingPeriodPosition
+	period.Remaining = v.gt.Constants.BlocksPerVotingPeriod - period.Position - 1
+
+	return period, nil
+}
+
+// GetCurrentPeriodKind gets the kind of the voting period of the block id
+func (v *VotingService) GetCurrentPeriodKind(id interface{}) (string, error) {
+	var kind string
+	err := v.getVotes(id, "current_period_kind", &kind)
+	if err != nil {
+		return kind, errors.Wrap(err, "could not get current period kind")
+	}
+	return kind, nil
+}
+
+// GetCurrentProposal gets the proposal voted on at the block id, or an empty string if there is none
+func (v *VotingService) GetCurrentProposal(id interface{}) (string, error) {
+	var proposal *string
+	err := v.getVotes(id, "current_proposal", &proposal)
+	if err != nil {
+		return "", errors.Wrap(err, "could not get current proposal")
+	}
+	if proposal == nil {
+		return "", nil
+	}
+	return *proposal, nil
+}
+
+// GetProposals gets the proposals of the proposal period of the block id, with the rolls supporting them
+func (v *VotingService) GetProposals(id interface{}) ([]ProposalSupport, error) {
+	var pairs [][]json.RawMessage
+	err := v.getVotes(id, "proposals", &pairs)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get proposals")
+	}
+
+	proposals := make([]ProposalSupport, len(pairs))
+	for k, pair := range pairs {
+		if len(pair) != 2 {
+			return nil, errors.Errorf("could not get proposals, invalid proposal %v", pair)
+		}
+		if err := json.Unmarshal(pair[0], &proposals[k].Proposal); err != nil {
+			return nil, errors.Wrap(err, "could not get proposals")
+		}
+		if err := json.Unmarshal(pair[1], &proposals[k].Rolls); err != nil {
+			return nil, errors.Wrap(err, "could not get proposals")
+		}
+	}
+
+	return proposals, nil
+}
+
+// GetBallots gets the rolls that voted each ballot in the voting period of the block id
+func (v *VotingService) GetBallots(id interface{}) (Ballots, error) {
+	var ballots Ballots
+	err := v.getVotes(id, "ballots", &ballots)
+	if err != nil {
+		return ballots, errors.Wrap(err, "could not get ballots")
+	}
+	return ballots, nil
+}
+
+// GetBallotList gets the ballots cast by delegates in the voting period of the block id
+func (v *VotingService) GetBallotList(id interface{}) ([]Ballot, error) {
+	var ballots []Ballot
+	err := v.getVotes(id, "ballot_list", &ballots)
+	if err != nil {
+		return ballots, errors.Wrap(err, "could not get ballot list")
+	}
+	return ballots, nil
+}
+
+// GetListings gets the rolls of the delegates allowed to vote in the voting period of the block id
+func (v *VotingService) GetListings(id interface{}) ([]Listing, error) {
+	var listings []Listing
+	err := v.getVotes(id, "listings", &listings)
+	if err != nil {
+		return listings, errors.Wrap(err, "could not get listings")
+	}
+	return listings, nil
+}
+
+// GetCurrentQuorum gets the quorum of the voting period of the block id, in hundredths of a percent
+func (v *VotingService) GetCurrentQuorum(id interface{}) (int, error) {
+	var quorum int
+	err := v.getVotes(id, "current_quorum", &quorum)
+	if err != nil {
+		return quorum, errors.Wrap(err, "could not get current quorum")
+	}
+	return quorum, nil
+}
+
+// GetParticipation computes the participation and approval of the ballots of the voting period of the block id.
+// Proposal and testing periods have no ballots, so their participation is zero.
+func (v *VotingService) GetParticipation(id interface{}) (Participation, error) {
+	var participation Participation
+	var err error
+
+	participation.Period, err = v.GetCurrentPeriod(id)
+	if err != nil {
+		return participation, errors.Wrap(err, "could not get participation")
+	}
+
+	listings, err := v.GetListings(id)
+	if err != nil {
+		return participation, errors.Wrap(err, "could not get participation")
+	}
+	for _, listing := range listings {
+		participation.Rolls += listing.Rolls
+	}
+
+	participation.Ballots, err = v.GetBallots(id)
+	if err != nil {
+		return participation, errors.Wrap(err, "could not get participation")
+	}
+
+	participation.Quorum, err = v.GetCurrentQuorum(id)
+	if err != nil {
+		return participation, errors.Wrap(err, "could not get participation")
+	}
+
+	participation.compute()
+
+	return participation, nil
+}
+
+// compute sets the participation and approval of the ballots against the listed rolls and quorum
+func (p *Participation) compute() {
+	voted := p.Ballots.Yay + p.Ballots.Nay + p.Ballots.Pass
+	if p.Rolls > 0 {
+		p.Participation = voted * 10000 / p.Rolls
+	}
+	p.QuorumReached = p.Rolls > 0 && p.Participation >= p.Quorum
+
+	p.Approval, p.Supermajority = 0, false
+	if p.Ballots.Yay+p.Ballots.Nay > 0 {
+		p.Approval = p.Ballots.Yay * 10000 / (p.Ballots.Yay + p.Ballots.Nay)
+		p.Supermajority = p.Ballots.Yay*5 >= (p.Ballots.Yay+p.Ballots.Nay)*4
+	}
+}
+
+// getVotes gets a votes RPC of the block id and unmarshals it into out
+func (v *VotingService) getVotes(id interface{}, rpc string, out interface{}) error {
+	query, err := blockQuery(id)
+	if err != nil {
+		return errors.Wrapf(err, "could not get votes '%s'", rpc)
+	}
+	query = query + "/votes/" + rpc
+
+	resp, err := v.gt.Get(query, nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not get votes '%s'", query)
+	}
+
+	err = json.Unmarshal(resp, out)
+	if err != nil {
+		return errors.Wrapf(err, "could not get votes '%s'", query)
+	}
+
+	return nil
+}
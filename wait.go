@@ -0,0 +1,189 @@
+package gotezos
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// How often Wait polls the node for a new head when no interval is given
+	waitPollInterval = 5 * time.Second
+)
+
+// Statuses of an operation reported by Wait. Included manager operations report the status of their
+// operation result instead of OperationStatusIncluded.
+const (
+	OperationStatusPending       = "pending"
+	OperationStatusBranchDelayed = "branch_delayed"
+	OperationStatusUnprocessed   = "unprocessed"
+	OperationStatusBranchRefused = "branch_refused"
+	OperationStatusRefused       = "refused"
+	OperationStatusExpired       = "expired"
+	OperationStatusIncluded      = "included"
+	OperationStatusApplied       = "applied"
+	OperationStatusFailed        = "failed"
+	OperationStatusBacktracked   = "backtracked"
+	OperationStatusSkipped       = "skipped"
+)
+
+// WaitOptions configures how OperationService.Wait watches for an operation. Confirmations is the number of
+// blocks, including the one the operation was included in, required before Wait returns and defaults to 1.
+// Branch is the block hash the operation was forged on. When it is empty, the blocks of the last max operations ttl
+// before the head at the time Wait is called are scanned as well, and the head is used as the branch, which may make
+// Wait give up later than necessary. PollInterval defaults to 5 seconds.
+type WaitOptions struct {
+	Confirmations int
+	Branch        string
+	PollInterval  time.Duration
+}
+
+// OperationReceipt is the status of an operation watched by Wait, along with its receipt once included
+type OperationReceipt struct {
+	Hash          string
+	Status        string
+	BlockHash     string
+	Level         int
+	Confirmations int
+	Operation     Operation
+}
+
+// Wait watches new heads for the operation hash until it is included with the required number of confirmations.
+// It returns an error along with the receipt if the operation is refused by the mempool, or expires because its
+// branch became older than the max operations ttl before the operation was included. Operations refused on the
+// current branch of the node are still waited for, since they may be included on another branch. The outcome is reported to
+// the CounterManager of the service, so rejected or expired operations resync the counters of their source.
+func (o *OperationService) Wait(hash string, opts WaitOptions) (OperationReceipt, error) {
+	receipt := OperationReceipt{Hash: hash, Status: OperationStatusPending}
+
+	if opts.Confirmations < 1 {
+		opts.Confirmations = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = waitPollInterval
+	}
+
+	var branch Block
+	var err error
+	if opts.Branch != "" {
+		branch, err = o.gt.Block.Get(opts.Branch)
+	} else {
+		branch, err = o.gt.Block.GetHead()
+	}
+	if err != nil {
+		return receipt, errors.Wrapf(err, "could not wait for operation %s", hash)
+	}
+
+	// Operations can only be included in blocks after their branch, and an operation on an unknown branch may have
+	// been included in any block its branch was recent enough for
+	next := branch.Header.Level + 1
+	if opts.Branch == "" {
+		next = branch.Header.Level - branch.Metadata.MaxOperationsTTL
+		if next < 1 {
+			next = 1
+		}
+	}
+
+	for {
+		head, err := o.gt.Block.GetHead()
+		if err != nil {
+			return receipt, errors.Wrapf(err, "could not wait for operation %s", hash)
+		}
+
+		// Make sure the block the operation was included in was not replaced by a reorganisation
+		if receipt.BlockHash != "" {
+			block, err := o.gt.Block.Get(receipt.Level)
+			if err != nil {
+				return receipt, errors.Wrapf(err, "could not wait for operation %s", hash)
+			}
+			if block.Hash != receipt.BlockHash {
+				next = receipt.Level
+				receipt = OperationReceipt{Hash: hash, Status: OperationStatusPending}
+			}
+		}
+
+		for ; receipt.BlockHash == "" && next <= head.Header.Level; next++ {
+			block, err := o.gt.Block.Get(next)
+			if err != nil {
+				return receipt, errors.Wrapf(err, "could not wait for operation %s", hash)
+			}
+
+			if operation, ok := findOperation(block, hash); ok {
+				receipt.BlockHash = block.Hash
+				receipt.Level = block.Header.Level
+				receipt.Operation = operation
+				receipt.Status = operationStatus(operation)
+			}
+		}
+
+		if receipt.BlockHash != "" {
+			receipt.Confirmations = head.Header.Level - receipt.Level + 1
+			if receipt.Confirmations >= opts.Confirmations {
+				o.Counter.Confirm(hash)
+				return receipt, nil
+			}
+		} else {
+			status, err := o.getMempoolStatus(hash)
+			if err != nil {
+				return receipt, errors.Wrapf(err, "could not wait for operation %s", hash)
+			}
+			receipt.Status = status
+
+			if status == OperationStatusRefused {
+				o.Counter.Reject(hash)
+				return receipt, errors.Errorf("could not wait for operation %s, operation was %s", hash, status)
+			}
+
+			if head.Header.Level > branch.Header.Level+head.Metadata.MaxOperationsTTL {
+				receipt.Status = OperationStatusExpired
+				o.Counter.Reject(hash)
+				return receipt, errors.Errorf("could not wait for operation %s, branch %s is older than %d blocks", hash, branch.Hash, head.Metadata.MaxOperationsTTL)
+			}
+		}
+
+		time.Sleep(opts.PollInterval)
+	}
+}
+
+// getMempoolStatus returns the status of an operation in the node's mempool, or pending if it is not known
+// or not yet processed
+func (o *OperationService) getMempoolStatus(hash string) (string, error) {
+	pending, err := o.gt.Mempool.GetPendingOperations()
+	if err != nil {
+		return "", err
+	}
+
+	op, ok := pending.Find(hash)
+	if !ok || op.Status == OperationStatusApplied || op.Status == OperationStatusUnprocessed {
+		return OperationStatusPending, nil
+	}
+
+	return op.Status, nil
+}
+
+// findOperation looks for an operation hash in the operations of a block
+func findOperation(block Block, hash string) (Operation, bool) {
+	for _, pass := range block.Operations {
+		for _, operation := range pass {
+			if operation.Hash == hash {
+				return operation, true
+			}
+		}
+	}
+	return Operation{}, false
+}
+
+// operationStatus returns the status of an included operation, taken from its first unsuccessful manager operation result
+func operationStatus(operation Operation) string {
+	for _, contents := range operation.Contents {
+		if result, _, ok := contentsResult(contents); ok && result.Status != OperationStatusApplied {
+			return result.Status
+		}
+	}
+	for _, contents := range operation.Contents {
+		if _, _, ok := contentsResult(contents); ok {
+			return OperationStatusApplied
+		}
+	}
+	return OperationStatusIncluded
+}